@@ -0,0 +1,71 @@
+// Allows for caching decoded segments, so that probing, streaming and retries do not fetch the same articles again and again.
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Returns a decoded segment from the cache, or fetches and decodes it and stores the result. A nil cache just fetches.
+//Data failing its CRC check is returned alongside yenc.ErrCRCMismatch and is not cached.
+func FetchPart(ctx context.Context, c Cache, fetcher nntp.Fetcher, messageID string) (*yenc.Part, error) {
+	if c != nil {
+		if part, ok := c.Get(messageID); ok {
+			return part, nil
+		}
+	}
+
+	body, err := fetcher.Fetch(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	part, err := yenc.Decode(body)
+	if err != nil {
+		if part != nil && errors.Is(err, yenc.ErrCRCMismatch) {
+			return part, err
+		}
+		return nil, err
+	}
+
+	if c != nil {
+		c.Put(messageID, part)
+	}
+	return part, nil
+}
+
+//Returns the body of an article. Cached parts are encoded again as yEnc; on a miss the body is fetched from the source and
+//returned as it came, while its decoded part is cached if it passes its CRC check.
+func (f *Fetcher) Fetch(ctx context.Context, messageID string) ([]byte, error) {
+	if part, ok := f.Cache.Get(messageID); ok {
+		return yenc.EncodePart(*part, 0), nil
+	}
+	body, err := f.Source.Fetch(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if part, err := yenc.Decode(body); err == nil {
+		f.Cache.Put(messageID, part)
+	}
+	return body, nil
+}
+
+//Checks the memory tier, then the disk tier, copying disk hits into memory.
+func (t *Tiered) Get(messageID string) (*yenc.Part, bool) {
+	if part, ok := t.Memory.Get(messageID); ok {
+		return part, true
+	}
+	part, ok := t.Disk.Get(messageID)
+	if ok {
+		t.Memory.Put(messageID, part)
+	}
+	return part, ok
+}
+
+//Stores a part in both tiers.
+func (t *Tiered) Put(messageID string, part *yenc.Part) {
+	t.Memory.Put(messageID, part)
+	t.Disk.Put(messageID, part)
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func part(size int) *yenc.Part {
+	return &yenc.SplitParts("file.bin", make([]byte, size), 0)[0]
+}
+
+func TestMemoryEviction(t *testing.T) {
+	m := NewMemory(250)
+	m.Put("a", part(100))
+	m.Put("b", part(100))
+	m.Get("a")
+	m.Put("c", part(100))
+
+	if _, ok := m.Get("b"); ok {
+		t.Fatal("expected the least recently used part to be evicted")
+	}
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("expected the recently used part to be kept")
+	}
+	if count, size := m.Len(); count != 2 || size != 200 {
+		t.Fatalf("unexpected cache size: %d parts, %d bytes", count, size)
+	}
+}
+
+func TestDiskValidationAndCap(t *testing.T) {
+	dir := t.TempDir()
+	d, err := OpenDisk(dir, 2500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := yenc.SplitParts("file.bin", []byte("some segment data"), 0)[0]
+	d.Put("seg@test", &original)
+	got, ok := d.Get("seg@test")
+	if !ok || string(got.Data) != "some segment data" || got.End != original.End {
+		t.Fatalf("unexpected round trip: %+v", got)
+	}
+
+	//Flipping a data byte must turn the entry into a miss.
+	path := filepath.Join(dir, diskKey("seg@test")+diskExtension)
+	raw, _ := os.ReadFile(path)
+	raw[len(raw)-1] ^= 0xff
+	os.WriteFile(path, raw, 0644)
+	if _, ok := d.Get("seg@test"); ok {
+		t.Fatal("expected a corrupted entry to fail validation")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected the corrupted entry to be removed")
+	}
+
+	for i := 0; i < 5; i++ {
+		d.Put(strconv.Itoa(i), part(1000))
+	}
+	count, size := d.Len()
+	if size > 2500 || count != 2 {
+		t.Fatalf("disk tier exceeded its cap: %d entries, %d bytes", count, size)
+	}
+
+	reopened, err := OpenDisk(dir, 2500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("4"); !ok {
+		t.Fatal("expected entries to survive reopening")
+	}
+}
+
+func TestFetchPartThroughTiers(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	defer server.Close()
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	disk, _ := OpenDisk(t.TempDir(), 10<<20)
+	tiered := &Tiered{Memory: NewMemory(10 << 20), Disk: disk}
+	id := nzb.Files[2].Segments[0].ID
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := FetchPart(context.Background(), tiered, client, id); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	before := server.CommandCount("BODY")
+	fresh := &Tiered{Memory: NewMemory(10 << 20), Disk: disk}
+	if _, err := FetchPart(context.Background(), fresh, client, id); err != nil {
+		t.Fatal(err)
+	}
+	if server.CommandCount("BODY") != before {
+		t.Fatal("expected the disk tier to serve the segment without fetching it")
+	}
+	if _, ok := fresh.Memory.Get(id); !ok {
+		t.Fatal("expected the disk hit to be promoted into memory")
+	}
+}
+
+func TestDiskGetKeepsConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	d, err := OpenDisk(dir, 10<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, diskKey("seg@test")+diskExtension)
+
+	//A Get finding a corrupt entry races a Put replacing it; the fresh entry must survive either order.
+	for i := 0; i < 100; i++ {
+		d.Put("seg@test", part(1<<20))
+		raw, _ := os.ReadFile(path)
+		raw[len(raw)-1] ^= 0xff
+		os.WriteFile(path, raw, 0644)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			d.Get("seg@test")
+		}()
+		go func() {
+			defer wg.Done()
+			d.Put("seg@test", part(1<<20))
+		}()
+		wg.Wait()
+		if _, ok := d.Get("seg@test"); !ok {
+			t.Fatalf("a fresh entry was removed on iteration %d", i)
+		}
+	}
+}
+
+func TestFetcherServesHits(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	defer server.Close()
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	var fetcher nntp.Fetcher = &Fetcher{Cache: NewMemory(10 << 20), Source: client}
+	id := nzb.Files[2].Segments[0].ID
+	var parts []*yenc.Part
+	for i := 0; i < 2; i++ {
+		body, err := fetcher.Fetch(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := yenc.Decode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+	if server.CommandCount("BODY") != 1 {
+		t.Fatalf("fetched the body %d times", server.CommandCount("BODY"))
+	}
+	if string(parts[0].Data) != string(parts[1].Data) || parts[0].Begin != parts[1].Begin || parts[0].FileSize != parts[1].FileSize {
+		t.Fatalf("cached body decodes differently: %+v", parts[1])
+	}
+}
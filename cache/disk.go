@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Extension of disk cache entries.
+const diskExtension = ".seg"
+
+//Opens a disk cache in dir, creating it if needed and indexing the entries already there, oldest first, so that a reopened cache
+//keeps evicting in a sensible order.
+func OpenDisk(dir string, maxBytes int64) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	d := &Disk{
+		dir: dir,
+		maxBytes: maxBytes,
+		entries: map[string]*list.Element{},
+		order: list.New(),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		key string
+		size int64
+		modified int64
+	}
+	var found []existing
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), diskExtension) {
+			//Leftovers of interrupted writes.
+			if strings.HasPrefix(f.Name(), ".tmp-") {
+				os.Remove(filepath.Join(dir, f.Name()))
+			}
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, existing{strings.TrimSuffix(f.Name(), diskExtension), info.Size(), info.ModTime().UnixNano()})
+	}
+	sort.Slice(found, func(a, b int) bool {
+		return found[a].modified < found[b].modified
+	})
+	for _, e := range found {
+		d.entries[e.key] = d.order.PushFront(&diskEntry{key: e.key, size: e.size})
+		d.bytes += e.size
+	}
+
+	d.mu.Lock()
+	d.evict()
+	d.mu.Unlock()
+	return d, nil
+}
+
+//Hashes a message-ID into a filename-safe key.
+func diskKey(messageID string) string {
+	sum := sha256.Sum256([]byte(messageID))
+	return hex.EncodeToString(sum[:])
+}
+
+//Returns the path of an entry.
+func (d *Disk) path(key string) string {
+	return filepath.Join(d.dir, key+diskExtension)
+}
+
+//Drops an entry from the index and removes its file. Callers hold d.mu.
+func (d *Disk) drop(key string) {
+	if element, ok := d.entries[key]; ok {
+		d.bytes -= element.Value.(*diskEntry).size
+		d.order.Remove(element)
+		delete(d.entries, key)
+	}
+	os.Remove(d.path(key))
+}
+
+//Removes least recently used entries until the cache fits its cap. Callers hold d.mu.
+func (d *Disk) evict() {
+	for d.bytes > d.maxBytes && d.order.Len() > 0 {
+		d.drop(d.order.Back().Value.(*diskEntry).key)
+	}
+}
+
+//Reads a part from disk, verifying its checksum. Corrupt or unreadable entries are removed and reported as misses.
+func (d *Disk) Get(messageID string) (*yenc.Part, bool) {
+	key := diskKey(messageID)
+
+	d.mu.Lock()
+	element, ok := d.entries[key]
+	if ok {
+		d.order.MoveToFront(element)
+	}
+	d.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	part, ok := readEntry(d.path(key), messageID)
+	if !ok {
+		//A Put may have replaced the entry since it was looked up, in which case its fresh file must be kept.
+		d.mu.Lock()
+		if d.entries[key] == element {
+			d.drop(key)
+		}
+		d.mu.Unlock()
+	}
+	return part, ok
+}
+
+//Parses an entry file: a JSON header line followed by the data.
+func readEntry(path string, messageID string) (*yenc.Part, bool) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	line, data, found := bytes.Cut(raw, []byte("\n"))
+	if !found {
+		return nil, false
+	}
+
+	var header diskHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, false
+	}
+	if header.ID != messageID || header.Length != len(data) || crc32.ChecksumIEEE(data) != header.CRC32 {
+		return nil, false
+	}
+
+	return &yenc.Part{
+		Name: header.Name,
+		Number: header.Number,
+		Total: header.Total,
+		Begin: header.Begin,
+		End: header.End,
+		FileSize: header.FileSize,
+		Data: data,
+		CRC32: header.CRC32,
+		FileCRC32: header.FileCRC32,
+	}, true
+}
+
+//Writes a part to disk atomically, then evicts old entries if the cap is exceeded. Write failures are ignored, as a cache may
+//always miss.
+func (d *Disk) Put(messageID string, part *yenc.Part) {
+	key := diskKey(messageID)
+	header, err := json.Marshal(diskHeader{
+		ID: messageID,
+		Name: part.Name,
+		Number: part.Number,
+		Total: part.Total,
+		Begin: part.Begin,
+		End: part.End,
+		FileSize: part.FileSize,
+		FileCRC32: part.FileCRC32,
+		Length: len(part.Data),
+		CRC32: crc32.ChecksumIEEE(part.Data),
+	})
+	if err != nil {
+		return
+	}
+
+	size := int64(len(header) + 1 + len(part.Data))
+	if size > d.maxBytes {
+		return
+	}
+
+	temp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	writer := bufio.NewWriter(temp)
+	writer.Write(header)
+	writer.WriteByte('\n')
+	writer.Write(part.Data)
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return
+	}
+	temp.Close()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := os.Rename(temp.Name(), d.path(key)); err != nil {
+		os.Remove(temp.Name())
+		return
+	}
+	if element, ok := d.entries[key]; ok {
+		d.bytes -= element.Value.(*diskEntry).size
+		d.order.Remove(element)
+	}
+	d.entries[key] = d.order.PushFront(&diskEntry{key: key, size: size})
+	d.bytes += size
+	d.evict()
+}
+
+//Returns the number of cached entries and their total size on disk.
+func (d *Disk) Len() (int, int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.entries), d.bytes
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"container/list"
+
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Creates a memory cache holding up to maxBytes of decoded data.
+func NewMemory(maxBytes int64) *Memory {
+	return &Memory{
+		maxBytes: maxBytes,
+		entries: map[string]*list.Element{},
+		order: list.New(),
+	}
+}
+
+//Returns a cached part, marking it as recently used. The part is shared and must not be modified.
+func (m *Memory) Get(messageID string) (*yenc.Part, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[messageID]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+	return element.Value.(*memoryEntry).part, true
+}
+
+//Stores a part, evicting the least recently used ones until the cache fits its cap. Parts larger than the cap are not stored.
+func (m *Memory) Put(messageID string, part *yenc.Part) {
+	size := int64(len(part.Data))
+	if size > m.maxBytes {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if element, ok := m.entries[messageID]; ok {
+		m.bytes -= int64(len(element.Value.(*memoryEntry).part.Data))
+		m.order.Remove(element)
+	}
+	m.entries[messageID] = m.order.PushFront(&memoryEntry{id: messageID, part: part})
+	m.bytes += size
+
+	for m.bytes > m.maxBytes {
+		oldest := m.order.Remove(m.order.Back()).(*memoryEntry)
+		delete(m.entries, oldest.id)
+		m.bytes -= int64(len(oldest.part.Data))
+	}
+}
+
+//Returns the number of cached parts and their total data size.
+func (m *Memory) Len() (int, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries), m.bytes
+}
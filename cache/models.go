@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Store of decoded segments keyed by message-ID (parser.Segment.ID). Implementations are safe for concurrent use.
+type Cache interface {
+	//Returns a cached part, or false if it is absent or failed validation.
+	Get(messageID string) (*yenc.Part, bool)
+	Put(messageID string, part *yenc.Part)
+}
+
+//An entry of the memory tier.
+type memoryEntry struct {
+	id string
+	part *yenc.Part
+}
+
+//In-memory least-recently-used cache, capped by the total size of the data it holds.
+type Memory struct {
+	mu sync.Mutex
+	maxBytes int64
+	bytes int64
+	entries map[string]*list.Element
+	order *list.List
+}
+
+//Bookkeeping for a file of the disk tier.
+type diskEntry struct {
+	key string
+	size int64
+}
+
+//On-disk cache, capped by the total size of its files. Entries carry a checksum verified on every read.
+type Disk struct {
+	dir string
+	mu sync.Mutex
+	maxBytes int64
+	bytes int64
+	entries map[string]*list.Element
+	order *list.List
+}
+
+//A memory tier in front of a disk tier. Reads promote disk hits into memory; writes go to both.
+type Tiered struct {
+	Memory Cache
+	Disk Cache
+}
+
+//Serves article bodies through a cache in front of another fetcher, satisfying nntp.Fetcher, for components that decode bodies
+//themselves, such as probes.
+type Fetcher struct {
+	Cache Cache
+	Source nntp.Fetcher
+}
+
+//Header written before the data of a disk entry.
+type diskHeader struct {
+	ID string `json:"id"`
+	Name string `json:"name"`
+	Number int `json:"number"`
+	Total int `json:"total"`
+	Begin int64 `json:"begin"`
+	End int64 `json:"end"`
+	FileSize int64 `json:"size"`
+	FileCRC32 uint32 `json:"file_crc32"`
+	Length int `json:"length"`
+	CRC32 uint32 `json:"crc32"`
+}
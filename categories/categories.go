@@ -0,0 +1,199 @@
+// Allows for mapping the categories indexers and NZBs carry, such as newznab IDs ("5040"), indexer labels ("TV > HD") and free text
+// ("misc."), to local categories with their own output directories and post-processing.
+package categories
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jgr0sz/nzbgo/newznab"
+	"github.com/jgr0sz/nzbgo/release"
+)
+
+//Compiled regexes for release names release.Parse has no fields for.
+var (
+	LOSSLESS_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(flac|alac|ape|lossless|24bit)(?:[ ._\-\])]|$)`)
+	AUDIO_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(mp3|aac|ogg|opus|320kbps|\d{3}kbps|v0|discography|album)(?:[ ._\-\])]|$)`)
+	EBOOK_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(epub|mobi|azw3?|ebooks?|retail[ ._\-]ebook)(?:[ ._\-\])]|$)`)
+	SOFTWARE_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(win(?:32|64|dows)|x64|x86|macos|linux|keygen|multilingual)(?:[ ._\-\])]|$)`)
+)
+
+//Separators ignored when comparing names.
+var separatorPattern = regexp.MustCompile(`[\s._\-/>:,()\[\]]+`)
+
+//Lowercases text and reduces separators to single spaces.
+func normalize(text string) string {
+	return strings.TrimSpace(separatorPattern.ReplaceAllString(strings.ToLower(text), " "))
+}
+
+//Reads a JSON configuration file and creates a mapper from it.
+func Load(path string) (*Mapper, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("categories: %s: %w", path, err)
+	}
+	return New(config)
+}
+
+//Creates a mapper, checking that category names are set and unique, the default exists and every pattern compiles.
+func New(config Config) (*Mapper, error) {
+	mapper := &Mapper{config: config, patterns: make([][]*regexp.Regexp, len(config.Categories))}
+	seen := map[string]bool{}
+	for i, c := range config.Categories {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("categories: category %d has no name", i+1)
+		case seen[name]:
+			return nil, fmt.Errorf("categories: category %q is defined twice", c.Name)
+		}
+		seen[name] = true
+
+		for _, p := range c.Patterns {
+			pattern, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("categories: category %q: %w", c.Name, err)
+			}
+			mapper.patterns[i] = append(mapper.patterns[i], pattern)
+		}
+	}
+	if config.Default != "" && !seen[strings.ToLower(config.Default)] {
+		return nil, fmt.Errorf("categories: default category %q is not defined", config.Default)
+	}
+	return mapper, nil
+}
+
+//Lists the local categories in configuration order.
+func (m *Mapper) Categories() []Category {
+	return append([]Category{}, m.config.Categories...)
+}
+
+//Finds a local category by name, case-insensitively. Nil if there is none.
+func (m *Mapper) Lookup(name string) *Category {
+	name = strings.TrimSpace(name)
+	for i := range m.config.Categories {
+		if strings.EqualFold(m.config.Categories[i].Name, name) {
+			return &m.config.Categories[i]
+		}
+	}
+	return nil
+}
+
+//Maps a category as given by an indexer or NZB, and the release's name, to a local category. The first of these to match wins:
+//  - a pattern of a local category matching the release name;
+//  - a local category's name, or one of its configured names;
+//  - a newznab ID, given as a number or recognized from a standard label, looked up in the categories' newznab IDs;
+//  - a newznab ID guessed from the release name, looked up the same way;
+//  - the default category.
+//Inputs recognized as newznab's catch-all Other categories are tried after the release name. Nil if nothing matches and there is
+//no default.
+func (m *Mapper) Map(category string, name string) *Category {
+	for i, patterns := range m.patterns {
+		for _, p := range patterns {
+			if name != "" && p.MatchString(name) {
+				return &m.config.Categories[i]
+			}
+		}
+	}
+
+	category = strings.TrimSpace(category)
+	if found := m.Lookup(category); found != nil {
+		return found
+	}
+	if wanted := normalize(category); wanted != "" {
+		for i, c := range m.config.Categories {
+			for _, n := range c.Names {
+				if normalize(n) == wanted {
+					return &m.config.Categories[i]
+				}
+			}
+		}
+	}
+
+	id, recognized := newznab.CategoryID(category)
+	if recognized && id/1000 != newznab.CategoryOther/1000 {
+		if found := m.byNewznab(id); found != nil {
+			return found
+		}
+	}
+	if guessed := Guess(name); guessed != 0 {
+		if found := m.byNewznab(guessed); found != nil {
+			return found
+		}
+	}
+	if recognized {
+		if found := m.byNewznab(id); found != nil {
+			return found
+		}
+	}
+	return m.Lookup(m.config.Default)
+}
+
+//Finds the category listing a newznab ID, preferring an exact match over one listing its parent.
+func (m *Mapper) byNewznab(id int) *Category {
+	parent := id / 1000 * 1000
+	var byParent *Category
+	for i, c := range m.config.Categories {
+		for _, listed := range c.Newznab {
+			switch {
+			case listed == id:
+				return &m.config.Categories[i]
+			case listed == parent && byParent == nil:
+				byParent = &m.config.Categories[i]
+			}
+		}
+	}
+	return byParent
+}
+
+//Maps a category and release name like Map, returning the local category's name. The boolean reports whether anything matched.
+func (m *Mapper) Normalize(category string, name string) (string, bool) {
+	found := m.Map(category, name)
+	if found == nil {
+		return "", false
+	}
+	return found.Name, true
+}
+
+//Guesses a newznab category ID from a release name: episodes are TV, named music formats Audio, ebook formats Books, dated releases
+//with a resolution or source Movies and operating systems PC. Resolution picks SD, HD or UHD for video. Zero if nothing fits.
+func Guess(name string) int {
+	if strings.TrimSpace(name) == "" {
+		return 0
+	}
+	info := release.Parse(name)
+	video := func(base int) int {
+		switch info.Resolution {
+		case "2160p", "4k":
+			return base + 45
+		case "720p", "1080p", "1080i":
+			return base + 40
+		case "480p", "576p":
+			return base + 30
+		}
+		return base
+	}
+
+	switch {
+	case info.Season > 0 || info.Episode > 0:
+		return video(5000)
+	case LOSSLESS_PATTERN.MatchString(name):
+		return 3040
+	case AUDIO_PATTERN.MatchString(name):
+		return 3010
+	case EBOOK_PATTERN.MatchString(name):
+		return 7020
+	case info.Year > 0 && (info.Resolution != "" || info.Source != ""):
+		return video(2000)
+	case SOFTWARE_PATTERN.MatchString(name):
+		return 4000
+	}
+	return 0
+}
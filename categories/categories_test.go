@@ -0,0 +1,111 @@
+package categories
+
+import (
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/metaeditor"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+func TestMap(t *testing.T) {
+	mapper, err := Load("../_tests/categories/categories.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tv := mapper.Lookup("TV"); tv == nil || tv.Directory != "/data/tv" || !tv.PostProcessing.Cleanup || tv.PostProcessing.Script != "notify.sh" {
+		t.Fatalf("unexpected tv category %+v", tv)
+	}
+
+	cases := []struct {
+		category string
+		name string
+		want string
+	}{
+		{"5040", "", "tv"},
+		{"5045", "", "tv-uhd"},
+		{"TV > HD", "", "tv"},
+		{"Movies > HD", "", "movies"},
+		{"Television", "", "tv"},
+		{"movies", "", "movies"},
+		//Other categories give way to the release name.
+		{"misc.", "Some.Show.S01E02.2160p.WEB-DL-GRP", "tv-uhd"},
+		{"8010", "Some.Movie.2019.1080p.BluRay.x264-GRP", "movies"},
+		{"misc.", "random", "misc"},
+		{"", "Artist-Album-2020-FLAC", "music"},
+		{"", "Some.Movie.2019.1080p.BluRay.x264-GRP", "movies"},
+		//Patterns beat the given category.
+		{"TV > HD", "Nature.Documentary.2020.1080p.BluRay-GRP", "docs"},
+		{"unheard of", "", "misc"},
+		{"", "", "misc"},
+	}
+	for _, c := range cases {
+		if got, _ := mapper.Normalize(c.category, c.name); got != c.want {
+			t.Errorf("Map(%q, %q) = %q, want %q", c.category, c.name, got, c.want)
+		}
+	}
+
+	//Without a default, unmatched inputs map to nothing.
+	bare, err := New(Config{Categories: []Category{{Name: "tv", Newznab: []int{5000}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found := bare.Map("7020", ""); found != nil {
+		t.Fatalf("unexpected match %+v", found)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, config := range []Config{
+		{Categories: []Category{{Name: "tv"}, {Name: "TV"}}},
+		{Categories: []Category{{Name: ""}}},
+		{Categories: []Category{{Name: "tv", Patterns: []string{"("}}}},
+		{Default: "misc", Categories: []Category{{Name: "tv"}}},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("invalid config accepted: %+v", config)
+		}
+	}
+}
+
+func TestGuess(t *testing.T) {
+	for name, want := range map[string]int{
+		"Show.Name.S01E02.720p.HDTV.x264-GRP": 5040,
+		"Show.Name.1x02.DVDRip-GRP": 5000,
+		"Movie.Name.2010.2160p.UHD.BluRay-GRP": 2045,
+		"Artist - Album (2001) [MP3 320kbps]": 3010,
+		"Author.Name.Book.Title.2019.RETAIL.EPUB": 7020,
+		"Some.Tool.v2.1.x64.Multilingual-GRP": 4000,
+		"random words": 0,
+	} {
+		if got := Guess(name); got != want {
+			t.Errorf("Guess(%q) = %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	mapper, err := Load("../_tests/categories/categories.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nzb := &parser.Nzb{Head: parser.Head{Meta: []parser.Meta{
+		{Type: "title", Value: "Show.Name.S01E02.1080p.WEB-DL-GRP"},
+		{Type: "category", Value: "TV > HD"},
+	}}}
+	editor := &metaeditor.NzbMetaEditor{Metadata: nzb.Head.Meta, Nzb: nzb}
+	if !metaeditor.NormalizeCategory(editor, mapper) || parser.Category(editor.Metadata) != "tv" || len(editor.Metadata) != 2 {
+		t.Fatalf("category was not normalized: %+v", editor.Metadata)
+	}
+
+	//Without a category or title, the file name is used and the field is added.
+	nzb, err = parser.FromFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nzb.Head.Meta = nil
+	nzb.Files[0].Subject = `"Some.Movie.2019.1080p.BluRay.x264-GRP.r01" yEnc (1/2)`
+	editor = &metaeditor.NzbMetaEditor{Metadata: nzb.Head.Meta, Nzb: nzb}
+	if !metaeditor.NormalizeCategory(editor, mapper) || parser.Category(editor.Metadata) != "movies" {
+		t.Fatalf("category was not added: %+v", editor.Metadata)
+	}
+}
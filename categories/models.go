@@ -0,0 +1,41 @@
+package categories
+
+import "regexp"
+
+//What to do with a category's jobs once downloaded.
+type PostProcessing struct {
+	Repair bool `json:"repair"`
+	Unpack bool `json:"unpack"`
+	//Deletes archives and par2 files once unpacked.
+	Cleanup bool `json:"cleanup"`
+	//Script run after post-processing, if any.
+	Script string `json:"script,omitempty"`
+}
+
+//A local category and the inputs that map to it.
+type Category struct {
+	Name string `json:"name"`
+	//Where the category's jobs are moved once done.
+	Directory string `json:"directory"`
+	PostProcessing PostProcessing `json:"postprocessing"`
+	//Newznab category IDs. A parent ID such as 5000 covers its subcategories, unless one of those is listed by another category.
+	Newznab []int `json:"newznab,omitempty"`
+	//Indexer labels and free text mapped here, such as "TV > HD" or "television". Compared case-insensitively and ignoring
+	//separators.
+	Names []string `json:"names,omitempty"`
+	//Regexes matched against the release name, taking precedence over the given category.
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+//Category mapping configuration, as stored in a JSON file.
+type Config struct {
+	//Category used when nothing else matches; none if empty.
+	Default string `json:"default,omitempty"`
+	Categories []Category `json:"categories"`
+}
+
+//Maps indexer and NZB categories to local categories.
+type Mapper struct {
+	config Config
+	patterns [][]*regexp.Regexp
+}
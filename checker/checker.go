@@ -0,0 +1,253 @@
+// Allows for checking whether the segments of an NZB are still available across one or more Usenet servers before downloading.
+package checker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Returned when no server could be connected to at all.
+var ErrNoServers = errors.New("checker: no server could be reached")
+
+//Chooses which segments to check. With sampling, a random subset of each file's segments is picked, at least one per file.
+func selectSegments(nzb *parser.Nzb, options Options) []SegmentStatus {
+	sample := options.SamplePercent > 0 && options.SamplePercent < 100
+	random := rand.New(rand.NewSource(options.Seed))
+
+	var segments []SegmentStatus
+	for i, f := range nzb.Files {
+		chosen := make([]bool, len(f.Segments))
+		if sample {
+			count := max(int(math.Ceil(float64(len(f.Segments))*options.SamplePercent/100)), 1)
+			for _, idx := range random.Perm(len(f.Segments))[:min(count, len(f.Segments))] {
+				chosen[idx] = true
+			}
+		}
+
+		for j, s := range f.Segments {
+			segments = append(segments, SegmentStatus{
+				File: i,
+				Segment: s,
+				Checked: !sample || chosen[j],
+			})
+		}
+	}
+	return segments
+}
+
+//Checks the availability of an NZB's segments on every server, issuing pipelined STAT commands over up to server.Connections
+//connections per server. Servers are checked concurrently. Only an unreachable set of servers, or a cancelled ctx, returns an error.
+func Check(ctx context.Context, nzb *parser.Nzb, servers []Server, options Options) (*Report, error) {
+	report := &Report{
+		Segments: selectSegments(nzb, options),
+		ServerErrors: make([]error, len(servers)),
+	}
+	for i := range report.Segments {
+		report.Segments[i].Servers = make([]Status, len(servers))
+	}
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		report.Servers = append(report.Servers, server.Name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.ServerErrors[i] = checkServer(ctx, report.Segments, i, server, options)
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reachable := false
+	for _, err := range report.ServerErrors {
+		reachable = reachable || err == nil
+	}
+	if !reachable && len(servers) > 0 {
+		return nil, errors.Join(append([]error{ErrNoServers}, report.ServerErrors...)...)
+	}
+
+	summarize(nzb, report)
+	return report, nil
+}
+
+//Checks every selected segment against a single server, writing into column idx of the matrix. Each worker owns a distinct
+//batch of rows, so no locking is needed.
+func checkServer(ctx context.Context, segments []SegmentStatus, idx int, server Server, options Options) error {
+	depth := options.PipelineDepth
+	if depth <= 0 {
+		depth = 16
+	}
+
+	//Batching the rows to check, so that each batch is a single pipelined round trip.
+	batches := make(chan []int)
+	go func() {
+		defer close(batches)
+		var batch []int
+		for i, s := range segments {
+			if !s.Checked {
+				continue
+			}
+			batch = append(batch, i)
+			if len(batch) == depth {
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+					return
+				}
+				batch = nil
+			}
+		}
+		if len(batch) > 0 {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	connections := max(server.Connections, 1)
+	errs := make([]error, connections)
+	unfinished := make([][]int, connections)
+	var wg sync.WaitGroup
+	for w := 0; w < connections; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unfinished[w], errs[w] = checkWorker(ctx, segments, idx, server.Config, batches)
+		}()
+	}
+	wg.Wait()
+	//Unblocking the batcher in case every connection failed.
+	for range batches {
+	}
+
+	//A server counts as reachable as long as one of its connections worked. The batches its failed connections were holding are
+	//retried over a fresh connection; rows still unchecked after that stay Unknown.
+	reachable := false
+	for _, err := range errs {
+		reachable = reachable || err == nil
+	}
+	if !reachable {
+		return errors.Join(errs...)
+	}
+	retry := make(chan []int, connections)
+	for _, batch := range unfinished {
+		if batch != nil {
+			retry <- batch
+		}
+	}
+	close(retry)
+	if len(retry) > 0 {
+		checkWorker(ctx, segments, idx, server.Config, retry)
+	}
+	return nil
+}
+
+//Consumes batches over a single connection, reconnecting once if the connection drops mid-check. On failure, the batch it was
+//checking is returned so another connection can take it over.
+func checkWorker(ctx context.Context, segments []SegmentStatus, idx int, config nntp.Config, batches <-chan []int) ([]int, error) {
+	client, err := nntp.DialContext(ctx, config)
+	if err != nil {
+		//Leaving the batches for the server's other connections.
+		return nil, err
+	}
+	defer func() {
+		client.Quit()
+	}()
+
+	for batch := range batches {
+		ids := make([]string, len(batch))
+		for i, row := range batch {
+			ids[i] = segments[row].Segment.ID
+		}
+
+		results, err := client.PipelineStat(ids)
+		if err != nil {
+			client.Close()
+			//Dialing into a separate variable, so the deferred Quit never sees a nil client.
+			reconnected, err := nntp.DialContext(ctx, config)
+			if err != nil {
+				return batch, err
+			}
+			client = reconnected
+			if results, err = client.PipelineStat(ids); err != nil {
+				return batch, err
+			}
+		}
+
+		for i, row := range batch {
+			switch {
+			case results[i] == nil:
+				segments[row].Servers[idx] = Available
+			case nntp.IsNoSuchArticle(results[i]):
+				segments[row].Servers[idx] = Missing
+			}
+		}
+	}
+	return nil, nil
+}
+
+//Fills in per-file, per-set and overall completeness from the availability matrix.
+func summarize(nzb *parser.Nzb, report *Report) {
+	report.Files = make([]FileReport, len(nzb.Files))
+	for i, f := range nzb.Files {
+		report.Files[i] = FileReport{
+			Name: parser.ExtractFilename(f),
+			Segments: len(f.Segments),
+			PerServer: make([]int, len(report.Servers)),
+		}
+	}
+
+	for _, s := range report.Segments {
+		if !s.Checked {
+			continue
+		}
+		file := &report.Files[s.File]
+		available, known := false, false
+		for i, status := range s.Servers {
+			if status == Available {
+				file.PerServer[i]++
+				available = true
+			}
+			known = known || status != Unknown
+		}
+		//A segment no server answered for is not known to be missing, so it is left out like an unsampled one.
+		if !known {
+			file.Unknown++
+			continue
+		}
+		file.Checked++
+		if available {
+			file.Available++
+		}
+	}
+
+	setIndex := map[string]int{}
+	report.Complete = true
+	for i := range report.Files {
+		file := &report.Files[i]
+		file.Complete = file.Available == file.Checked
+		report.Checked += file.Checked
+		report.Available += file.Available
+		report.Unknown += file.Unknown
+		report.Complete = report.Complete && file.Complete
+
+		name := parser.SetName(file.Name)
+		idx, ok := setIndex[name]
+		if !ok {
+			idx = len(report.Sets)
+			setIndex[name] = idx
+			report.Sets = append(report.Sets, SetReport{Name: name, Complete: true})
+		}
+		report.Sets[idx].Files = append(report.Sets[idx].Files, i)
+		report.Sets[idx].Complete = report.Sets[idx].Complete && file.Complete
+	}
+}
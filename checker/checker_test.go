@@ -0,0 +1,173 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+func TestCheckAcrossServers(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastFile := len(nzb.Files) - 1
+	missing := nzb.Files[lastFile].Segments[0].ID
+
+	primary, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Missing: map[string]bool{missing: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+
+	fill, err := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fill.Close()
+
+	servers := []Server{
+		{Name: "primary", Config: primary.Config(), Connections: 2},
+		{Name: "fill", Config: fill.Config(), Connections: 1},
+	}
+	report, err := Check(context.Background(), nzb, servers, Options{PipelineDepth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete {
+		t.Fatal("expected the combined servers to cover every segment")
+	}
+	if report.Files[lastFile].PerServer[0] != report.Files[lastFile].Segments-1 {
+		t.Fatalf("unexpected primary coverage: %+v", report.Files[lastFile])
+	}
+	if primary.ConnectionCount() > 2 {
+		t.Fatalf("primary got %d connections", primary.ConnectionCount())
+	}
+
+	report, err = Check(context.Background(), nzb, servers[:1], Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete || report.Files[lastFile].Complete || report.Files[0].Complete == false {
+		t.Fatalf("unexpected completeness with the primary only: %+v", report.Files)
+	}
+	for _, set := range report.Sets {
+		if set.Complete {
+			t.Fatalf("set %q should be incomplete", set.Name)
+		}
+	}
+}
+
+func TestCheckSampling(t *testing.T) {
+	nzb, _ := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	server, err := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	total := 0
+	for _, f := range nzb.Files {
+		total += len(f.Segments)
+	}
+	report, err := Check(context.Background(), nzb, []Server{{Name: "only", Config: server.Config()}}, Options{SamplePercent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked >= total || report.Checked < len(nzb.Files) || !report.Complete {
+		t.Fatalf("unexpected sample: checked %d of %d", report.Checked, total)
+	}
+	if server.CommandCount("STAT") != report.Checked {
+		t.Fatalf("issued %d STAT commands for %d checked segments", server.CommandCount("STAT"), report.Checked)
+	}
+}
+
+func TestCheckServerGoneMidCheck(t *testing.T) {
+	nzb, _ := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	server, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Delay: 20 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Closing the server once checking is under way, so the reconnect fails too.
+	go func() {
+		for server.CommandCount("STAT") == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		server.Close()
+	}()
+	_, err = Check(context.Background(), nzb, []Server{{Name: "only", Config: server.Config(), Connections: 2}}, Options{PipelineDepth: 1})
+	if err == nil {
+		t.Fatal("expected an error once the server went away")
+	}
+}
+
+//Relays connections to a server, dropping the first one once it sends a STAT and refusing the one after that, so a single worker
+//loses its connection and cannot reconnect while the others keep working.
+func flakyProxy(t *testing.T, target nntp.Config) nntp.Config {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for accepted := 1; ; accepted++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if accepted == 3 {
+				conn.Close()
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				upstream, err := net.Dial("tcp", nntp.Address(target))
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go io.Copy(conn, upstream)
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || (accepted == 1 && strings.HasPrefix(line, "STAT")) {
+						return
+					}
+					upstream.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+
+	config := target
+	config.Port = listener.Addr().(*net.TCPAddr).Port
+	return config
+}
+
+func TestCheckRetriesBatchOfFailedConnection(t *testing.T) {
+	nzb, _ := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	server, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Delay: 2 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	config := flakyProxy(t, server.Config())
+	report, err := Check(context.Background(), nzb, []Server{{Name: "only", Config: config, Connections: 2}}, Options{PipelineDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete || report.Unknown != 0 {
+		t.Fatalf("the failed connection's batch was not retried: checked %d, available %d, unknown %d", report.Checked, report.Available, report.Unknown)
+	}
+}
@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//A Usenet server to check against. Connections caps the number of simultaneous connections opened to it, 1 if zero.
+type Server struct {
+	Name string
+	Config nntp.Config
+	Connections int
+}
+
+//Settings for a check.
+type Options struct {
+	//Percentage (0-100] of each file's segments to check. Every segment is checked if zero or 100; at least one segment per file
+	//is always checked.
+	SamplePercent float64
+	//Seed for choosing sampled segments, making sampling reproducible.
+	Seed int64
+	//Number of STAT commands pipelined per round trip, 16 if zero.
+	PipelineDepth int
+}
+
+//Availability of a segment on a single server.
+type Status int
+
+const (
+	//The segment was not checked, either because of sampling or because the server could not be reached.
+	Unknown Status = iota
+	Available
+	Missing
+)
+
+//A row of the availability matrix. Servers holds one status per server, in the order the servers were given.
+type SegmentStatus struct {
+	File int
+	Segment parser.Segment
+	Checked bool
+	Servers []Status
+}
+
+//Completeness of a single file. PerServer counts the checked segments each server has. Unknown counts the segments chosen for
+//checking that no server could answer for, as its connections failed; they are not counted as checked.
+type FileReport struct {
+	Name string
+	Segments int
+	Checked int
+	Available int
+	Unknown int
+	PerServer []int
+	Complete bool
+}
+
+//Completeness of a set of files sharing a parser.SetName, such as a rar set and its par2 files.
+type SetReport struct {
+	Name string
+	Files []int
+	Complete bool
+}
+
+//Result of a check. Complete is true when every checked segment is available on at least one server; in sampling mode it is
+//an estimate for the whole NZB.
+type Report struct {
+	Servers []string
+	Segments []SegmentStatus
+	Files []FileReport
+	Sets []SetReport
+	//Per server, the error that stopped it from being checked, or nil.
+	ServerErrors []error
+	Checked int
+	Available int
+	Unknown int
+	Complete bool
+}
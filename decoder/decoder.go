@@ -0,0 +1,177 @@
+// Allows for the detection and decoding of binary article bodies, covering yEnc as well as the UUencode used by older posts.
+package decoder
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"regexp"
+
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Errors returned while decoding UU data.
+var (
+	ErrNoBegin = errors.New("decoder: no uuencode begin line found")
+	ErrNotEncoded = errors.New("decoder: body is not yEnc or uuencoded")
+)
+
+//Precompiled regex for the "begin 644 name" line that opens a UU-encoded file.
+var uuBeginPattern = regexp.MustCompile(`^begin ([0-7]{3,4}) (.+?)\s*$`)
+
+//Checks whether a line is a well-formed UU data line: a length character followed by enough characters to carry it.
+func isUULine(line []byte) bool {
+	if len(line) == 0 {
+		return false
+	}
+	for _, c := range line {
+		if c < ' ' || c > '`' {
+			return false
+		}
+	}
+	count := int((line[0] - ' ') & 0x3f)
+	return len(line)-1 >= (count+2)/3*4
+}
+
+//Checks whether a line is exactly as long as its length character calls for, allowing one trailing pad character. Stricter than
+//isUULine, so a bare line can be told apart from text.
+func isExactUULine(line []byte) bool {
+	if !isUULine(line) {
+		return false
+	}
+	count := int((line[0] - ' ') & 0x3f)
+	expected := 1 + (count+2)/3*4
+	return count > 0 && (len(line) == expected || len(line) == expected+1)
+}
+
+//Checks whether a line ends UU data: the zero-length line ("`" or a space) or "end".
+func isUUTerminator(line []byte) bool {
+	return string(line) == "`" || string(line) == " " || string(line) == "end"
+}
+
+//Decodes a single UU data line, appending its bytes to data.
+func decodeUULine(data []byte, line []byte) []byte {
+	count := int((line[0] - ' ') & 0x3f)
+	line = line[1:]
+	for written := 0; written < count && len(line) >= 4; line = line[4:] {
+		var chars [4]byte
+		for i := range chars {
+			chars[i] = (line[i] - ' ') & 0x3f
+		}
+		decoded := [3]byte{
+			chars[0]<<2 | chars[1]>>4,
+			chars[1]<<4 | chars[2]>>2,
+			chars[2]<<6 | chars[3],
+		}
+		n := min(3, count-written)
+		data = append(data, decoded[:n]...)
+		written += n
+	}
+	return data
+}
+
+//Determines the encoding of an article body: yEnc if it has a =ybegin line, UU if it has a begin line or a bare UU data line (as
+//continuation parts of a multipart UU post do), none otherwise.
+func DetectEncoding(body []byte) Encoding {
+	//An exact line shorter than full length is only taken as UU data when the data ends right after it, as in the short last part of
+	//a multipart post.
+	pending := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+		if bytes.HasPrefix(line, []byte("=ybegin ")) {
+			return EncodingYEnc
+		}
+		if uuBeginPattern.Match(line) {
+			return EncodingUU
+		}
+
+		switch {
+		//Full-length UU lines always start with "M" (45 bytes).
+		case len(line) == 61 && line[0] == 'M' && isUULine(line):
+			return EncodingUU
+		case pending && isUUTerminator(line):
+			return EncodingUU
+		default:
+			pending = isExactUULine(line)
+		}
+	}
+	return EncodingNone
+}
+
+//Decodes UU data spread over one or more bodies given in order. The first body must contain the begin line; later ones may be bare
+//continuation parts. Lines that are not UU data (text, signatures, blank lines) are skipped. Decoding stops at the "end" line.
+func decodeUU(bodies [][]byte) (*Result, error) {
+	result := &Result{Encoding: EncodingUU}
+	begun := false
+
+	for _, body := range bodies {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+		for scanner.Scan() {
+			line := bytes.TrimRight(scanner.Bytes(), "\r")
+
+			if !begun {
+				if match := uuBeginPattern.FindSubmatch(line); match != nil {
+					result.Mode = string(match[1])
+					result.Name = string(match[2])
+					begun = true
+				}
+				continue
+			}
+
+			if bytes.Equal(line, []byte("end")) {
+				result.Complete = true
+				return result, nil
+			}
+			if isUULine(line) {
+				result.Data = decodeUULine(result.Data, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !begun {
+		return nil, ErrNoBegin
+	}
+	return result, nil
+}
+
+//Decodes a UU-encoded article body, returning the filename and mode from its begin line.
+func DecodeUU(body []byte) (*Result, error) {
+	return decodeUU([][]byte{body})
+}
+
+//Decodes a UU-encoded file split across several article bodies, given in part order.
+func DecodeUUParts(bodies [][]byte) (*Result, error) {
+	return decodeUU(bodies)
+}
+
+//Detects the encoding of an article body and decodes it. Bodies with no encoding are returned verbatim, with ErrNotEncoded,
+//so callers can tell plain text apart from binaries. Bare UU continuation parts yield ErrNoBegin; use DecodeUUParts for those.
+func DecodeBody(body []byte) (*Result, error) {
+	switch DetectEncoding(body) {
+	case EncodingYEnc:
+		part, err := yenc.Decode(body)
+		if part == nil {
+			return nil, err
+		}
+		return &Result{
+			Encoding: EncodingYEnc,
+			Name: part.Name,
+			Data: part.Data,
+			Complete: err != yenc.ErrNoTrailer,
+		}, err
+	case EncodingUU:
+		return DecodeUU(body)
+	default:
+		return &Result{
+			Encoding: EncodingNone,
+			Data: body,
+			Complete: true,
+		}, ErrNotEncoded
+	}
+}
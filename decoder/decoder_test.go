@@ -0,0 +1,110 @@
+package decoder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Minimal UU encoder used to build fixtures.
+func uuencode(data []byte) []string {
+	char := func(b byte) byte {
+		if b == 0 {
+			return '`'
+		}
+		return b + ' '
+	}
+
+	var lines []string
+	for len(data) > 0 {
+		n := min(45, len(data))
+		chunk := append([]byte{}, data[:n]...)
+		data = data[n:]
+		for len(chunk)%3 != 0 {
+			chunk = append(chunk, 0)
+		}
+
+		line := []byte{char(byte(n))}
+		for i := 0; i < len(chunk); i += 3 {
+			line = append(line,
+				char(chunk[i]>>2),
+				char((chunk[i]<<4|chunk[i+1]>>4)&0x3f),
+				char((chunk[i+1]<<2|chunk[i+2]>>6)&0x3f),
+				char(chunk[i+2]&0x3f),
+			)
+		}
+		lines = append(lines, string(line))
+	}
+	return append(lines, "`")
+}
+
+func TestDecodeUUMultipart(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	lines := uuencode(data)
+
+	first := "Some text\r\nbegin 644 old file.rar\r\n" + strings.Join(lines[:10], "\r\n") + "\r\n"
+	second := strings.Join(lines[10:], "\r\n") + "\r\nend\r\n-- \r\nsignature\r\n"
+
+	if enc := DetectEncoding([]byte(first)); enc != EncodingUU {
+		t.Fatalf("first part detected as %v", enc)
+	}
+	if enc := DetectEncoding([]byte(second)); enc != EncodingUU {
+		t.Fatalf("continuation part detected as %v", enc)
+	}
+
+	result, err := DecodeUUParts([][]byte{[]byte(first), []byte(second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != "old file.rar" || result.Mode != "644" || !result.Complete {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if !bytes.Equal(result.Data, data) {
+		t.Fatal("decoded data does not match the original")
+	}
+}
+
+func TestDecodeUUShortLastPart(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	lines := uuencode(data)
+
+	//Everything but the last, short data line goes into the first part.
+	first := "begin 600 short.bin\r\n" + strings.Join(lines[:len(lines)-2], "\r\n") + "\r\n"
+	last := strings.Join(lines[len(lines)-2:], "\r\n") + "\r\nend\r\n"
+
+	if enc := DetectEncoding([]byte(last)); enc != EncodingUU {
+		t.Fatalf("short last part detected as %v", enc)
+	}
+	if enc := DetectEncoding([]byte("#ABCD\r\nnot the end\r\n")); enc != EncodingNone {
+		t.Fatalf("text detected as %v", enc)
+	}
+
+	result, err := DecodeUUParts([][]byte{[]byte(first), []byte(last)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Complete || !bytes.Equal(result.Data, data) {
+		t.Fatalf("short last part was not decoded: %d bytes, complete %v", len(result.Data), result.Complete)
+	}
+}
+
+func TestDecodeBodyDispatch(t *testing.T) {
+	body := yenc.EncodePart(yenc.SplitParts("a.bin", []byte("payload"), 0)[0], 0)
+	result, err := DecodeBody(body)
+	if err != nil || result.Encoding != EncodingYEnc || string(result.Data) != "payload" {
+		t.Fatalf("unexpected yEnc result: %+v, %v", result, err)
+	}
+
+	result, err = DecodeBody([]byte("just some text\r\n"))
+	if err != ErrNotEncoded || result.Encoding != EncodingNone {
+		t.Fatalf("unexpected plain result: %+v, %v", result, err)
+	}
+}
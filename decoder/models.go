@@ -0,0 +1,32 @@
+package decoder
+
+//Binary encodings an article body may use.
+type Encoding int
+
+const (
+	EncodingNone Encoding = iota
+	EncodingYEnc
+	EncodingUU
+)
+
+//Returns the conventional name of an encoding.
+func (e Encoding) String() string {
+	switch e {
+	case EncodingYEnc:
+		return "yenc"
+	case EncodingUU:
+		return "uu"
+	default:
+		return "none"
+	}
+}
+
+//Decoded contents of one or more article bodies. Name and Mode come from the "begin" line of UU data or the =ybegin line of yEnc data.
+//Complete reports whether the end of the encoded file ("end" or =yend) was seen.
+type Result struct {
+	Encoding Encoding
+	Name string
+	Mode string
+	Data []byte
+	Complete bool
+}
@@ -0,0 +1,409 @@
+// Allows for downloading the files of an NZB from Usenet, assembling them from their segments and resuming interrupted jobs.
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/pool"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Errors returned by Download.
+var (
+	ErrNoServers = errors.New("downloader: no servers configured")
+	//Returned for a segment whose data failed its CRC check on every server that had it.
+	ErrDamaged = errors.New("downloader: segment failed its CRC check on every server")
+	//Returned for a part whose =ypart range cannot belong to its segment, so writing it would land outside the file.
+	ErrOutOfRange = errors.New("downloader: part lies outside its segment's range")
+)
+
+//A segment waiting to be fetched. Decoded data is never larger than the encoded articles, so the segments before it bound
+//where its data can start, and the whole file where it can end.
+type task struct {
+	file int
+	segment parser.Segment
+	posted time.Time
+	maxOffset int64
+	maxEnd int64
+}
+
+//State shared by the workers of a single Download call.
+type job struct {
+	nzb *parser.Nzb
+	options Options
+	pool *pool.Pool
+	journal *Journal
+
+	mu sync.Mutex
+	files []*os.File
+	result *Result
+	fileBytes []int64
+	fileTotals []int64
+	fileLeft []int
+	bytes int64
+	total int64
+}
+
+//Determines the name a file is saved under: its subject filename, or a numbered placeholder when none can be extracted.
+//Only the base name is kept, so subjects cannot write outside the job directory.
+func TargetName(file parser.File, idx int) string {
+	name := filepath.Base(parser.ExtractFilename(file))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = fmt.Sprintf("file-%d", idx+1)
+	}
+	return name
+}
+
+//Determines the names the files of an NZB are saved under, as TargetName does. Files whose names repeat, compared
+//case-insensitively, get a numbered suffix before the extension so they do not overwrite each other: "name (2).ext".
+func TargetNames(nzb *parser.Nzb) []string {
+	names := make([]string, len(nzb.Files))
+	taken := map[string]bool{}
+	for i, f := range nzb.Files {
+		name := TargetName(f, i)
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		taken[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+//Downloads every file of an NZB into options.Directory. Segments are fetched through a pool.Pool of the configured servers, decoded,
+//and written at the offset their =ypart header declares; parts declaring offsets their segment cannot have count as missing. Every intact segment is recorded in a journal once written, so calling
+//Download again after an interruption only fetches what is left, damaged segments included. Missing and damaged segments are reported in the result rather than as errors.
+func Download(ctx context.Context, nzb *parser.Nzb, options Options) (*Result, error) {
+	if options.Progress != nil {
+		defer close(options.Progress)
+	}
+	if len(options.Servers) == 0 && options.Pool == nil {
+		return nil, ErrNoServers
+	}
+	if options.Retries <= 0 {
+		options.Retries = 3
+	}
+	if err := os.MkdirAll(options.Directory, 0755); err != nil {
+		return nil, err
+	}
+
+	journal, err := OpenJournal(options.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &job{
+		nzb: nzb,
+		options: options,
+		journal: journal,
+		files: make([]*os.File, len(nzb.Files)),
+		result: &Result{Files: make([]FileResult, len(nzb.Files))},
+		fileBytes: make([]int64, len(nzb.Files)),
+		fileTotals: make([]int64, len(nzb.Files)),
+		fileLeft: make([]int, len(nzb.Files)),
+	}
+
+	//Backup servers map onto optional pool members; the given order sets priorities.
+	j.pool = options.Pool
+	if j.pool == nil {
+		var servers []pool.Server
+		for i, s := range options.Servers {
+			servers = append(servers, pool.Server{
+				Name: s.Name,
+				Config: s.Config,
+				MaxConnections: s.Connections,
+				Priority: i,
+				Optional: s.Backup,
+			})
+		}
+		j.pool = pool.New(servers, pool.Options{})
+		defer j.pool.Close()
+	}
+	workers := max(j.pool.Capacity(), 1)
+
+	var tasks []task
+	names := TargetNames(nzb)
+	for i, f := range nzb.Files {
+		name := names[i]
+		j.result.Files[i] = FileResult{
+			Name: name,
+			Path: filepath.Join(options.Directory, name),
+			Segments: len(f.Segments),
+		}
+		j.fileTotals[i] = int64(parser.FileSize(f))
+		j.total += j.fileTotals[i]
+
+		offsets := segmentOffsets(f)
+		for _, s := range f.Segments {
+			if journal.Done(s.ID) {
+				j.result.Resumed++
+				j.fileBytes[i] += int64(s.Bytes)
+				j.bytes += int64(s.Bytes)
+				continue
+			}
+			j.fileLeft[i]++
+			tasks = append(tasks, task{file: i, segment: s, posted: parser.DatePosted(f), maxOffset: offsets[s.Number], maxEnd: j.fileTotals[i]})
+		}
+	}
+
+	//Files the journal already holds entirely are reported done up front, as no segment of theirs will be.
+	for i := range nzb.Files {
+		if j.fileLeft[i] == 0 {
+			if err := j.send(ctx, j.progress(i)); err != nil {
+				journal.Close()
+				return j.result, err
+			}
+		}
+	}
+
+	queue := make(chan task)
+	go func() {
+		defer close(queue)
+		for _, t := range tasks {
+			select {
+			case queue <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				if err := j.process(ctx, t); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	//Draining in case every worker stopped early.
+	for range queue {
+	}
+
+	for _, f := range j.files {
+		if f != nil {
+			f.Close()
+		}
+	}
+
+	if err := errors.Join(append([]error{ctx.Err()}, errs...)...); err != nil {
+		journal.Close()
+		return j.result, err
+	}
+
+	complete := true
+	for i := range j.result.Files {
+		file := &j.result.Files[i]
+		file.Complete = len(file.Missing) == 0 && file.Damaged == 0
+		complete = complete && file.Complete
+	}
+	if complete {
+		journal.Remove()
+	} else {
+		journal.Close()
+	}
+	return j.result, nil
+}
+
+//Returns the encoded bytes of the segments numbered before each segment, by segment number.
+func segmentOffsets(file parser.File) map[int]int64 {
+	segments := append([]parser.Segment{}, file.Segments...)
+	sort.Slice(segments, func(a, b int) bool {
+		return segments[a].Number < segments[b].Number
+	})
+	offsets := map[int]int64{}
+	var offset int64
+	for _, s := range segments {
+		offsets[s.Number] = offset
+		offset += int64(s.Bytes)
+	}
+	return offsets
+}
+
+//Checks that a part's data lies where its segment can be. NZBs without segment sizes give no bounds, so only the start is checked.
+func (t task) inRange(part *yenc.Part) bool {
+	if part.Begin < 1 {
+		return false
+	}
+	if t.maxEnd <= 0 {
+		return true
+	}
+	return part.Begin-1 <= t.maxOffset && part.Begin-1+int64(len(part.Data)) <= t.maxEnd
+}
+
+//Fetches, decodes and writes a single segment. Only local failures, such as being unable to write, are returned.
+func (j *job) process(ctx context.Context, t task) error {
+	part, err := j.fetch(ctx, t)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	switch {
+	case err == nil, errors.Is(err, ErrDamaged):
+		if writeErr := j.write(t.file, part); writeErr != nil {
+			return writeErr
+		}
+		//Damaged segments are left out of the journal, so a resume fetches them again rather than taking them as done.
+		if err != nil {
+			j.mu.Lock()
+			j.result.Files[t.file].Damaged++
+			j.mu.Unlock()
+		} else if recordErr := j.journal.Record(t.segment.ID); recordErr != nil {
+			return recordErr
+		}
+	default:
+		j.mu.Lock()
+		j.result.Files[t.file].Missing = append(j.result.Files[t.file].Missing, t.segment.ID)
+		j.mu.Unlock()
+	}
+
+	return j.report(ctx, t)
+}
+
+//Retrieves and decodes a segment through the pool. A 430 or CRC failure moves on to the next server, backup servers included;
+//when every server failed for other reasons, the fetch is retried once the earliest server's backoff has passed.
+func (j *job) fetch(ctx context.Context, t task) (*yenc.Part, error) {
+	var (
+		part *yenc.Part
+		damaged *yenc.Part
+		err error
+	)
+
+	for attempt := 0; attempt < j.options.Retries; attempt++ {
+		_, err = j.pool.Do(ctx, t.posted, func(client *nntp.Client) error {
+			body, err := client.Body(t.segment.ID)
+			if err != nil {
+				return err
+			}
+			decoded, err := yenc.Decode(body)
+			if decoded != nil && !t.inRange(decoded) {
+				return fmt.Errorf("%w: %w", pool.ErrNextServer, ErrOutOfRange)
+			}
+			if err == nil {
+				part = decoded
+				return nil
+			}
+			if decoded != nil && (errors.Is(err, yenc.ErrCRCMismatch) || errors.Is(err, yenc.ErrSizeMismatch)) {
+				damaged = decoded
+			}
+			return fmt.Errorf("%w: %w", pool.ErrNextServer, err)
+		})
+
+		if err == nil {
+			return part, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		//Every server answered; asking again will not change anything.
+		if nntp.IsNoSuchArticle(err) || errors.Is(err, pool.ErrNextServer) {
+			break
+		}
+		if !j.waitForServers(ctx) {
+			return nil, ctx.Err()
+		}
+	}
+
+	if damaged != nil {
+		return damaged, ErrDamaged
+	}
+	return nil, err
+}
+
+//Waits until the earliest backing-off server may be retried, returning at once if a server is not backing off at all. Returns
+//false if ctx was cancelled meanwhile.
+func (j *job) waitForServers(ctx context.Context) bool {
+	var earliest time.Time
+	for i, h := range j.pool.Health() {
+		if h.RetryAt.IsZero() {
+			return ctx.Err() == nil
+		}
+		if i == 0 || h.RetryAt.Before(earliest) {
+			earliest = h.RetryAt
+		}
+	}
+
+	select {
+	case <-time.After(time.Until(earliest)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+//Writes a decoded part at its offset in the target file, syncing it so the journal never runs ahead of the data.
+func (j *job) write(idx int, part *yenc.Part) error {
+	j.mu.Lock()
+	file := j.files[idx]
+	if file == nil {
+		var err error
+		file, err = os.OpenFile(j.result.Files[idx].Path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			j.mu.Unlock()
+			return err
+		}
+		j.files[idx] = file
+	}
+	j.mu.Unlock()
+
+	if _, err := file.WriteAt(part.Data, part.Begin-1); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+//Updates the byte counters for a handled segment and sends a progress update.
+func (j *job) report(ctx context.Context, t task) error {
+	j.mu.Lock()
+	j.fileBytes[t.file] += int64(t.segment.Bytes)
+	j.bytes += int64(t.segment.Bytes)
+	j.fileLeft[t.file]--
+	update := j.progress(t.file)
+	j.mu.Unlock()
+	return j.send(ctx, update)
+}
+
+//Builds the progress update for a file. Callers hold j.mu when workers are running.
+func (j *job) progress(idx int) Progress {
+	return Progress{
+		File: idx,
+		Name: j.result.Files[idx].Name,
+		FileBytes: j.fileBytes[idx],
+		FileTotal: j.fileTotals[idx],
+		Bytes: j.bytes,
+		Total: j.total,
+		FileDone: j.fileLeft[idx] == 0,
+	}
+}
+
+//Sends a progress update, if progress is wanted.
+func (j *job) send(ctx context.Context, update Progress) error {
+	if j.options.Progress == nil {
+		return nil
+	}
+	select {
+	case j.options.Progress <- update:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,304 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/pool"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func TestDownloadWithBackupAndResume(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastFile := nzb.Files[len(nzb.Files)-1]
+	onBackup := map[string]bool{lastFile.Segments[1].ID: true, lastFile.Segments[2].ID: true}
+	everywhere := map[string]bool{lastFile.Segments[2].ID: true}
+
+	primary, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Missing: onBackup}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+
+	backup, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Missing: everywhere}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	dir := t.TempDir()
+	progress := make(chan Progress)
+	var updates []Progress
+	done := make(chan struct{})
+	go func() {
+		for p := range progress {
+			updates = append(updates, p)
+		}
+		close(done)
+	}()
+
+	result, err := Download(context.Background(), nzb, Options{
+		Directory: dir,
+		Servers: []Server{
+			{Name: "primary", Config: primary.Config(), Connections: 3},
+			{Name: "backup", Config: backup.Config(), Backup: true},
+		},
+		Progress: progress,
+	})
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := result.Files[len(result.Files)-1]
+	if last.Complete || len(last.Missing) != 1 || last.Missing[0] != lastFile.Segments[2].ID {
+		t.Fatalf("unexpected result for the last file: %+v", last)
+	}
+	if backup.CommandCount("BODY") != 2 {
+		t.Fatalf("backup served %d bodies, expected only the two missing on primary", backup.CommandCount("BODY"))
+	}
+	if len(updates) != countSegments(nzb) || updates[len(updates)-1].Bytes != int64(parser.Size(nzb)) {
+		t.Fatalf("unexpected progress updates: %d", len(updates))
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalName)); err != nil {
+		t.Fatal("expected the journal to be kept for an incomplete job")
+	}
+
+	//Resuming against a complete server only fetches the segment that is still missing.
+	full, err := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer full.Close()
+
+	progress = make(chan Progress)
+	finished := map[int]bool{}
+	done = make(chan struct{})
+	go func() {
+		for p := range progress {
+			if p.FileDone {
+				finished[p.File] = true
+			}
+		}
+		close(done)
+	}()
+	result, err = Download(context.Background(), nzb, Options{
+		Directory: dir,
+		Servers: []Server{{Name: "full", Config: full.Config(), Connections: 2}},
+		Progress: progress,
+	})
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.CommandCount("BODY") != 1 || result.Resumed != countSegments(nzb)-1 {
+		t.Fatalf("resume fetched %d bodies and skipped %d", full.CommandCount("BODY"), result.Resumed)
+	}
+	//Files the journal already held are reported done too.
+	if len(finished) != len(nzb.Files) {
+		t.Fatalf("only files %v were reported done", finished)
+	}
+
+	for i, f := range nzb.Files {
+		data, err := os.ReadFile(result.Files[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, nntptest.FillerData(parser.ExtractFilename(f), parser.FileSize(f))) {
+			t.Fatalf("%s was not assembled correctly", result.Files[i].Name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalName)); !os.IsNotExist(err) {
+		t.Fatal("expected the journal to be removed once the job completed")
+	}
+}
+
+func countSegments(nzb *parser.Nzb) int {
+	count := 0
+	for _, f := range nzb.Files {
+		count += len(f.Segments)
+	}
+	return count
+}
+
+func TestResumeRetriesDamaged(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := nzb.Files[0].Segments[0].ID
+	corrupt, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{CorruptCRC: map[string]bool{damaged: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer corrupt.Close()
+
+	dir := t.TempDir()
+	options := Options{Directory: dir, Servers: []Server{{Name: "corrupt", Config: corrupt.Config(), Connections: 2}}}
+	for run := 1; run <= 2; run++ {
+		result, err := Download(context.Background(), nzb, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Files[0].Damaged != 1 || result.Files[0].Complete {
+			t.Fatalf("run %d: damage was not reported: %+v", run, result.Files[0])
+		}
+		if _, err := os.Stat(filepath.Join(dir, JournalName)); err != nil {
+			t.Fatalf("run %d: expected the journal to be kept for a damaged job", run)
+		}
+	}
+	if corrupt.CommandCount("BODY") != countSegments(nzb)+1 {
+		t.Fatalf("resume fetched %d bodies, expected only the damaged one again", corrupt.CommandCount("BODY")-countSegments(nzb))
+	}
+
+	//An intact copy finally completes the job.
+	full, err := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer full.Close()
+	options.Servers = []Server{{Name: "full", Config: full.Config()}}
+	result, err := Download(context.Background(), nzb, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(result.Files[0].Path)
+	if !result.Files[0].Complete || full.CommandCount("BODY") != 1 ||
+		!bytes.Equal(data, nntptest.FillerData(parser.ExtractFilename(nzb.Files[0]), parser.FileSize(nzb.Files[0]))) {
+		t.Fatalf("damaged segment was not replaced: %+v", result.Files[0])
+	}
+}
+
+func TestDownloadRejectsOutOfRangeParts(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	//A part claiming to start a terabyte into its file.
+	last := len(nzb.Files) - 1
+	file := nzb.Files[last]
+	part := yenc.SplitParts(parser.ExtractFilename(file), []byte("far away"), 0)[0]
+	part.Number, part.Total = 2, len(file.Segments)
+	part.Begin, part.End = 1<<40, 1<<40+7
+	server.AddArticle(file.Segments[1].ID, nil, yenc.EncodePart(part, 0))
+
+	result, err := Download(context.Background(), nzb, Options{Directory: t.TempDir(), Servers: []Server{{Name: "bad", Config: server.Config()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Files[last]; got.Complete || len(got.Missing) != 1 || got.Missing[0] != file.Segments[1].ID {
+		t.Fatalf("out of range part was not rejected: %+v", got)
+	}
+	if info, err := os.Stat(result.Files[last].Path); err != nil || info.Size() > int64(parser.FileSize(file)) {
+		t.Fatalf("file grew past its declared size: %v, %v", info.Size(), err)
+	}
+}
+
+func TestDamagedSegmentFetchedFromBackup(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := nzb.Files[0].Segments[0].ID
+	primary, err := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{CorruptCRC: map[string]bool{damaged: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	backup, err := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	result, err := Download(context.Background(), nzb, Options{
+		Directory: t.TempDir(),
+		Servers: []Server{
+			{Name: "primary", Config: primary.Config(), Connections: 2},
+			{Name: "backup", Config: backup.Config(), Backup: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Files[0].Complete || backup.CommandCount("BODY") != 1 {
+		t.Fatalf("backup served %d bodies for a damaged segment: %+v", backup.CommandCount("BODY"), result.Files[0])
+	}
+}
+
+func TestDuplicateNamesGetSuffixes(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := nzb.Files[0]
+	nzb.Files = []parser.File{first, first, first}
+	nzb.Files[1].Subject = strings.ToUpper(first.Subject)
+	server, err := nntptest.NewServer(&parser.Nzb{Files: nzb.Files[:1]}, nil, nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	result, err := Download(context.Background(), nzb, Options{Directory: t.TempDir(), Servers: []Server{{Name: "full", Config: server.Config()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := parser.ExtractFilename(first)
+	ext := filepath.Ext(name)
+	expected := []string{name, strings.TrimSuffix(strings.ToUpper(name), strings.ToUpper(ext)) + " (2)" + strings.ToUpper(ext), strings.TrimSuffix(name, ext) + " (3)" + ext}
+	for i, f := range result.Files {
+		data, err := os.ReadFile(f.Path)
+		if f.Name != expected[i] || err != nil || len(data) == 0 {
+			t.Fatalf("file %d saved as %q, expected %q: %v", i, f.Name, expected[i], err)
+		}
+	}
+}
+
+func TestWaitForServersReturnsWhileOneIsUsable(t *testing.T) {
+	good, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer good.Close()
+	gone, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone.Close()
+
+	//The unreachable server is tried first and backs off for an hour; the reachable one never failed.
+	p := pool.New([]pool.Server{
+		{Name: "good", Config: good.Config(), Priority: 1},
+		{Name: "gone", Config: gone.Config(), Priority: 0},
+	}, pool.Options{BackoffBase: time.Hour})
+	defer p.Close()
+	if _, err := p.Do(context.Background(), time.Time{}, func(client *nntp.Client) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	j := &job{pool: p}
+	if !j.waitForServers(ctx) || time.Since(start) > time.Second {
+		t.Fatalf("waited %v although a server was usable", time.Since(start))
+	}
+}
@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+//Name of the journal file kept in a job's directory.
+const JournalName = ".nzbgo.journal"
+
+//Opens the journal in dir, loading the segments already recorded in it. A torn last line from a crash is ignored.
+func OpenJournal(dir string) (*Journal, error) {
+	path := filepath.Join(dir, JournalName)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	journal := &Journal{done: map[string]bool{}}
+	//Only newline-terminated lines were fully written.
+	if idx := bytes.LastIndexByte(data, '\n'); idx >= 0 {
+		scanner := bufio.NewScanner(bytes.NewReader(data[:idx+1]))
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				journal.done[line] = true
+			}
+		}
+		data = data[:idx+1]
+	} else {
+		data = nil
+	}
+
+	//Rewriting without the torn line, so new entries start on a fresh line.
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	journal.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+//Checks whether a segment was recorded as done.
+func (j *Journal) Done(messageID string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done[messageID]
+}
+
+//Durably records a segment as done. Callers must have synced the segment's data beforehand.
+func (j *Journal) Record(messageID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.WriteString(messageID + "\n"); err != nil {
+		return err
+	}
+	j.done[messageID] = true
+	return j.file.Sync()
+}
+
+//Closes the journal file.
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+//Closes and deletes the journal, once a job no longer needs resuming.
+func (j *Journal) Remove() error {
+	j.Close()
+	return os.Remove(j.file.Name())
+}
@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"os"
+	"sync"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/pool"
+)
+
+//A server to download from. Backup servers are only asked for articles the other servers answered with 430 or served damaged.
+//Connections caps the number of simultaneous connections opened to it, 1 if zero.
+type Server struct {
+	Name string
+	Config nntp.Config
+	Connections int
+	Backup bool
+}
+
+//Settings for a download job.
+type Options struct {
+	//Directory files and the journal are written to. Created if missing.
+	Directory string
+	//Servers in order of preference; non-backup servers are tried first.
+	Servers []Server
+	//Pool to fetch through instead of Servers, for callers sharing one pool between jobs or needing priority tiers and retention.
+	Pool *pool.Pool
+	//Receives progress updates and is closed when Download returns. Updates are sent blocking, so it must be drained.
+	Progress chan<- Progress
+	//Attempts for a segment when servers fail for reasons other than the article missing, 3 if zero.
+	Retries int
+}
+
+//A progress update. Byte counts are in encoded article bytes, as given by parser.Segment.Bytes, so totals are known up front.
+type Progress struct {
+	File int
+	Name string
+	FileBytes int64
+	FileTotal int64
+	Bytes int64
+	Total int64
+	//Set once every segment of the file has been handled.
+	FileDone bool
+}
+
+//Outcome for a single file. Missing lists the message-IDs no server had; Damaged counts segments that were written despite failing
+//their CRC check on every server, leaving them for par2 to repair.
+type FileResult struct {
+	Name string
+	Path string
+	Segments int
+	Missing []string
+	Damaged int
+	Complete bool
+}
+
+//Outcome of a download job.
+type Result struct {
+	Files []FileResult
+	//Segments skipped because the journal recorded them as done.
+	Resumed int
+}
+
+//Append-only record of completed segments, used to resume interrupted jobs.
+type Journal struct {
+	file *os.File
+	mu sync.Mutex
+	done map[string]bool
+}
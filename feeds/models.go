@@ -0,0 +1,110 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/newznab"
+)
+
+//An RSS or Atom feed of NZBs.
+type Feed struct {
+	Name string
+	URL string
+}
+
+//Conditions an item must meet to be grabbed. Every set condition has to hold.
+type Rule struct {
+	Name string
+	//The title must match at least one Include pattern, if any are given, and none of the Exclude patterns.
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+	//Size limits in bytes. Items of unknown size fail a rule that sets any limit.
+	MinSize int64
+	MaxSize int64
+	//Newznab categories the item must be in; a parent category such as 5000 covers its subcategories.
+	Categories []int
+	//Items published longer ago than this are ignored. Items without a date pass.
+	MaxAge time.Duration
+	//Release-name fields the title must carry, such as {"resolution": "1080p"}; see release.Info.Field. Compared case-insensitively.
+	Fields map[string]string
+	//Names of the feeds the rule applies to; all feeds if empty.
+	Feeds []string
+}
+
+//Settings for a feed watcher.
+type Options struct {
+	Feeds []Feed
+	//An item is grabbed by the first rule it matches.
+	Rules []Rule
+	//Directory grabbed NZBs are saved to.
+	Directory string
+	//File remembering the GUIDs already handled, so restarts do not grab items again.
+	StateFile string
+	//Time between polls, 15 minutes if zero.
+	Interval time.Duration
+	//Used for every request; http.DefaultClient if nil.
+	HTTP *http.Client
+	//Number of polls an item whose NZB fails to download is tried on before it is given up, 5 if zero.
+	Attempts int
+	//Receives every grab attempt when set. Grabs are sent blocking, so it must be drained.
+	Grabs chan<- Grab
+}
+
+//An attempt to grab a matching item. Path is where the NZB was saved; Err is set if it could not be downloaded or did not parse.
+type Grab struct {
+	Feed string
+	Rule string
+	Item newznab.Item
+	Path string
+	Err error
+}
+
+//Contents of the state file: when each GUID was first seen.
+type state struct {
+	Seen map[string]time.Time `json:"seen"`
+}
+
+//Polls feeds and grabs the items matching its rules.
+type Watcher struct {
+	options Options
+	seen map[string]time.Time
+	//Download failures per item not yet seen, which are forgotten on restart.
+	failures map[string]int
+	now func() time.Time
+}
+
+//Atom documents, which some indexers publish instead of RSS.
+type atomFeed struct {
+	XMLName xml.Name `xml:"feed"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	ID string `xml:"id"`
+	Links []atomLink `xml:"link"`
+	Updated string `xml:"updated"`
+	Published string `xml:"published"`
+	Categories []atomCategory `xml:"category"`
+	Summary string `xml:"summary"`
+	Attrs []atomAttr `xml:"attr"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel string `xml:"rel,attr"`
+	Length int64 `xml:"length,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomAttr struct {
+	Name string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
@@ -0,0 +1,405 @@
+// Allows for watching indexer RSS and Atom feeds, grabbing the NZBs of items that match user rules.
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/newznab"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/release"
+)
+
+//How long GUIDs are remembered. Feeds only list recent items, so older entries are dropped from the state file.
+const seenRetention = 90 * 24 * time.Hour
+
+//Largest feed or NZB downloaded, and largest NZB once decompressed.
+const maxDownload = 64 << 20
+
+//Reported for grabbed NZBs that parse but list no files.
+var ErrEmpty = errors.New("feeds: NZB contains no files")
+
+//Reported for feeds and NZBs larger than the download limit.
+var ErrTooLarge = errors.New("feeds: download exceeds the size limit")
+
+//Marks failures to save the state file, which Run stops on rather than risk grabbing the same items again after a restart.
+var errState = errors.New("feeds: saving state")
+
+//Creates a watcher, loading the GUIDs recorded in the state file if it exists.
+func New(options Options) (*Watcher, error) {
+	if options.Interval <= 0 {
+		options.Interval = 15 * time.Minute
+	}
+	if options.HTTP == nil {
+		options.HTTP = http.DefaultClient
+	}
+	if options.Attempts <= 0 {
+		options.Attempts = 5
+	}
+	watcher := &Watcher{options: options, seen: map[string]time.Time{}, failures: map[string]int{}, now: time.Now}
+
+	if options.StateFile != "" {
+		data, err := os.ReadFile(options.StateFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(data) > 0 {
+			var saved state
+			if err := json.Unmarshal(data, &saved); err != nil {
+				return nil, fmt.Errorf("feeds: reading state file: %w", err)
+			}
+			for guid, seen := range saved.Seen {
+				watcher.seen[guid] = seen
+			}
+		}
+	}
+	return watcher, nil
+}
+
+//Polls until ctx is cancelled. Feed errors do not stop it; they are retried on the next poll.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(ctx); ctx.Err() != nil {
+			return nil
+		} else if errors.Is(err, errState) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//Reads every feed once and grabs the new items matching a rule. Items that fail to download are retried on the next polls, up to
+//Options.Attempts times; items whose NZB does not parse are not. Returns the grab attempts, and the errors of feeds that could not be read.
+func (w *Watcher) Poll(ctx context.Context) ([]Grab, error) {
+	var (
+		grabs []Grab
+		errs []error
+	)
+	for _, feed := range w.options.Feeds {
+		items, err := w.fetchFeed(ctx, feed)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("feeds: %s: %w", feed.Name, err))
+			continue
+		}
+
+		for _, item := range items {
+			key := item.GUID
+			if key == "" {
+				key = item.Link
+			}
+			if _, seen := w.seen[key]; seen || key == "" {
+				continue
+			}
+
+			rule := w.match(feed, item)
+			if rule == nil {
+				w.seen[key] = w.now()
+				continue
+			}
+
+			grab := Grab{Feed: feed.Name, Rule: rule.Name, Item: item}
+			grab.Path, grab.Err = w.grab(ctx, item)
+			//Download failures are worth a few more tries; an NZB that does not parse will not get better.
+			var invalid *invalidError
+			if grab.Err != nil && !errors.As(grab.Err, &invalid) {
+				w.failures[key]++
+			}
+			if grab.Err == nil || errors.As(grab.Err, &invalid) || w.failures[key] >= w.options.Attempts {
+				w.seen[key] = w.now()
+				delete(w.failures, key)
+			}
+			grabs = append(grabs, grab)
+			if w.options.Grabs != nil {
+				select {
+				case w.options.Grabs <- grab:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}
+
+	if err := w.save(); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", errState, err))
+	}
+	return grabs, errors.Join(errs...)
+}
+
+//Downloads and parses a feed.
+func (w *Watcher) fetchFeed(ctx context.Context, feed Feed) ([]newznab.Item, error) {
+	data, err := w.download(ctx, feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFeed(data)
+}
+
+//Parses an RSS or Atom feed, including newznab attributes.
+func ParseFeed(data []byte) ([]newznab.Item, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("feeds: not an RSS or Atom feed: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "rss":
+			results, err := newznab.ParseResults(data)
+			if err != nil {
+				return nil, err
+			}
+			return results.Items, nil
+		case "feed":
+			return parseAtom(data)
+		default:
+			return nil, fmt.Errorf("feeds: unexpected root element <%s>", start.Name.Local)
+		}
+	}
+}
+
+//Parses an Atom feed. The enclosure link is taken as the NZB's URL, falling back to the first link.
+func parseAtom(data []byte) ([]newznab.Item, error) {
+	var document atomFeed
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	items := make([]newznab.Item, 0, len(document.Entries))
+	for _, entry := range document.Entries {
+		item := newznab.Item{
+			Title: strings.TrimSpace(entry.Title),
+			GUID: strings.TrimSpace(entry.ID),
+			Description: entry.Summary,
+		}
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" || item.Link == "" {
+				item.Link = link.Href
+				item.Size = max(item.Size, link.Length)
+			}
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		item.Published, _ = time.Parse(time.RFC3339, strings.TrimSpace(published))
+		for _, a := range entry.Attrs {
+			item.SetAttr(a.Name, a.Value)
+		}
+		//Categories are given as a numeric term, a label such as "Movies > HD", or both; newznab attributes may repeat them.
+		for i, category := range entry.Categories {
+			if i == 0 {
+				item.Category = category.Label
+				if item.Category == "" {
+					item.Category = category.Term
+				}
+			}
+			id, ok := newznab.CategoryID(category.Term)
+			if !ok {
+				id, ok = newznab.CategoryID(category.Label)
+			}
+			if ok && !slices.Contains(item.Categories, id) {
+				item.Categories = append(item.Categories, id)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+//Returns the first rule an item from feed matches, or nil.
+func (w *Watcher) match(feed Feed, item newznab.Item) *Rule {
+	for i := range w.options.Rules {
+		if w.options.Rules[i].Matches(feed.Name, item, w.now()) {
+			return &w.options.Rules[i]
+		}
+	}
+	return nil
+}
+
+//Checks whether an item from the named feed meets every condition of the rule, as of now.
+func (r *Rule) Matches(feed string, item newznab.Item, now time.Time) bool {
+	if len(r.Feeds) > 0 && !slices.Contains(r.Feeds, feed) {
+		return false
+	}
+
+	if len(r.Include) > 0 && !slices.ContainsFunc(r.Include, func(p *regexp.Regexp) bool { return p.MatchString(item.Title) }) {
+		return false
+	}
+	if slices.ContainsFunc(r.Exclude, func(p *regexp.Regexp) bool { return p.MatchString(item.Title) }) {
+		return false
+	}
+
+	if (r.MinSize > 0 || r.MaxSize > 0) && item.Size <= 0 {
+		return false
+	}
+	if (r.MinSize > 0 && item.Size < r.MinSize) || (r.MaxSize > 0 && item.Size > r.MaxSize) {
+		return false
+	}
+
+	if len(r.Categories) > 0 && !slices.ContainsFunc(item.Categories, func(id int) bool {
+		return slices.ContainsFunc(r.Categories, func(wanted int) bool {
+			return id == wanted || (wanted%1000 == 0 && id/1000 == wanted/1000)
+		})
+	}) {
+		return false
+	}
+
+	if r.MaxAge > 0 && !item.Published.IsZero() && now.Sub(item.Published) > r.MaxAge {
+		return false
+	}
+
+	if len(r.Fields) > 0 {
+		info := release.Parse(item.Title)
+		for field, value := range r.Fields {
+			if !strings.EqualFold(info.Field(field), value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//Marks NZBs that were downloaded but did not parse.
+type invalidError struct {
+	err error
+}
+
+func (e *invalidError) Error() string {
+	return fmt.Sprintf("feeds: invalid NZB: %v", e.err)
+}
+
+func (e *invalidError) Unwrap() error {
+	return e.err
+}
+
+//Downloads an item's NZB and saves it once it parses. Returns where it was saved.
+func (w *Watcher) grab(ctx context.Context, item newznab.Item) (string, error) {
+	data, err := w.download(ctx, item.Link)
+	if errors.Is(err, ErrTooLarge) {
+		return "", &invalidError{err: err}
+	}
+	if err != nil {
+		return "", err
+	}
+	//Indexers may serve NZBs gzipped, which are saved decompressed.
+	if data, err = parser.Decompress(data, maxDownload); err != nil {
+		return "", &invalidError{err: err}
+	}
+	nzb, err := parser.FromStr(string(data))
+	if err == nil && len(nzb.Files) == 0 {
+		err = ErrEmpty
+	}
+	if err != nil {
+		return "", &invalidError{err: err}
+	}
+
+	if err := os.MkdirAll(w.options.Directory, 0755); err != nil {
+		return "", err
+	}
+	path := uniquePath(w.options.Directory, safeName(item.Title))
+	return path, writeAtomic(path, data)
+}
+
+//Fetches a URL, failing on non-200 responses and with ErrTooLarge on bodies over maxDownload.
+func (w *Watcher) download(ctx context.Context, target string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := w.options.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feeds: unexpected HTTP status %s", response.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownload {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+//Writes the seen GUIDs to the state file, forgetting ones past the retention.
+func (w *Watcher) save() error {
+	for guid, seen := range w.seen {
+		if w.now().Sub(seen) > seenRetention {
+			delete(w.seen, guid)
+		}
+	}
+	if w.options.StateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state{Seen: w.seen}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(w.options.StateFile, data)
+}
+
+//Turns an item title into an NZB filename.
+func safeName(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if strings.Trim(title, ".") == "" {
+		title = "untitled"
+	}
+	return title
+}
+
+//Returns a path for name.nzb in dir that is not taken yet.
+func uniquePath(dir string, name string) string {
+	candidate := filepath.Join(dir, name+".nzb")
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s.%d.nzb", name, i))
+	}
+}
+
+//Writes a file through a temporary file and a rename, so readers never see it half-written.
+func writeAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".feeds-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,244 @@
+package feeds
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const rssDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/">
+<channel>
+%s
+</channel>
+</rss>`
+
+const rssItem = `<item>
+  <title>%s</title>
+  <guid>%s</guid>
+  <link>%s</link>
+  <pubDate>%s</pubDate>
+  <newznab:attr name="category" value="5000"/>
+  <newznab:attr name="category" value="5040"/>
+  <newznab:attr name="size" value="%d"/>
+</item>`
+
+const atomDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/">
+  <entry>
+    <title>Movie.Title.2020.1080p.BluRay-GRP</title>
+    <id>urn:movie:1</id>
+    <link rel="alternate" href="%[1]s/details/movie1"/>
+    <link rel="enclosure" href="%[1]s/nzb/good" length="8000000000"/>
+    <updated>%[2]s</updated>
+    <category term="2040" label="Movies > HD"/>
+    <newznab:attr name="category" value="2040"/>
+  </entry>
+  <entry>
+    <title>Movie.Title.1990.1080p.BluRay-GRP</title>
+    <id>urn:movie:2</id>
+    <link rel="enclosure" href="%[1]s/nzb/good"/>
+    <updated>2001-01-01T00:00:00Z</updated>
+    <newznab:attr name="category" value="2040"/>
+  </entry>
+</feed>`
+
+func startFeeds(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	sample, err := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flaky atomic.Int32
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		date := time.Now().Add(-time.Hour).Format(time.RFC1123Z)
+		items := []string{
+			fmt.Sprintf(rssItem, "Show.Name.S01E01.1080p.WEB-DL-GRP", "tv1", server.URL+"/nzb/good", date, 2<<30),
+			fmt.Sprintf(rssItem, "Show.Name.S01E01.720p.HDTV-GRP", "tv2", server.URL+"/nzb/good", date, 1<<30),
+			fmt.Sprintf(rssItem, "Show.Name.S01E02.1080p.WEB-DL-GRP", "tv3", server.URL+"/nzb/invalid", date, 2<<30),
+			fmt.Sprintf(rssItem, "Show.Name.S01E03.1080p.WEB-DL-GRP", "tv4", server.URL+"/nzb/flaky", date, 2<<30),
+			fmt.Sprintf(rssItem, "Show.Name.S01E04.1080p.WEB-DL-GRP", "tv5", server.URL+"/nzb/good", date, 9<<30),
+		}
+		fmt.Fprintf(w, rssDocument, strings.Join(items, "\n"))
+	})
+	mux.HandleFunc("/atom", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, atomDocument, server.URL, time.Now().Add(-time.Hour).Format(time.RFC3339))
+	})
+	mux.HandleFunc("/nzb/good", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sample)
+	})
+	mux.HandleFunc("/nzb/invalid", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>rate limited</html>"))
+	})
+	mux.HandleFunc("/nzb/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if flaky.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(sample)
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &flaky
+}
+
+func TestWatcherGrabsMatchingItems(t *testing.T) {
+	server, flaky := startFeeds(t)
+	dir := t.TempDir()
+	options := Options{
+		Feeds: []Feed{{Name: "tv", URL: server.URL + "/rss"}, {Name: "movies", URL: server.URL + "/atom"}},
+		Rules: []Rule{
+			{
+				Name: "show",
+				Include: []*regexp.Regexp{regexp.MustCompile(`(?i)^show\.name\.`)},
+				Exclude: []*regexp.Regexp{regexp.MustCompile(`(?i)\.hdtv\.`)},
+				Fields: map[string]string{"resolution": "1080P"},
+				Categories: []int{5000},
+				MaxSize: 5 << 30,
+				Feeds: []string{"tv"},
+			},
+			{Name: "movies", Categories: []int{2000}, MaxAge: 48 * time.Hour, Fields: map[string]string{"source": "bluray"}},
+		},
+		Directory: filepath.Join(dir, "grabbed"),
+		StateFile: filepath.Join(dir, "state.json"),
+	}
+	watcher, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grabs, err := watcher.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := map[string]error{}
+	for _, g := range grabs {
+		outcomes[g.Item.Title] = g.Err
+	}
+	if len(grabs) != 4 {
+		t.Fatalf("expected 4 grab attempts, got %+v", grabs)
+	}
+	if outcomes["Show.Name.S01E01.1080p.WEB-DL-GRP"] != nil || outcomes["Movie.Title.2020.1080p.BluRay-GRP"] != nil {
+		t.Fatalf("matching items were not grabbed: %v", outcomes)
+	}
+	if err := outcomes["Show.Name.S01E02.1080p.WEB-DL-GRP"]; err == nil || !strings.Contains(err.Error(), "invalid NZB") {
+		t.Fatalf("invalid NZB was not rejected: %v", err)
+	}
+	if outcomes["Show.Name.S01E03.1080p.WEB-DL-GRP"] == nil {
+		t.Fatal("failed download was reported as grabbed")
+	}
+	if _, err := os.Stat(filepath.Join(options.Directory, "Movie.Title.2020.1080p.BluRay-GRP.nzb")); err != nil {
+		t.Fatal(err)
+	}
+
+	//A new watcher reading the same state only retries the failed download.
+	watcher, err = New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grabs, err = watcher.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grabs) != 1 || grabs[0].Item.GUID != "tv4" || grabs[0].Err != nil || flaky.Load() != 2 {
+		t.Fatalf("unexpected retry: %+v", grabs)
+	}
+	if grabs, _ := watcher.Poll(context.Background()); len(grabs) != 0 {
+		t.Fatalf("seen items were grabbed again: %+v", grabs)
+	}
+	entries, _ := os.ReadDir(options.Directory)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 saved NZBs, got %v", entries)
+	}
+}
+
+func TestPollReportsFeedErrors(t *testing.T) {
+	server, _ := startFeeds(t)
+	watcher, err := New(Options{Feeds: []Feed{{Name: "broken", URL: server.URL + "/nzb/invalid"}, {Name: "missing", URL: server.URL + "/none"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = watcher.Poll(context.Background())
+	if err == nil || errors.Is(err, errState) || !strings.Contains(err.Error(), "broken") || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected both feeds to fail, got %v", err)
+	}
+}
+
+func TestParseAtomCategories(t *testing.T) {
+	document := `<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><title>Numeric</title><id>1</id><category term="2040"/></entry>
+  <entry><title>Labelled</title><id>2</id><category term="tv-hd" label="TV > HD"/><category term="5000"/></entry>
+  <entry><title>Unknown</title><id>3</id><category term="whatever"/></entry>
+</feed>`
+	items, err := ParseFeed([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || fmt.Sprint(items[0].Categories) != "[2040]" || fmt.Sprint(items[1].Categories) != "[5040 5000]" || len(items[2].Categories) != 0 {
+		t.Fatalf("unexpected categories: %+v", items)
+	}
+	if items[1].Category != "TV > HD" {
+		t.Fatalf("unexpected category label %q", items[1].Category)
+	}
+}
+
+func TestPollGivesUpAndDecompresses(t *testing.T) {
+	sample, err := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(sample)
+	writer.Close()
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		date := time.Now().Format(time.RFC1123Z)
+		fmt.Fprintf(w, rssDocument, fmt.Sprintf(rssItem, "Down", "down", server.URL+"/nzb/down", date, 1)+
+			fmt.Sprintf(rssItem, "Gzipped", "gzipped", server.URL+"/nzb/gzipped", date, 1))
+	})
+	mux.HandleFunc("/nzb/down", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone for now", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/nzb/gzipped", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(compressed.Bytes())
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	watcher, err := New(Options{Feeds: []Feed{{Name: "all", URL: server.URL + "/rss"}}, Rules: []Rule{{Name: "any"}}, Directory: dir, Attempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grabs, _ := watcher.Poll(context.Background())
+	if len(grabs) != 2 || grabs[0].Err == nil || grabs[1].Err != nil {
+		t.Fatalf("unexpected first poll: %+v", grabs)
+	}
+	saved, err := os.ReadFile(grabs[1].Path)
+	if err != nil || !bytes.Equal(saved, sample) {
+		t.Fatalf("gzipped NZB was not saved decompressed: %v", err)
+	}
+
+	if grabs, _ := watcher.Poll(context.Background()); len(grabs) != 1 || grabs[0].Item.GUID != "down" {
+		t.Fatalf("failed download was not retried: %+v", grabs)
+	}
+	if grabs, _ := watcher.Poll(context.Background()); len(grabs) != 0 {
+		t.Fatalf("failed download was retried past the attempts: %+v", grabs)
+	}
+}
@@ -0,0 +1,273 @@
+// Allows for building NZBs from NNTP overview data by grouping article subjects into files and files into releases, as indexers do.
+package grouping
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Compiled regex for the (n/m) and [n/m] counters in binary subjects.
+var COUNTER_PATTERN = *regexp.MustCompile(`[\[(](\d+)/(\d+)[\])]`)
+
+//Compiled regex for the yEnc marker that precedes the part counter, at the end of the text before it.
+var YENC_PATTERN = *regexp.MustCompile(`(?i)\byEnc\s*$`)
+
+//Reads the overview of a newsgroup over an established connection and groups it. A high of zero reads to the newest article.
+func FromGroup(client *nntp.Client, group string, low int64, high int64) ([]Release, error) {
+	info, err := client.Group(group)
+	if err != nil {
+		return nil, err
+	}
+	low = max(low, info.Low)
+	overviews, err := client.Over(low, high)
+	if err != nil {
+		return nil, err
+	}
+	return Group(overviews, Options{Groups: []string{group}}), nil
+}
+
+//Reads a dump of overview lines, in the tab-separated format of an OVER response, and groups it.
+func FromDump(path string, options Options) ([]Release, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overviews, err := nntp.ParseOverviews(data)
+	if err != nil {
+		return nil, err
+	}
+	return Group(overviews, options), nil
+}
+
+//Groups overview records into releases. Articles whose subjects carry no (n/m) part counter are not binaries and are ignored, as
+//are repeated message-IDs and part numbers. Releases are ordered by name.
+func Group(overviews []nntp.Overview, options Options) []Release {
+	binaries := map[string]*binary{}
+	seen := map[string]bool{}
+	for _, o := range overviews {
+		if seen[o.MessageID] {
+			continue
+		}
+		seen[o.MessageID] = true
+
+		b, part := classify(o, binaries)
+		if b == nil {
+			continue
+		}
+		if _, ok := b.parts[part]; ok {
+			continue
+		}
+		b.parts[part] = o
+		if b.date.IsZero() || (!o.Date.IsZero() && o.Date.Before(b.date)) {
+			b.date = o.Date
+		}
+	}
+
+	byRelease := map[string][]*binary{}
+	for _, b := range binaries {
+		byRelease[b.release] = append(byRelease[b.release], b)
+	}
+
+	releases := make([]Release, 0, len(byRelease))
+	for _, members := range byRelease {
+		releases = append(releases, buildRelease(members, options))
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Name != releases[j].Name {
+			return releases[i].Name < releases[j].Name
+		}
+		return releases[i].Poster < releases[j].Poster
+	})
+	return releases
+}
+
+//Finds the binary an article belongs to, creating it on first sight, and returns it with the article's part number. Returns nil
+//for articles without a part counter.
+func classify(o nntp.Overview, binaries map[string]*binary) (*binary, int) {
+	partCounter, fileCounter := subjectCounters(o.Subject)
+	if partCounter == nil {
+		return nil, 0
+	}
+	part, _ := strconv.Atoi(o.Subject[partCounter[2]:partCounter[3]])
+	partTotal, _ := strconv.Atoi(o.Subject[partCounter[4]:partCounter[5]])
+	if part == 0 || partTotal == 0 {
+		return nil, 0
+	}
+
+	//Articles of a file share their subject apart from the part counter.
+	normalized := o.Subject[:partCounter[0]] + o.Subject[partCounter[1]:]
+	key := o.From + "\x00" + normalized + "\x00" + strconv.Itoa(partTotal)
+	if b, ok := binaries[key]; ok {
+		return b, part
+	}
+
+	b := &binary{
+		name: parser.ExtractFilename(parser.File{Subject: o.Subject}),
+		subject: o.Subject,
+		poster: o.From,
+		partTotal: partTotal,
+		parts: map[int]nntp.Overview{},
+	}
+	if fileCounter != nil {
+		b.fileNumber, _ = strconv.Atoi(o.Subject[fileCounter[2]:fileCounter[3]])
+		b.fileTotal, _ = strconv.Atoi(o.Subject[fileCounter[4]:fileCounter[5]])
+		//Files of a release share their poster, file count and whatever precedes the file counter, usually the release name.
+		//Subjects starting with the counter name no release, so the archive set stands in for it.
+		prefix := o.Subject[:fileCounter[0]]
+		if strings.Trim(prefix, " -\"") == "" {
+			prefix = setName(b.name)
+		}
+		b.release = o.From + "\x00" + prefix + "\x00" + strconv.Itoa(b.fileTotal)
+	} else {
+		//Without a file counter, only the archive set the filename belongs to ties files together.
+		b.release = o.From + "\x00" + parser.SetName(b.name)
+	}
+	binaries[key] = b
+	return b, part
+}
+
+//Locates the part and file counters of a subject as submatch indexes, nil if absent. Counters inside the quoted filename are
+//skipped. The part counter is the one following the yEnc marker, or the last one without a marker; the file counter precedes it.
+func subjectCounters(subject string) ([]int, []int) {
+	quoteStart := strings.Index(subject, "\"")
+	quoteEnd := strings.LastIndex(subject, "\"")
+
+	var counters [][]int
+	for _, c := range COUNTER_PATTERN.FindAllStringSubmatchIndex(subject, -1) {
+		if quoteStart < c[0] && c[0] < quoteEnd {
+			continue
+		}
+		counters = append(counters, c)
+	}
+	if len(counters) == 0 {
+		return nil, nil
+	}
+
+	partIndex := len(counters) - 1
+	for i, c := range counters {
+		if YENC_PATTERN.MatchString(subject[:c[0]]) {
+			partIndex = i
+			break
+		}
+	}
+	if partIndex == 0 {
+		return counters[0], nil
+	}
+	return counters[partIndex], counters[partIndex-1]
+}
+
+//The archive set a filename belongs to, or its name without extension for files outside any set, such as .nfo files.
+func setName(filename string) string {
+	set := parser.SetName(filename)
+	if set == filename {
+		set, _ = parser.SplitFilename(filename)
+	}
+	return set
+}
+
+//Assembles the binaries of a release into an NZB and works out its completeness.
+func buildRelease(members []*binary, options Options) Release {
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].fileNumber != members[j].fileNumber {
+			return members[i].fileNumber < members[j].fileNumber
+		}
+		return members[i].name < members[j].name
+	})
+
+	release := Release{
+		Poster: members[0].poster,
+		Files: len(members),
+		ExpectedFiles: members[0].fileTotal,
+		Nzb: &parser.Nzb{},
+	}
+	complete := release.ExpectedFiles == 0 || release.Files >= release.ExpectedFiles
+	for _, b := range members {
+		release.Parts += len(b.parts)
+		release.ExpectedParts += b.partTotal
+		if len(b.parts) < b.partTotal {
+			complete = false
+		}
+		release.Nzb.Files = append(release.Nzb.Files, buildFile(b, options))
+	}
+	release.Complete = complete
+
+	release.Name = releaseName(members)
+	release.Nzb.Head.Meta = []parser.Meta{{Type: "title", Value: release.Name}}
+	return release
+}
+
+//Converts a binary into an NZB file entry, with segments in part order.
+func buildFile(b *binary, options Options) parser.File {
+	numbers := make([]int, 0, len(b.parts))
+	for n := range b.parts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	file := parser.File{
+		Poster: b.poster,
+		Date: b.date.Unix(),
+		Subject: b.parts[numbers[0]].Subject,
+	}
+	if b.date.IsZero() {
+		file.Date = 0
+	}
+
+	groups := map[string]bool{}
+	for _, n := range numbers {
+		o := b.parts[n]
+		file.Segments = append(file.Segments, parser.Segment{Bytes: int(o.Bytes), Number: n, ID: o.MessageID})
+		for _, g := range xrefGroups(o.Xref) {
+			if !groups[g] {
+				groups[g] = true
+				file.Groups = append(file.Groups, g)
+			}
+		}
+	}
+	if len(file.Groups) == 0 {
+		file.Groups = options.Groups
+	}
+	return file
+}
+
+//Extracts the newsgroups from an Xref value such as "host alt.binaries.a:12 alt.binaries.b:34".
+func xrefGroups(xref string) []string {
+	fields := strings.Fields(xref)
+	if len(fields) < 2 {
+		return nil
+	}
+	groups := make([]string, 0, len(fields)-1)
+	for _, f := range fields[1:] {
+		group, _, _ := strings.Cut(f, ":")
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+//Names a release after the subject text preceding its file counter, or failing that after the archive set of its files.
+func releaseName(members []*binary) string {
+	if _, fileCounter := subjectCounters(members[0].subject); fileCounter != nil {
+		prefix := strings.Trim(members[0].subject[:fileCounter[0]], " -\"")
+		if prefix != "" {
+			return prefix
+		}
+	}
+
+	//The set shared by the most files, which skips stray .nfo and .sfv files.
+	counts := map[string]int{}
+	best := ""
+	for _, b := range members {
+		set := setName(b.name)
+		counts[set]++
+		if counts[set] > counts[best] || (counts[set] == counts[best] && set < best) {
+			best = set
+		}
+	}
+	return best
+}
@@ -0,0 +1,151 @@
+package grouping
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/poster"
+)
+
+func TestFromGroupRebuildsPostedReleases(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	posted := map[string]*parser.Nzb{}
+	for _, release := range []struct{ name, from string }{{"Show.S01E01", "alice <a@example.com>"}, {"Show.S01E02", "bob <b@example.com>"}} {
+		nzb, err := poster.Post(context.Background(), server.Config(), []poster.Input{
+			{Name: release.name + ".part1.rar", Data: nntptest.FillerData(release.name+"1", 2500)},
+			{Name: release.name + ".part2.rar", Data: nntptest.FillerData(release.name+"2", 900)},
+			{Name: release.name + ".par2", Data: nntptest.FillerData(release.name+"p", 300)},
+		}, poster.Options{
+			Newsgroups: []string{"alt.binaries.test"},
+			From: release.from,
+			PartSize: 1000,
+			SubjectTemplate: release.name + ` - [{{.FileNumber}}/{{.FileTotal}}] - "{{.Name}}" yEnc ({{.Part}}/{{.Parts}}) {{.Size}}`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		posted[release.name] = nzb
+	}
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	releases, err := FromGroup(client, "alt.binaries.test", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 {
+		t.Fatalf("expected 2 releases, got %d", len(releases))
+	}
+
+	for _, release := range releases {
+		want := posted[release.Name]
+		if want == nil {
+			t.Fatalf("unexpected release name %q", release.Name)
+		}
+		if !release.Complete || release.Files != 3 || release.ExpectedFiles != 3 || release.Parts != 5 || release.ExpectedParts != 5 {
+			t.Fatalf("unexpected completeness: %+v", release)
+		}
+		for i, file := range release.Nzb.Files {
+			if parser.ExtractFilename(file) != parser.ExtractFilename(want.Files[i]) || file.Poster != release.Poster {
+				t.Fatalf("file %d is %q, expected %q", i, file.Subject, want.Files[i].Subject)
+			}
+			if file.Groups[0] != "alt.binaries.test" || file.Date != want.Files[i].Date {
+				t.Fatalf("unexpected file attributes: %+v", file)
+			}
+			for j, segment := range file.Segments {
+				if segment.ID != want.Files[i].Segments[j].ID || segment.Number != j+1 {
+					t.Fatalf("unexpected segment %+v", segment)
+				}
+			}
+		}
+	}
+}
+
+func TestFromDumpReportsIncompleteReleases(t *testing.T) {
+	lines := []string{
+		//Part 2 of the first file and the whole third file are missing; part 1 is listed twice.
+		"1\t[1/3] - \"movie.part1.rar\" yEnc (1/2) 2000\tposter <p@example.com>\tMon, 02 Jan 2006 15:04:05 +0000\t<a1@x>\t\t1100\t10",
+		"2\t[1/3] - \"movie.part1.rar\" yEnc (1/2) 2000\tposter <p@example.com>\tMon, 02 Jan 2006 15:04:05 +0000\t<a1@x>\t\t1100\t10",
+		"3\t[2/3] - \"movie.part2.rar\" yEnc (1/1) 500\tposter <p@example.com>\tMon, 02 Jan 2006 15:05:05 +0000\t<b1@x>\t\t600\t5",
+		//A single file without a file counter, and a text post that is not a binary.
+		"4\t\"notes.nfo\" yEnc (1/1) 100\tother <o@example.com>\tMon, 02 Jan 2006 16:00:00 +0000\t<c1@x>\t\t200\t3\tXref: host alt.binaries.misc:4",
+		"5\tRe: where is part 2?\tother <o@example.com>\tMon, 02 Jan 2006 16:00:00 +0000\t<d1@x>\t\t200\t3",
+	}
+	path := filepath.Join(t.TempDir(), "overview.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := FromDump(path, Options{Groups: []string{"alt.binaries.test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 {
+		t.Fatalf("expected 2 releases, got %+v", releases)
+	}
+
+	movie, notes := releases[0], releases[1]
+	if movie.Name != "movie" || movie.Complete || movie.Files != 2 || movie.ExpectedFiles != 3 || movie.Parts != 2 || movie.ExpectedParts != 3 {
+		t.Fatalf("unexpected movie release: %+v", movie)
+	}
+	if got := movie.Nzb.Files[0]; len(got.Segments) != 1 || got.Segments[0].Bytes != 1100 || got.Groups[0] != "alt.binaries.test" {
+		t.Fatalf("unexpected movie file: %+v", got)
+	}
+	if parser.Title(movie.Nzb.Head.Meta) != "movie" {
+		t.Fatal("release name is not recorded as the NZB title")
+	}
+
+	if notes.Name != "notes" || !notes.Complete || notes.Nzb.Files[0].Groups[0] != "alt.binaries.misc" {
+		t.Fatalf("unexpected notes release: %+v", notes)
+	}
+}
+
+func TestGroupSeparatesUnprefixedReleases(t *testing.T) {
+	from := "poster <p@example.com>"
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	subjects := []string{
+		//Two releases in the default subject format, with nothing before the file counter.
+		`[1/2] - "Show.S01E01.part1.rar" yEnc (1/1) 500`,
+		`[2/2] - "Show.S01E01.part2.rar" yEnc (1/1) 500`,
+		`[1/2] - "Show.S01E02.part1.rar" yEnc (1/1) 500`,
+		`[2/2] - "Show.S01E02.par2" yEnc (1/1) 500`,
+		//A filename carrying its own counter, posted in two parts.
+		`[1/1] - "Album (1/2).zip" yEnc (1/2) 900`,
+		`[1/1] - "Album (1/2).zip" yEnc (2/2) 900`,
+	}
+	var overviews []nntp.Overview
+	for i, subject := range subjects {
+		overviews = append(overviews, nntp.Overview{Number: int64(i + 1), Subject: subject, From: from, Date: date, MessageID: fmt.Sprintf("<%d@x>", i), Bytes: 600})
+	}
+
+	releases := Group(overviews, Options{})
+	if len(releases) != 3 {
+		t.Fatalf("expected 3 releases, got %+v", releases)
+	}
+	for i, name := range []string{"Album (1/2)", "Show.S01E01", "Show.S01E02"} {
+		release := releases[i]
+		if release.Name != name || !release.Complete {
+			t.Fatalf("release %d: unexpected %+v", i, release)
+		}
+	}
+	if album := releases[0]; album.Files != 1 || album.Parts != 2 || album.ExpectedParts != 2 || album.ExpectedFiles != 1 {
+		t.Fatalf("counter inside the filename was taken for the part counter: %+v", album)
+	}
+}
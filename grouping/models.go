@@ -0,0 +1,42 @@
+package grouping
+
+import (
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Settings for grouping.
+type Options struct {
+	//Groups recorded on files whose overview records carry no Xref, usually the newsgroup the overview was read from.
+	Groups []string
+}
+
+//A set of files posted together, with how complete it is on the server the overview came from.
+type Release struct {
+	Name string
+	Poster string
+	//Files found, and the file count announced by the [n/m] counter in the subjects. ExpectedFiles is 0 when there is no counter.
+	Files int
+	ExpectedFiles int
+	//Articles found, and the article count announced by the (n/m) counters of the files found.
+	Parts int
+	ExpectedParts int
+	//Whether every announced file and article was found.
+	Complete bool
+	Nzb *parser.Nzb
+}
+
+//Articles sharing a subject apart from their part counter.
+type binary struct {
+	name string
+	subject string
+	poster string
+	release string
+	fileNumber int
+	fileTotal int
+	partTotal int
+	parts map[int]nntp.Overview
+	date time.Time
+}
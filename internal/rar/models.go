@@ -0,0 +1,22 @@
+package rar
+
+//The first file header of a rar volume, or the fact that the archive's headers are encrypted and no file header can be read.
+type Header struct {
+	//4 for RAR 4.x archives, 5 for RAR 5.0.
+	Version int
+	//Set when the headers themselves are encrypted; the other fields are then empty and Encrypted is set too.
+	HeadersEncrypted bool
+	Name string
+	//Offset and length of the file's data area within the volume.
+	DataOffset int64
+	DataLength int64
+	Stored bool
+	Encrypted bool
+}
+
+//Reads a header field by field, failing once it runs past the end rather than slicing out of bounds.
+type cursor struct {
+	data []byte
+	pos int
+	failed bool
+}
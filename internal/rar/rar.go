@@ -0,0 +1,268 @@
+// Allows for reading the block headers of RAR 4.x and 5.0 volumes, as far as the first file header. Every size read from the
+// volume is checked against the data actually present before it is used, so crafted headers yield errors rather than panics.
+package rar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+//Leading bytes of rar archives.
+var (
+	Magic4 = []byte("Rar!\x1a\x07\x00")
+	Magic5 = []byte("Rar!\x1a\x07\x01\x00")
+)
+
+//Errors returned while reading headers.
+var (
+	ErrNotRar = errors.New("rar: not a rar archive")
+	//The data ends before a file header, as when only a volume's first segment was fetched and its leading blocks are large.
+	ErrNoFileHeader = errors.New("rar: no file header within the data")
+	ErrMalformed = errors.New("rar: malformed header")
+)
+
+//Reads the first file header of a volume from its leading bytes.
+func FirstFile(data []byte) (*Header, error) {
+	switch {
+	case bytes.HasPrefix(data, Magic5):
+		return firstFile5(data)
+	case bytes.HasPrefix(data, Magic4):
+		return firstFile4(data)
+	}
+	return nil, ErrNotRar
+}
+
+//Walks RAR 4.x blocks to the first file header.
+func firstFile4(data []byte) (*Header, error) {
+	const (
+		mainHeader = 0x73
+		fileHeader = 0x74
+		mainPassword = 0x0080
+		longBlock = 0x8000
+	)
+
+	length := int64(len(data))
+	offset := int64(len(Magic4))
+	for offset+7 <= length {
+		//HEAD_CRC(2) HEAD_TYPE(1) HEAD_FLAGS(2) HEAD_SIZE(2) [ADD_SIZE(4)]
+		kind := data[offset+2]
+		flags := binary.LittleEndian.Uint16(data[offset+3:])
+		size := int64(binary.LittleEndian.Uint16(data[offset+5:]))
+		if size < 7 {
+			return nil, ErrMalformed
+		}
+
+		switch kind {
+		case mainHeader:
+			if flags&mainPassword != 0 {
+				return &Header{Version: 4, HeadersEncrypted: true, Encrypted: true}, nil
+			}
+		case fileHeader:
+			if offset+size > length {
+				return nil, ErrNoFileHeader
+			}
+			return file4(data[offset+7:offset+size], flags, offset+size)
+		}
+
+		if flags&longBlock != 0 {
+			if offset+11 > length {
+				return nil, ErrNoFileHeader
+			}
+			size += int64(binary.LittleEndian.Uint32(data[offset+7:]))
+		}
+		offset += size
+	}
+	return nil, ErrNoFileHeader
+}
+
+//Reads the fields of a RAR 4.x file header, given without its 7 byte base. dataOffset is where the header ends.
+func file4(header []byte, flags uint16, dataOffset int64) (*Header, error) {
+	const (
+		largeFile = 0x0100
+		password = 0x0004
+		storeMethod = 0x30
+	)
+
+	//PACK_SIZE(4) UNP_SIZE(4) HOST_OS(1) FILE_CRC(4) FTIME(4) UNP_VER(1) METHOD(1) NAME_SIZE(2) ATTR(4)
+	//[HIGH_PACK_SIZE(4) HIGH_UNP_SIZE(4)] FILE_NAME
+	if len(header) < 25 {
+		return nil, ErrMalformed
+	}
+	packed := int64(binary.LittleEndian.Uint32(header[0:]))
+	nameSize := int(binary.LittleEndian.Uint16(header[19:]))
+	nameStart := 25
+	if flags&largeFile != 0 {
+		if len(header) < 33 {
+			return nil, ErrMalformed
+		}
+		high := int64(binary.LittleEndian.Uint32(header[25:]))
+		if high > math.MaxInt32 {
+			return nil, ErrMalformed
+		}
+		packed |= high << 32
+		nameStart += 8
+	}
+	if nameSize > len(header)-nameStart {
+		return nil, ErrMalformed
+	}
+	//Unicode names append an encoded copy after a NUL; the first part is enough.
+	name, _, _ := bytes.Cut(header[nameStart:nameStart+nameSize], []byte{0})
+
+	return &Header{
+		Version: 4,
+		Name: string(name),
+		DataOffset: dataOffset,
+		DataLength: packed,
+		Stored: header[18] == storeMethod,
+		Encrypted: flags&password != 0,
+	}, nil
+}
+
+//Reads a RAR 5.0 variable-length integer, returning it and the number of bytes used, or 0 bytes if it is truncated.
+func readVint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		value |= uint64(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+//Reads a variable-length integer.
+func (c *cursor) vint() uint64 {
+	if c.failed {
+		return 0
+	}
+	value, n := readVint(c.data[c.pos:])
+	if n == 0 {
+		c.failed = true
+		return 0
+	}
+	c.pos += n
+	return value
+}
+
+//Skips n bytes.
+func (c *cursor) skip(n uint64) {
+	if c.failed || n > uint64(len(c.data)-c.pos) {
+		c.failed = true
+		return
+	}
+	c.pos += int(n)
+}
+
+//Reads n bytes.
+func (c *cursor) bytes(n uint64) []byte {
+	start := c.pos
+	c.skip(n)
+	if c.failed {
+		return nil
+	}
+	return c.data[start:c.pos]
+}
+
+//Walks RAR 5.0 blocks to the first file header.
+func firstFile5(data []byte) (*Header, error) {
+	const (
+		fileHeader = 2
+		encryptionHeader = 4
+		hasExtra = 0x01
+		hasData = 0x02
+	)
+
+	offset := len(Magic5)
+	for offset+4 < len(data) {
+		//CRC32(4) HeaderSize(vint), then HeaderSize bytes starting with the type.
+		headerSize, n := readVint(data[offset+4:])
+		if n == 0 {
+			return nil, ErrNoFileHeader
+		}
+		start := offset + 4 + n
+		if headerSize == 0 {
+			return nil, ErrMalformed
+		}
+		if headerSize > uint64(len(data)-start) {
+			return nil, ErrNoFileHeader
+		}
+		end := start + int(headerSize)
+
+		c := &cursor{data: data[start:end]}
+		kind := c.vint()
+		flags := c.vint()
+		extraSize, dataSize := uint64(0), uint64(0)
+		if flags&hasExtra != 0 {
+			extraSize = c.vint()
+		}
+		if flags&hasData != 0 {
+			dataSize = c.vint()
+		}
+		if c.failed || extraSize > uint64(len(c.data)-c.pos) || dataSize > math.MaxInt64 {
+			return nil, ErrMalformed
+		}
+
+		switch kind {
+		case encryptionHeader:
+			return &Header{Version: 5, HeadersEncrypted: true, Encrypted: true}, nil
+		case fileHeader:
+			return file5(c, int(extraSize), int64(end), int64(dataSize))
+		}
+
+		if dataSize > uint64(len(data)-end) {
+			return nil, ErrNoFileHeader
+		}
+		offset = end + int(dataSize)
+	}
+	return nil, ErrNoFileHeader
+}
+
+//Reads the fields of a RAR 5.0 file header from a cursor positioned after the common fields. The extra area is the header's last
+//extraSize bytes.
+func file5(c *cursor, extraSize int, dataOffset int64, dataSize int64) (*Header, error) {
+	const (
+		hasTime = 0x02
+		hasCRC = 0x04
+		fileEncryptionRecord = 0x01
+	)
+
+	extra := c.data[len(c.data)-extraSize:]
+	fields := &cursor{data: c.data[:len(c.data)-extraSize], pos: c.pos}
+	fileFlags := fields.vint()
+	fields.vint() //Unpacked size
+	fields.vint() //Attributes
+	if fileFlags&hasTime != 0 {
+		fields.skip(4)
+	}
+	if fileFlags&hasCRC != 0 {
+		fields.skip(4)
+	}
+	compression := fields.vint()
+	fields.vint() //Host OS
+	name := fields.bytes(fields.vint())
+	if fields.failed {
+		return nil, ErrMalformed
+	}
+
+	header := &Header{
+		Version: 5,
+		Name: string(name),
+		DataOffset: dataOffset,
+		DataLength: dataSize,
+		//Bits 7-9 of the compression information hold the method; 0 means stored.
+		Stored: (compression>>7)&0x07 == 0,
+	}
+	for len(extra) > 0 {
+		recordSize, n := readVint(extra)
+		if n == 0 || recordSize > uint64(len(extra)-n) {
+			break
+		}
+		if recordType, _ := readVint(extra[n : n+int(recordSize)]); recordType == fileEncryptionRecord {
+			header.Encrypted = true
+		}
+		extra = extra[n+int(recordSize):]
+	}
+	return header, nil
+}
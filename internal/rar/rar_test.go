@@ -0,0 +1,81 @@
+package rar
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+//Builds a RAR 5.0 volume holding a main header and then the given file header.
+func rar5(fileHeader []byte) []byte {
+	data := append([]byte{}, Magic5...)
+	data = append(data, 0, 0, 0, 0, 3, 1, 0, 0)
+	data = append(data, 0, 0, 0, 0, byte(len(fileHeader)))
+	return append(data, fileHeader...)
+}
+
+func TestFirstFile(t *testing.T) {
+	//RAR 4.x, stored, with a Unicode name.
+	name := []byte("movie.mkv\x00\x01\x02")
+	header := binary.LittleEndian.AppendUint32(nil, 1234)
+	header = binary.LittleEndian.AppendUint32(header, 1234)
+	header = append(header, 2, 0, 0, 0, 0, 0, 0, 0, 0, 29, 0x30)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(name)))
+	header = append(append(header, 0, 0, 0, 0), name...)
+	data := append(append([]byte{}, Magic4...), 0, 0, 0x73, 0, 0, 13, 0, 0, 0, 0, 0, 0, 0)
+	data = append(data, 0, 0, 0x74, 0x00, 0x80)
+	data = binary.LittleEndian.AppendUint16(data, uint16(7+len(header)))
+	data = append(data, header...)
+
+	found, err := FirstFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Version != 4 || found.Name != "movie.mkv" || !found.Stored || found.Encrypted || found.DataLength != 1234 ||
+		found.DataOffset != int64(len(data)) {
+		t.Fatalf("unexpected RAR 4.x header %+v", found)
+	}
+
+	//RAR 5.0 with a file encryption record, preceded by a main header.
+	found, err = FirstFile(rar5([]byte{2, 3, 5, 7, 0, 0, 0, 0, 0, 1, 'a', 4, 1, 0, 0, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Version != 5 || found.Name != "a" || !found.Encrypted || found.DataLength != 7 || !found.Stored {
+		t.Fatalf("unexpected RAR 5.0 header %+v", found)
+	}
+
+	found, err = FirstFile(append(append([]byte{}, Magic5...), 0, 0, 0, 0, 2, 4, 0))
+	if err != nil || !found.HeadersEncrypted {
+		t.Fatalf("encrypted headers not reported: %+v %v", found, err)
+	}
+	if _, err := FirstFile([]byte("PK\x03\x04")); !errors.Is(err, ErrNotRar) {
+		t.Fatalf("zip gave %v", err)
+	}
+}
+
+func TestFirstFileMalformed(t *testing.T) {
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	rar4Long := append(append([]byte{}, Magic4...), 0, 0, 0x74, 0x00, 0x01, 32, 0)
+
+	for name, data := range map[string][]byte{
+		"header size past int64": append(append(append([]byte{}, Magic5...), 0, 0, 0, 0), append(huge, make([]byte, 64)...)...),
+		"data size wrapping the offset": rar5(append([]byte{1, 0x02}, huge...)),
+		"mtime past a short header": rar5([]byte{2, 0, 0x02, 0, 0}),
+		"CRC past a short header": rar5([]byte{2, 0, 0x06, 0, 0, 1, 2, 3, 4}),
+		"name length past int64": rar5(append([]byte{2, 0, 0, 0, 0, 0, 0}, huge...)),
+		"name longer than the header": rar5([]byte{2, 0, 0, 0, 0, 0, 0, 50, 'a'}),
+		"extra area larger than the header": rar5([]byte{2, 0x01, 60, 0}),
+		"large file without high sizes": append(rar4Long, make([]byte, 25)...),
+		"rar4 block smaller than its base": append(append([]byte{}, Magic4...), 0, 0, 0x73, 0, 0, 3, 0),
+	} {
+		if found, err := FirstFile(data); err == nil {
+			t.Errorf("%s: accepted as %+v", name, found)
+		}
+	}
+
+	//Truncated data is reported as such, so callers can fetch more.
+	if _, err := FirstFile(rar5([]byte{2, 0, 0, 0, 0, 0, 0, 1, 'a'})[:20]); !errors.Is(err, ErrNoFileHeader) {
+		t.Fatalf("truncated header gave %v", err)
+	}
+}
@@ -69,14 +69,59 @@ func Remove(editor *NzbMetaEditor, attribute string) {
 //Set metadata fields, replacing one or more of the same type of fields.
 func Set(editor *NzbMetaEditor, attribute string, content string) {
 	if IsAttribute(attribute) {
-		for _, m := range editor.Metadata {
-			if m.Type == attribute {
-				m.Value = content
+		//Indexing rather than ranging by value, so the stored fields themselves are updated.
+		for i := range editor.Metadata {
+			if editor.Metadata[i].Type == attribute {
+				editor.Metadata[i].Value = content
 			}
 		}
 	}
 }
 
+//Sets a metadata field like Set, appending it instead if the editor holds no field of that type yet. Empty content is ignored.
+func Put(editor *NzbMetaEditor, attribute string, content string) {
+	if content == "" {
+		return
+	}
+	if Contains(editor, attribute, "") {
+		Set(editor, attribute, content)
+	} else {
+		Append(editor, attribute, content)
+	}
+}
+
+//Checks whether an NzbMetaEditor holds a field of the given type, and with the given content unless it is empty.
+func Contains(editor *NzbMetaEditor, attribute string, content string) bool {
+	for _, m := range editor.Metadata {
+		if m.Type == attribute && (content == "" || m.Value == content) {
+			return true
+		}
+	}
+	return false
+}
+
+//Replaces the category field with the local category a mapper picks for it, adding the field if there was none. The title field,
+//or failing that the first non-par2 file's name, is given as the release name. Nothing changes if the mapper finds no category;
+//the return value reports whether one was set.
+func NormalizeCategory(editor *NzbMetaEditor, mapper CategoryMapper) bool {
+	name := parser.Title(editor.Metadata)
+	if name == "" && editor.Nzb != nil {
+		for _, f := range editor.Nzb.Files {
+			if !parser.IsPar2(&f) {
+				name = parser.SetName(parser.ExtractFilename(f))
+				break
+			}
+		}
+	}
+
+	category, ok := mapper.Normalize(parser.Category(editor.Metadata), name)
+	if !ok {
+		return false
+	}
+	Put(editor, "category", category)
+	return true
+}
+
 //Default sorting pattern.
 var defaultPattern = map[string]int {
 	"title": 0,
@@ -0,0 +1,98 @@
+package metaeditor
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+func openSample(t *testing.T) *NzbMetaEditor {
+	editor, err := From_file("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return editor
+}
+
+func TestSetUpdatesStoredFields(t *testing.T) {
+	editor := openSample(t)
+	Append(editor, "password", "second")
+
+	Set(editor, "password", "changed")
+	if passwords := parser.Passwords(editor.Metadata); !slices.Equal(passwords, []string{"changed", "changed"}) {
+		t.Fatalf("every password field should be replaced, got %v", passwords)
+	}
+	//Fields of other types are untouched, and unknown attributes are ignored.
+	Set(editor, "unknown", "value")
+	if parser.Title(editor.Metadata) != "title" || len(editor.Metadata) != 5 {
+		t.Fatalf("unexpected fields: %+v", editor.Metadata)
+	}
+
+	//The change reaches the written NZB.
+	nzb, err := parser.FromStr(ToStr(editor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passwords := parser.Passwords(nzb.Head.Meta); !slices.Equal(passwords, []string{"changed", "changed"}) {
+		t.Fatalf("written NZB has passwords %v", passwords)
+	}
+}
+
+func TestPutAndContains(t *testing.T) {
+	editor := openSample(t)
+	if !Contains(editor, "title", "") || !Contains(editor, "title", "title") || Contains(editor, "title", "other") {
+		t.Fatal("Contains does not reflect the title field")
+	}
+
+	Put(editor, "title", "New Title")
+	if parser.Title(editor.Metadata) != "New Title" || len(editor.Metadata) != 4 {
+		t.Fatalf("existing title was not replaced: %+v", editor.Metadata)
+	}
+
+	Clear(editor)
+	Put(editor, "category", "tv")
+	Put(editor, "title", "")
+	if !Contains(editor, "category", "tv") || Contains(editor, "title", "") || len(editor.Metadata) != 1 {
+		t.Fatalf("unexpected fields after putting into an empty editor: %+v", editor.Metadata)
+	}
+}
+
+//Mapper returning a fixed category, recording what it was asked.
+type fakeMapper struct {
+	category string
+	ok bool
+	gotCategory string
+	gotName string
+}
+
+func (m *fakeMapper) Normalize(category string, name string) (string, bool) {
+	m.gotCategory, m.gotName = category, name
+	return m.category, m.ok
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	editor := openSample(t)
+	mapper := &fakeMapper{category: "movies", ok: true}
+	if !NormalizeCategory(editor, mapper) || parser.Category(editor.Metadata) != "movies" {
+		t.Fatalf("category was not replaced: %+v", editor.Metadata)
+	}
+	if mapper.gotCategory != "misc." || mapper.gotName != "title" {
+		t.Fatalf("mapper was given %q and %q", mapper.gotCategory, mapper.gotName)
+	}
+
+	//Without a title, the release name comes from the files; without a match, nothing changes.
+	Remove(editor, "title")
+	before := slices.Clone(editor.Metadata)
+	if NormalizeCategory(editor, &fakeMapper{}) || !slices.Equal(editor.Metadata, before) {
+		t.Fatalf("meta changed without a match: %+v", editor.Metadata)
+	}
+	Remove(editor, "category")
+	mapper = &fakeMapper{category: "tv", ok: true}
+	if !NormalizeCategory(editor, mapper) || parser.Category(editor.Metadata) != "tv" {
+		t.Fatalf("missing category was not added: %+v", editor.Metadata)
+	}
+	if mapper.gotName == "" || mapper.gotCategory != "" {
+		t.Fatalf("mapper was given %q and %q", mapper.gotCategory, mapper.gotName)
+	}
+}
@@ -7,3 +7,9 @@ type NzbMetaEditor struct {
 	Metadata []parser.Meta
 	Nzb *parser.Nzb
 }
+
+//Maps a category as found in an NZB, and the release's name, to a local category name, as categories.Mapper does. The boolean
+//reports whether anything matched.
+type CategoryMapper interface {
+	Normalize(category string, name string) (string, bool)
+}
@@ -0,0 +1,256 @@
+// Allows for searching newznab indexers and fetching their NZBs, and for serving a local NZB collection over the same API.
+package newznab
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Largest response read from an indexer, and largest NZB once decompressed.
+const maxResponse = 64 << 20
+
+//Returned for responses larger than the client reads.
+var ErrTooLarge = errors.New("newznab: response exceeds the size limit")
+
+//Creates a client for the API at baseURL.
+func NewClient(baseURL string, apiKey string) *Client {
+	return &Client{BaseURL: baseURL, APIKey: apiKey}
+}
+
+//Retrieves the indexer's capabilities.
+func (c *Client) Caps(ctx context.Context) (*Caps, error) {
+	data, err := c.get(ctx, url.Values{"t": {"caps"}})
+	if err != nil {
+		return nil, err
+	}
+	var caps Caps
+	if err := xml.Unmarshal(data, &caps); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
+
+//Runs a free-text search (t=search).
+func (c *Client) Search(ctx context.Context, query Query) (*Results, error) {
+	return c.search(ctx, "search", query, nil)
+}
+
+//Runs a TV search (t=tvsearch), by query, IDs, season and episode.
+func (c *Client) TVSearch(ctx context.Context, query Query) (*Results, error) {
+	return c.search(ctx, "tvsearch", query, map[string]string{
+		"season": query.Season,
+		"ep": query.Episode,
+		"tvdbid": query.TVDBID,
+		"tvmazeid": query.TVMazeID,
+		"rid": query.RageID,
+	})
+}
+
+//Runs a movie search (t=movie), by query or IMDb ID.
+func (c *Client) Movie(ctx context.Context, query Query) (*Results, error) {
+	return c.search(ctx, "movie", query, map[string]string{
+		"imdbid": strings.TrimPrefix(query.IMDBID, "tt"),
+	})
+}
+
+//Downloads the NZB of a search result, from its link or, failing that, through t=get with its GUID. Gzipped NZBs are decompressed.
+func (c *Client) Download(ctx context.Context, item Item) (*parser.Nzb, error) {
+	var (
+		data []byte
+		err error
+	)
+	if item.Link != "" {
+		data, err = c.fetch(ctx, item.Link)
+	} else {
+		data, err = c.get(ctx, url.Values{"t": {"get"}, "id": {item.GUID}})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = parser.Decompress(data, maxResponse); err != nil {
+		return nil, err
+	}
+	return parser.FromStr(string(data))
+}
+
+//Runs a search of type t, adding the common parameters and any non-empty extra ones.
+func (c *Client) search(ctx context.Context, t string, query Query, extra map[string]string) (*Results, error) {
+	values := url.Values{"t": {t}, "extended": {"1"}}
+	if query.Q != "" {
+		values.Set("q", query.Q)
+	}
+	if len(query.Categories) > 0 {
+		categories := make([]string, len(query.Categories))
+		for i, category := range query.Categories {
+			categories[i] = strconv.Itoa(category)
+		}
+		values.Set("cat", strings.Join(categories, ","))
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset > 0 {
+		values.Set("offset", strconv.Itoa(query.Offset))
+	}
+	if query.MaxAge > 0 {
+		values.Set("maxage", strconv.Itoa(query.MaxAge))
+	}
+	for key, value := range extra {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	data, err := c.get(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	return ParseResults(data)
+}
+
+//Parses an RSS document of search results, including newznab attributes.
+func ParseResults(data []byte) (*Results, error) {
+	var document rss
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	results := &Results{
+		Offset: document.Channel.Response.Offset,
+		Total: document.Channel.Response.Total,
+		Items: make([]Item, 0, len(document.Channel.Items)),
+	}
+	for _, raw := range document.Channel.Items {
+		results.Items = append(results.Items, convertItem(raw))
+	}
+	//Feeds without a newznab:response element only hold what they list.
+	if results.Total == 0 {
+		results.Total = len(results.Items)
+	}
+	return results, nil
+}
+
+//Converts a parsed RSS item.
+func convertItem(raw rssItem) Item {
+	item := Item{
+		Title: strings.TrimSpace(raw.Title),
+		GUID: strings.TrimSpace(raw.GUID),
+		Link: strings.TrimSpace(raw.Link),
+		Comments: raw.Comments,
+		Description: raw.Description,
+		Category: raw.Category,
+		Size: raw.Enclosure.Length,
+	}
+	if item.Link == "" {
+		item.Link = raw.Enclosure.URL
+	}
+	item.Published, _ = mail.ParseDate(strings.TrimSpace(raw.PubDate))
+
+	for _, a := range raw.Attrs {
+		item.SetAttr(a.Name, a.Value)
+	}
+	return item
+}
+
+//Records a newznab:attr, interpreting the attributes the module knows about (category, size, grabs and password).
+func (i *Item) SetAttr(name string, value string) {
+	if i.Attrs == nil {
+		i.Attrs = map[string][]string{}
+	}
+	i.Attrs[name] = append(i.Attrs[name], value)
+
+	switch name {
+	case "category":
+		if category, err := strconv.Atoi(value); err == nil {
+			i.Categories = append(i.Categories, category)
+		}
+	case "size":
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+			i.Size = size
+		}
+	case "grabs":
+		i.Grabs, _ = strconv.Atoi(value)
+	case "password":
+		password, _ := strconv.Atoi(value)
+		i.Password = Password(password)
+	}
+}
+
+//Calls the API with the given parameters and the API key.
+func (c *Client) get(ctx context.Context, values url.Values) ([]byte, error) {
+	endpoint, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	query := endpoint.Query()
+	for key, value := range values {
+		query[key] = value
+	}
+	if c.APIKey != "" {
+		query.Set("apikey", c.APIKey)
+	}
+	endpoint.RawQuery = query.Encode()
+	return c.fetch(ctx, endpoint.String())
+}
+
+//Fetches a URL, turning newznab error documents and HTTP failures into errors.
+func (c *Client) fetch(ctx context.Context, target string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxResponse {
+		return nil, ErrTooLarge
+	}
+	//Indexers report API errors as an <error> document, sometimes with a 200 status.
+	if apiErr := parseError(data); apiErr != nil {
+		return nil, apiErr
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("newznab: unexpected HTTP status %s", response.Status)
+	}
+	return data, nil
+}
+
+//Parses an <error> document, returning nil for anything else.
+func parseError(data []byte) *Error {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		if end := bytes.Index(trimmed, []byte("?>")); end >= 0 {
+			trimmed = bytes.TrimSpace(trimmed[end+2:])
+		}
+	}
+	if !bytes.HasPrefix(trimmed, []byte("<error")) {
+		return nil
+	}
+	var apiErr Error
+	if err := xml.Unmarshal(trimmed, &apiErr); err != nil {
+		return nil
+	}
+	return &apiErr
+}
@@ -0,0 +1,152 @@
+package newznab
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Starts an indexer replaying captured responses. Requests are checked against the query each test expects.
+func startIndexer(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	replay := func(w io.Writer, path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apikey") != "secret" {
+			replay(w, "../_tests/newznab/error.xml")
+			return
+		}
+		if check != nil {
+			check(r)
+		}
+		switch r.URL.Query().Get("t") {
+		case "caps":
+			replay(w, "../_tests/newznab/caps.xml")
+		case "search", "tvsearch", "movie":
+			replay(w, "../_tests/newznab/tvsearch.xml")
+		case "get":
+			replay(w, "../_tests/nzbs/samplenzb.nzb")
+		}
+	})
+	mux.HandleFunc("/getnzb/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ".gz") {
+			replay(w, "../_tests/nzbs/samplenzb.nzb")
+			return
+		}
+		writer := gzip.NewWriter(w)
+		defer writer.Close()
+		replay(writer, "../_tests/nzbs/samplenzb.nzb")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCaps(t *testing.T) {
+	server := startIndexer(t, nil)
+	caps, err := NewClient(server.URL+"/api", "secret").Caps(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Server.Title != "Example Indexer" || caps.Limits.Max != 100 || caps.Searching.TVSearch.Available != "yes" {
+		t.Fatalf("unexpected caps: %+v", caps)
+	}
+	if len(caps.Categories) != 2 || caps.Categories[1].Subcategories[1].ID != 5040 {
+		t.Fatalf("unexpected categories: %+v", caps.Categories)
+	}
+}
+
+func TestTVSearchParsesAttributes(t *testing.T) {
+	server := startIndexer(t, func(r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("t") != "tvsearch" || query.Get("season") != "1" || query.Get("ep") != "2" || query.Get("cat") != "5030,5040" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		if query.Has("imdbid") {
+			t.Error("movie parameters sent with a TV search")
+		}
+	})
+
+	results, err := NewClient(server.URL+"/api", "secret").TVSearch(context.Background(), Query{
+		Q: "Show Name",
+		Season: "1",
+		Episode: "2",
+		Categories: []int{5030, 5040},
+		IMDBID: "tt0000001",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Total != 2 || len(results.Items) != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	first := results.Items[0]
+	if first.Title != "Show.Name.S01E02.720p.HDTV.x264-GROUP" || first.Size != 1468006400 || first.Grabs != 42 || first.Password != PasswordNone {
+		t.Fatalf("unexpected item: %+v", first)
+	}
+	if len(first.Categories) != 2 || first.Categories[1] != 5040 || first.Category != "TV > HD" || first.Attrs["tvdbid"][0] != "12345" {
+		t.Fatalf("unexpected item categories or attributes: %+v", first)
+	}
+	if first.Published.Day() != 6 || !strings.Contains(first.Link, "/getnzb/a1b2c3.nzb") {
+		t.Fatalf("unexpected date or link: %+v", first)
+	}
+	if results.Items[1].Password != PasswordYes {
+		t.Fatal("password attribute not parsed")
+	}
+}
+
+func TestMovieSearchStripsIMDBPrefix(t *testing.T) {
+	server := startIndexer(t, func(r *http.Request) {
+		if r.URL.Query().Get("imdbid") != "0133093" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+	})
+	if _, err := NewClient(server.URL+"/api", "secret").Movie(context.Background(), Query{IMDBID: "tt0133093"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := startIndexer(t, nil)
+	client := NewClient(server.URL+"/api", "secret")
+
+	nzb, err := client.Download(context.Background(), Item{Link: server.URL + "/getnzb/a1b2c3.nzb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parser.Title(nzb.Head.Meta) != "title" || len(nzb.Files) == 0 {
+		t.Fatalf("unexpected NZB: %+v", nzb.Head)
+	}
+
+	if nzb, err := client.Download(context.Background(), Item{Link: server.URL + "/getnzb/a1b2c3.nzb.gz"}); err != nil || len(nzb.Files) == 0 {
+		t.Fatalf("gzipped NZB was not decompressed: %v", err)
+	}
+
+	//Without a link, the NZB is fetched through t=get.
+	if _, err := client.Download(context.Background(), Item{GUID: "a1b2c3"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	server := startIndexer(t, nil)
+	_, err := NewClient(server.URL+"/api", "wrong").Search(context.Background(), Query{Q: "anything"})
+	if !errors.Is(err, ErrIncorrectCredentials) {
+		t.Fatalf("expected ErrIncorrectCredentials, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package newznab
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+//An error response from an indexer. Compare against the sentinel errors below with errors.Is, which matches on Code alone.
+type Error struct {
+	XMLName xml.Name `xml:"error"`
+	Code int `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("newznab: %d %s", e.Code, e.Description)
+}
+
+//Matches any *Error carrying the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+//Error codes defined by the newznab API.
+var (
+	ErrIncorrectCredentials = &Error{Code: 100, Description: "Incorrect user credentials"}
+	ErrAccountSuspended = &Error{Code: 101, Description: "Account suspended"}
+	ErrInsufficientPrivileges = &Error{Code: 102, Description: "Insufficient privileges/not authorized"}
+	ErrMissingParameter = &Error{Code: 200, Description: "Missing parameter"}
+	ErrIncorrectParameter = &Error{Code: 201, Description: "Incorrect parameter"}
+	ErrNoSuchFunction = &Error{Code: 202, Description: "No such function"}
+	ErrFunctionNotAvailable = &Error{Code: 203, Description: "Function not available"}
+	ErrNoSuchItem = &Error{Code: 300, Description: "No such item"}
+	ErrRequestLimitReached = &Error{Code: 500, Description: "Request limit reached"}
+	ErrDownloadLimitReached = &Error{Code: 501, Description: "Download limit reached"}
+	ErrUnknown = &Error{Code: 900, Description: "Unknown error"}
+)
@@ -0,0 +1,233 @@
+package newznab
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//A newznab indexer API. BaseURL is the API endpoint, such as "https://indexer.example/api".
+type Client struct {
+	BaseURL string
+	APIKey string
+	//Used for every request; http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+//Search parameters. Fields a search type does not support are left out of its request.
+type Query struct {
+	//Free-text query.
+	Q string
+	Categories []int
+	//Result window; the indexer's defaults if zero.
+	Limit int
+	Offset int
+	//Only results posted within this many days; unlimited if zero.
+	MaxAge int
+	//TV search parameters.
+	Season string
+	Episode string
+	TVDBID string
+	TVMazeID string
+	RageID string
+	//Movie search parameters. IMDBID is given without the "tt" prefix.
+	IMDBID string
+}
+
+//Whether a release's archives are password protected, per the newznab "password" attribute.
+type Password int
+
+const (
+	PasswordNone Password = 0
+	PasswordYes Password = 1
+	PasswordMaybe Password = 2
+)
+
+//A search result.
+type Item struct {
+	Title string
+	GUID string
+	//URL of the NZB.
+	Link string
+	Comments string
+	Description string
+	Published time.Time
+	//Category as text, such as "TV > HD".
+	Category string
+	//Numeric newznab categories, such as 5040.
+	Categories []int
+	Size int64
+	Grabs int
+	Password Password
+	//Every newznab:attr of the item, by name; names may repeat.
+	Attrs map[string][]string
+}
+
+//A page of search results. Total counts every match, not only the ones returned.
+type Results struct {
+	Offset int
+	Total int
+	Items []Item
+}
+
+//Capabilities of an indexer, as returned by t=caps.
+type Caps struct {
+	XMLName xml.Name `xml:"caps"`
+	Server CapsServer `xml:"server"`
+	Limits CapsLimits `xml:"limits"`
+	Searching CapsSearching `xml:"searching"`
+	Categories []CapsCategory `xml:"categories>category"`
+}
+
+type CapsServer struct {
+	Version string `xml:"version,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+type CapsLimits struct {
+	Max int `xml:"max,attr,omitempty"`
+	Default int `xml:"default,attr,omitempty"`
+}
+
+type CapsSearching struct {
+	Search CapsSearch `xml:"search"`
+	TVSearch CapsSearch `xml:"tv-search"`
+	MovieSearch CapsSearch `xml:"movie-search"`
+}
+
+//Availability of a search type. Available is "yes" or "no"; SupportedParams is a comma-separated list such as "q,season,ep".
+type CapsSearch struct {
+	Available string `xml:"available,attr"`
+	SupportedParams string `xml:"supportedParams,attr,omitempty"`
+}
+
+type CapsCategory struct {
+	ID int `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+	Subcategories []CapsCategory `xml:"subcat"`
+}
+
+//RSS document returned by searches.
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel channel `xml:"channel"`
+}
+
+type channel struct {
+	Response response `xml:"response"`
+	Items []rssItem `xml:"item"`
+}
+
+type response struct {
+	Offset int `xml:"offset,attr"`
+	Total int `xml:"total,attr"`
+}
+
+type rssItem struct {
+	Title string `xml:"title"`
+	GUID string `xml:"guid"`
+	Link string `xml:"link"`
+	Comments string `xml:"comments"`
+	PubDate string `xml:"pubDate"`
+	Category string `xml:"category"`
+	Description string `xml:"description"`
+	Enclosure enclosure `xml:"enclosure"`
+	Attrs []attr `xml:"attr"`
+}
+
+type enclosure struct {
+	URL string `xml:"url,attr"`
+	Length int64 `xml:"length,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type attr struct {
+	Name string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+//Settings for serving a directory of NZBs.
+type ServerOptions struct {
+	//Directory searched recursively for .nzb and .nzb.gz files.
+	Directory string
+	//Keys accepted in the apikey parameter. Requests need no key if empty.
+	APIKeys []string
+	//Title reported by t=caps.
+	Title string
+	//Newznab category IDs by local category, matched case-insensitively against the NZBs' category meta. Consulted before the
+	//built-in mapping of common names such as "tv" or "Movies > HD".
+	Categories map[string]int
+	//Results per page when the request gives no limit, and the most a request may ask for. 100 if zero.
+	Limit int
+	//Whether feed links take their scheme and host from the X-Forwarded-Proto and X-Forwarded-Host headers. Only set it behind
+	//a reverse proxy that sets them; otherwise links use the request's own host.
+	TrustForwardedHeaders bool
+}
+
+//An http.Handler serving the newznab API over a directory of NZBs. The directory is rescanned when its contents change.
+type Server struct {
+	options ServerOptions
+	mu sync.Mutex
+	entries []entry
+	//Modification times of the scanned directories, used to notice changes.
+	scanned map[string]time.Time
+}
+
+//An indexed NZB.
+type entry struct {
+	guid string
+	path string
+	title string
+	category string
+	categoryID int
+	tags []string
+	size int64
+	posted time.Time
+	files int
+	groups []string
+	poster string
+	password bool
+}
+
+//RSS document written by the server. Field names carry the newznab prefix, declared on the root element.
+type feed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string `xml:"version,attr"`
+	Atom string `xml:"xmlns:atom,attr"`
+	Newznab string `xml:"xmlns:newznab,attr"`
+	Channel feedChannel `xml:"channel"`
+}
+
+type feedChannel struct {
+	Title string `xml:"title"`
+	Description string `xml:"description"`
+	Response feedResponse `xml:"newznab:response"`
+	Items []feedItem `xml:"item"`
+}
+
+type feedResponse struct {
+	Offset int `xml:"offset,attr"`
+	Total int `xml:"total,attr"`
+}
+
+type feedItem struct {
+	Title string `xml:"title"`
+	GUID feedGUID `xml:"guid"`
+	Link string `xml:"link"`
+	PubDate string `xml:"pubDate,omitempty"`
+	Category string `xml:"category,omitempty"`
+	Description string `xml:"description"`
+	Enclosure enclosure `xml:"enclosure"`
+	Attrs []feedAttr `xml:"newznab:attr"`
+}
+
+type feedGUID struct {
+	IsPermaLink bool `xml:"isPermaLink,attr"`
+	Value string `xml:",chardata"`
+}
+
+type feedAttr struct {
+	Name string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
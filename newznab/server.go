@@ -0,0 +1,542 @@
+package newznab
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Standard newznab categories, reported by t=caps and used to map category names.
+var DefaultCategories = []CapsCategory{
+	{ID: 2000, Name: "Movies", Subcategories: []CapsCategory{{ID: 2030, Name: "SD"}, {ID: 2040, Name: "HD"}, {ID: 2045, Name: "UHD"}}},
+	{ID: 3000, Name: "Audio", Subcategories: []CapsCategory{{ID: 3010, Name: "MP3"}, {ID: 3040, Name: "Lossless"}}},
+	{ID: 4000, Name: "PC", Subcategories: []CapsCategory{{ID: 4050, Name: "Games"}}},
+	{ID: 5000, Name: "TV", Subcategories: []CapsCategory{{ID: 5030, Name: "SD"}, {ID: 5040, Name: "HD"}, {ID: 5045, Name: "UHD"}, {ID: 5070, Name: "Anime"}}},
+	{ID: 6000, Name: "XXX"},
+	{ID: 7000, Name: "Books", Subcategories: []CapsCategory{{ID: 7020, Name: "Ebook"}}},
+	{ID: 8000, Name: "Other", Subcategories: []CapsCategory{{ID: 8010, Name: "Misc"}}},
+}
+
+//Common category names that are not spelled like the standard ones.
+var categoryAliases = map[string]int{
+	"movie": 2000,
+	"films": 2000,
+	"music": 3000,
+	"apps": 4000,
+	"software": 4000,
+	"games": 4050,
+	"series": 5000,
+	"anime": 5070,
+	"ebook": 7020,
+	"ebooks": 7020,
+	"misc": 8010,
+}
+
+//Newznab categories of NZBs without a category, and of categories that could not be mapped.
+const (
+	CategoryOther = 8000
+	CategoryMisc = 8010
+)
+
+//Separators normalized away when comparing names and queries.
+var separatorPattern = regexp.MustCompile(`[\s._\-/>:,()\[\]]+`)
+
+//Lowercases text and reduces separators to single spaces.
+func normalize(text string) string {
+	return strings.TrimSpace(separatorPattern.ReplaceAllString(strings.ToLower(text), " "))
+}
+
+//Creates a server over a directory of NZBs, indexing it right away.
+func NewServer(options ServerOptions) (*Server, error) {
+	if options.Limit <= 0 {
+		options.Limit = 100
+	}
+	if options.Title == "" {
+		options.Title = "nzbgo"
+	}
+	server := &Server{options: options}
+	if err := server.Reload(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+//Rescans the directory. NZBs that fail to parse are left out of the index.
+func (s *Server) Reload() error {
+	scanned := map[string]time.Time{}
+	var entries []entry
+	err := filepath.WalkDir(s.options.Directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			scanned[path] = info.ModTime()
+			return nil
+		}
+		if !isNzbFile(d.Name()) {
+			return nil
+		}
+		nzb, err := parser.FromFile(path)
+		if err != nil || len(nzb.Files) == 0 {
+			return nil
+		}
+		relative, _ := filepath.Rel(s.options.Directory, path)
+		entries = append(entries, s.index(nzb, path, relative))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	//Newest first, as indexers list them.
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].posted.Equal(entries[j].posted) {
+			return entries[i].posted.After(entries[j].posted)
+		}
+		return entries[i].title < entries[j].title
+	})
+
+	s.mu.Lock()
+	s.entries, s.scanned = entries, scanned
+	s.mu.Unlock()
+	return nil
+}
+
+//Rescans the directory if a file was added, removed or renamed since the last scan.
+func (s *Server) refresh() error {
+	s.mu.Lock()
+	scanned := s.scanned
+	s.mu.Unlock()
+
+	changed := false
+	count := 0
+	filepath.WalkDir(s.options.Directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		count++
+		info, err := d.Info()
+		if last, ok := scanned[path]; err != nil || !ok || !last.Equal(info.ModTime()) {
+			changed = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if !changed && count == len(scanned) {
+		return nil
+	}
+	return s.Reload()
+}
+
+//Checks whether a filename is an NZB, compressed or not.
+func isNzbFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".nzb") || strings.HasSuffix(lower, ".nzb.gz")
+}
+
+//Builds the index entry of an NZB.
+func (s *Server) index(nzb *parser.Nzb, path string, relative string) entry {
+	hash := sha1.Sum([]byte(filepath.ToSlash(relative)))
+	e := entry{
+		guid: hex.EncodeToString(hash[:]),
+		path: path,
+		title: strings.TrimSpace(parser.Title(nzb.Head.Meta)),
+		category: parser.Category(nzb.Head.Meta),
+		tags: parser.Tags(nzb.Head.Meta),
+		size: int64(parser.Size(nzb)),
+		posted: parser.Posted(nzb),
+		files: len(nzb.Files),
+		groups: parser.Groups(nzb),
+		password: len(parser.Passwords(nzb.Head.Meta)) > 0,
+	}
+	if e.title == "" {
+		e.title = parser.StripNzbExtension(filepath.Base(path))
+	}
+	if len(nzb.Files) > 0 {
+		e.poster = nzb.Files[0].Poster
+	}
+	e.categoryID = s.MapCategory(e.category)
+	return e
+}
+
+//Maps a local category to a newznab category ID: configured names come first, then whatever CategoryID recognizes. Anything else is
+//Other > Misc.
+func (s *Server) MapCategory(category string) int {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return CategoryOther
+	}
+	for name, id := range s.options.Categories {
+		if strings.EqualFold(name, category) {
+			return id
+		}
+	}
+	if id, ok := CategoryID(category); ok {
+		return id
+	}
+	return CategoryMisc
+}
+
+//Recognizes a category as indexers and NZBs write it: a numeric ID such as "5040", a standard name such as "TV" or "Movies > HD", or
+//a common alias such as "misc.". The boolean reports whether anything matched.
+func CategoryID(category string) (int, bool) {
+	category = strings.TrimSpace(category)
+	if id, err := strconv.Atoi(category); err == nil && id > 0 {
+		return id, true
+	}
+
+	wanted := normalize(category)
+	if wanted == "" {
+		return 0, false
+	}
+	for _, parent := range DefaultCategories {
+		if normalize(parent.Name) == wanted {
+			return parent.ID, true
+		}
+		for _, sub := range parent.Subcategories {
+			if normalize(parent.Name+" "+sub.Name) == wanted {
+				return sub.ID, true
+			}
+		}
+	}
+	id, ok := categoryAliases[wanted]
+	return id, ok
+}
+
+//Names a category ID the way indexers label items, such as "TV > HD".
+func categoryName(id int) string {
+	for _, parent := range DefaultCategories {
+		if parent.ID == id {
+			return parent.Name
+		}
+		for _, sub := range parent.Subcategories {
+			if sub.ID == id {
+				return parent.Name + " > " + sub.Name
+			}
+		}
+	}
+	return strconv.Itoa(id)
+}
+
+//Checks whether a category ID falls under any of the requested ones. A parent category such as 5000 covers its subcategories.
+func inCategories(id int, wanted []int) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, w := range wanted {
+		if id == w || (w%1000 == 0 && id/1000 == w/1000) {
+			return true
+		}
+	}
+	return false
+}
+
+//Serves the newznab API: t=caps, search, tvsearch, movie and get.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	t := query.Get("t")
+	if t == "" {
+		writeError(w, ErrMissingParameter)
+		return
+	}
+	//Capabilities are public, so clients can probe an indexer before a key is configured.
+	if t != "caps" && len(s.options.APIKeys) > 0 && !slices.Contains(s.options.APIKeys, query.Get("apikey")) {
+		writeError(w, ErrIncorrectCredentials)
+		return
+	}
+	if err := s.refresh(); err != nil {
+		writeError(w, ErrUnknown)
+		return
+	}
+
+	switch t {
+	case "caps":
+		s.serveCaps(w)
+	case "search", "tvsearch", "movie":
+		s.serveSearch(w, r, t)
+	case "get":
+		s.serveGet(w, query.Get("id"))
+	default:
+		writeError(w, ErrNoSuchFunction)
+	}
+}
+
+//Writes an XML document with its declaration.
+func writeXML(w http.ResponseWriter, contentType string, document any) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	io.WriteString(w, xml.Header)
+	w.Write(data)
+}
+
+//Writes a newznab error document. Indexers answer errors with 200, so clients read the body.
+func writeError(w http.ResponseWriter, apiErr *Error) {
+	writeXML(w, "application/xml; charset=utf-8", apiErr)
+}
+
+func (s *Server) serveCaps(w http.ResponseWriter) {
+	caps := Caps{
+		Server: CapsServer{Version: "1.0", Title: s.options.Title},
+		Limits: CapsLimits{Max: s.options.Limit, Default: s.options.Limit},
+		Searching: CapsSearching{
+			Search: CapsSearch{Available: "yes", SupportedParams: "q"},
+			TVSearch: CapsSearch{Available: "yes", SupportedParams: "q,season,ep"},
+			MovieSearch: CapsSearch{Available: "yes", SupportedParams: "q,imdbid"},
+		},
+		Categories: append([]CapsCategory{}, DefaultCategories...),
+	}
+
+	//Configured IDs outside the standard table are listed under their local names.
+	known := map[int]bool{}
+	for _, parent := range DefaultCategories {
+		known[parent.ID] = true
+		for _, sub := range parent.Subcategories {
+			known[sub.ID] = true
+		}
+	}
+	names := make([]string, 0, len(s.options.Categories))
+	for name := range s.options.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if id := s.options.Categories[name]; !known[id] {
+			known[id] = true
+			caps.Categories = append(caps.Categories, CapsCategory{ID: id, Name: name})
+		}
+	}
+	writeXML(w, "application/xml; charset=utf-8", caps)
+}
+
+//Compiles a matcher for season and episode numbers, given as "1", "01" or "S01"/"E02". Returns nil if neither is given.
+func episodePattern(season string, episode string) *regexp.Regexp {
+	number := func(value string, prefix string) string {
+		value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), prefix)
+		if n, err := strconv.Atoi(value); err == nil {
+			return strconv.Itoa(n)
+		}
+		return regexp.QuoteMeta(value)
+	}
+	switch {
+	case season != "" && episode != "":
+		s, e := number(season, "s"), number(episode, "e")
+		return regexp.MustCompile(fmt.Sprintf(`(?i)(^|[^a-z0-9])(s0*%s[ .]?e0*%s|0*%sx0*%s)([^0-9]|$)`, s, e, s, e))
+	case season != "":
+		s := number(season, "s")
+		return regexp.MustCompile(fmt.Sprintf(`(?i)(^|[^a-z0-9])(s0*%s([^0-9]|$)|0*%sx\d)`, s, s))
+	}
+	return nil
+}
+
+func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, t string) {
+	query := r.URL.Query()
+
+	var categories []int
+	for _, c := range strings.Split(query.Get("cat"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(c)); err == nil {
+			categories = append(categories, id)
+		}
+	}
+	//Type-specific searches only cover their own categories unless told otherwise.
+	if len(categories) == 0 && t == "tvsearch" {
+		categories = []int{5000}
+	}
+	if len(categories) == 0 && t == "movie" {
+		categories = []int{2000}
+	}
+
+	terms := strings.Fields(normalize(query.Get("q")))
+	var episodes *regexp.Regexp
+	if t == "tvsearch" {
+		episodes = episodePattern(query.Get("season"), query.Get("ep"))
+	}
+	imdb := strings.TrimPrefix(strings.ToLower(query.Get("imdbid")), "tt")
+	maxAge, _ := strconv.Atoi(query.Get("maxage"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > s.options.Limit {
+		limit = s.options.Limit
+	}
+
+	s.mu.Lock()
+	entries := s.entries
+	s.mu.Unlock()
+
+	var matches []entry
+	for _, e := range entries {
+		if !inCategories(e.categoryID, categories) {
+			continue
+		}
+		if maxAge > 0 && !e.posted.IsZero() && time.Since(e.posted) > time.Duration(maxAge)*24*time.Hour {
+			continue
+		}
+		searchable := " " + normalize(e.title+" "+strings.Join(e.tags, " ")) + " "
+		matched := true
+		for _, term := range terms {
+			if !strings.Contains(searchable, term) {
+				matched = false
+				break
+			}
+		}
+		if !matched || (episodes != nil && !episodes.MatchString(e.title)) {
+			continue
+		}
+		if t == "movie" && imdb != "" && !strings.Contains(strings.ToLower(strings.Join(e.tags, " ")), imdb) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+
+	document := feed{
+		Version: "2.0",
+		Atom: "http://www.w3.org/2005/Atom",
+		Newznab: "http://www.newznab.com/DTD/2010/feeds/attributes/",
+		Channel: feedChannel{
+			Title: s.options.Title,
+			Description: s.options.Title + " API results",
+			Response: feedResponse{Offset: offset, Total: len(matches)},
+		},
+	}
+	if offset < len(matches) {
+		matches = matches[max(offset, 0):]
+		for _, e := range matches[:min(limit, len(matches))] {
+			document.Channel.Items = append(document.Channel.Items, s.feedItem(r, e))
+		}
+	}
+	writeXML(w, "application/rss+xml; charset=utf-8", document)
+}
+
+//Returns the first value of a proxy header; proxies in a chain append theirs after the client-facing one.
+func forwardedValue(r *http.Request, header string) string {
+	value, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(value)
+}
+
+//Builds the RSS item of an entry, linking to t=get on this server. The proxy headers are only consulted when the options trust them.
+func (s *Server) feedItem(r *http.Request, e entry) feedItem {
+	target := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		target.Scheme = "https"
+	}
+	if s.options.TrustForwardedHeaders {
+		if proto := forwardedValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			target.Scheme = proto
+		}
+		if host := forwardedValue(r, "X-Forwarded-Host"); host != "" {
+			target.Host = host
+		}
+	}
+	query := url.Values{"t": {"get"}, "id": {e.guid}}
+	if key := r.URL.Query().Get("apikey"); key != "" {
+		query.Set("apikey", key)
+	}
+	target.RawQuery = query.Encode()
+	link := target.String()
+
+	item := feedItem{
+		Title: e.title,
+		GUID: feedGUID{Value: e.guid},
+		Link: link,
+		Category: categoryName(e.categoryID),
+		Description: e.title,
+		Enclosure: enclosure{URL: link, Length: e.size, Type: "application/x-nzb"},
+	}
+	if !e.posted.IsZero() {
+		item.PubDate = e.posted.Format(time.RFC1123Z)
+	}
+
+	addAttr := func(name string, value string) {
+		item.Attrs = append(item.Attrs, feedAttr{Name: name, Value: value})
+	}
+	if parent := e.categoryID / 1000 * 1000; parent != e.categoryID {
+		addAttr("category", strconv.Itoa(parent))
+	}
+	addAttr("category", strconv.Itoa(e.categoryID))
+	addAttr("size", strconv.FormatInt(e.size, 10))
+	addAttr("files", strconv.Itoa(e.files))
+	if e.poster != "" {
+		addAttr("poster", e.poster)
+	}
+	for _, g := range e.groups {
+		addAttr("group", g)
+	}
+	if !e.posted.IsZero() {
+		addAttr("usenetdate", e.posted.Format(time.RFC1123Z))
+	}
+	password := "0"
+	if e.password {
+		password = "1"
+	}
+	addAttr("password", password)
+	for _, tag := range e.tags {
+		addAttr("tag", tag)
+	}
+	return item
+}
+
+func (s *Server) serveGet(w http.ResponseWriter, id string) {
+	if id == "" {
+		writeError(w, ErrMissingParameter)
+		return
+	}
+
+	s.mu.Lock()
+	var found *entry
+	for i := range s.entries {
+		if s.entries[i].guid == id {
+			found = &s.entries[i]
+			break
+		}
+	}
+	s.mu.Unlock()
+	if found == nil {
+		writeError(w, ErrNoSuchItem)
+		return
+	}
+
+	data, err := os.ReadFile(found.path)
+	if err == nil && parser.IsGzip(found.path) {
+		var reader *gzip.Reader
+		if reader, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			data, err = io.ReadAll(reader)
+		}
+	}
+	if err != nil {
+		writeError(w, ErrNoSuchItem)
+		return
+	}
+
+	//The X-DNZB headers let download clients name and file the job without parsing the NZB.
+	w.Header().Set("Content-Type", "application/x-nzb")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", found.title+".nzb"))
+	w.Header().Set("X-DNZB-Name", found.title)
+	if found.category != "" {
+		w.Header().Set("X-DNZB-Category", found.category)
+	}
+	w.Write(data)
+}
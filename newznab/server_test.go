@@ -0,0 +1,194 @@
+package newznab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Writes a copy of the sample NZB with the given meta, posted at the given time.
+func writeNzb(t *testing.T, path string, posted time.Time, meta ...parser.Meta) {
+	nzb, err := parser.FromFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nzb.Head.Meta = meta
+	for i := range nzb.Files {
+		nzb.Files[i].Date = posted.Unix()
+	}
+	if err := parser.WriteFile(nzb, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startServer(t *testing.T) (*Client, string) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeNzb(t, filepath.Join(dir, "show1.nzb"), now.Add(-48*time.Hour),
+		parser.Meta{Type: "title", Value: "Show.Name.S01E01.720p.HDTV-GROUP"}, parser.Meta{Type: "category", Value: "TV > HD"})
+	writeNzb(t, filepath.Join(dir, "show2.nzb"), now.Add(-24*time.Hour),
+		parser.Meta{Type: "title", Value: "Show.Name.S01E02.720p.HDTV-GROUP"}, parser.Meta{Type: "category", Value: "tv"},
+		parser.Meta{Type: "password", Value: "secret"})
+	os.Mkdir(filepath.Join(dir, "movies"), 0755)
+	writeNzb(t, filepath.Join(dir, "movies", "movie.nzb"), now.Add(-30*24*time.Hour),
+		parser.Meta{Type: "title", Value: "The.Matrix.1999.1080p.BluRay-GROUP"}, parser.Meta{Type: "category", Value: "Movies > HD"},
+		parser.Meta{Type: "tag", Value: "imdb:tt0133093"})
+	writeNzb(t, filepath.Join(dir, "cartoon.nzb"), now.Add(-time.Hour),
+		parser.Meta{Type: "title", Value: "Cartoon.Show.S02E05.1080p"}, parser.Meta{Type: "category", Value: "Cartoons"})
+	os.WriteFile(filepath.Join(dir, "broken.nzb"), []byte("<nzb"), 0644)
+
+	server, err := NewServer(ServerOptions{Directory: dir, APIKeys: []string{"key"}, Categories: map[string]int{"cartoons": 5070}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return NewClient(httpServer.URL+"/api", "key"), dir
+}
+
+func titles(results *Results) []string {
+	var found []string
+	for _, item := range results.Items {
+		found = append(found, item.Title)
+	}
+	return found
+}
+
+func TestServerSearches(t *testing.T) {
+	client, _ := startServer(t)
+	ctx := context.Background()
+
+	caps, err := client.Caps(ctx)
+	if err != nil || caps.Searching.TVSearch.Available != "yes" || len(caps.Categories) != len(DefaultCategories) {
+		t.Fatalf("unexpected caps: %+v, %v", caps, err)
+	}
+
+	results, err := client.Search(ctx, Query{Q: "show name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(results); len(got) != 2 || got[0] != "Show.Name.S01E02.720p.HDTV-GROUP" {
+		t.Fatalf("unexpected search results, newest first: %v", got)
+	}
+
+	item := results.Items[0]
+	if item.Password != PasswordYes || item.Size == 0 || item.Categories[len(item.Categories)-1] != 5000 || item.Published.IsZero() {
+		t.Fatalf("unexpected item: %+v", item)
+	}
+	if results.Items[1].Category != "TV > HD" || results.Items[1].Categories[1] != 5040 {
+		t.Fatalf("category names were not mapped: %+v", results.Items[1])
+	}
+
+	results, err = client.TVSearch(ctx, Query{Season: "1", Episode: "1"})
+	if got := titles(results); err != nil || len(got) != 1 || got[0] != "Show.Name.S01E01.720p.HDTV-GROUP" {
+		t.Fatalf("unexpected tvsearch results: %v, %v", got, err)
+	}
+	//The configured mapping files "Cartoons" under TV > Anime.
+	results, err = client.TVSearch(ctx, Query{Season: "S02", Categories: []int{5070}})
+	if got := titles(results); err != nil || len(got) != 1 || got[0] != "Cartoon.Show.S02E05.1080p" {
+		t.Fatalf("unexpected tvsearch results: %v, %v", got, err)
+	}
+
+	results, err = client.Movie(ctx, Query{IMDBID: "tt0133093"})
+	if got := titles(results); err != nil || len(got) != 1 || results.Items[0].Categories[1] != 2040 {
+		t.Fatalf("unexpected movie results: %v, %v", got, err)
+	}
+	results, err = client.Search(ctx, Query{MaxAge: 7})
+	if err != nil || results.Total != 3 {
+		t.Fatalf("maxage did not exclude old posts: %v, %v", titles(results), err)
+	}
+	results, err = client.Search(ctx, Query{Limit: 1, Offset: 1})
+	if err != nil || results.Total != 4 || len(results.Items) != 1 || results.Items[0].Title != "Show.Name.S01E02.720p.HDTV-GROUP" {
+		t.Fatalf("unexpected page: %v, %v", titles(results), err)
+	}
+}
+
+func TestServerGetAndAuth(t *testing.T) {
+	client, dir := startServer(t)
+	ctx := context.Background()
+
+	if _, err := NewClient(client.BaseURL, "wrong").Search(ctx, Query{}); !errors.Is(err, ErrIncorrectCredentials) {
+		t.Fatalf("expected ErrIncorrectCredentials, got %v", err)
+	}
+	if _, err := client.Download(ctx, Item{GUID: "missing"}); !errors.Is(err, ErrNoSuchItem) {
+		t.Fatalf("expected ErrNoSuchItem, got %v", err)
+	}
+
+	//NZBs added later are picked up on the next request.
+	writeNzb(t, filepath.Join(dir, "late.nzb"), time.Now(), parser.Meta{Type: "title", Value: "Late.Arrival"})
+	results, err := client.Search(ctx, Query{Q: "late arrival"})
+	if err != nil || len(results.Items) != 1 {
+		t.Fatalf("new NZB was not indexed: %v, %v", results, err)
+	}
+
+	//Without a title, the filename up to its .nzb extension names the release.
+	writeNzb(t, filepath.Join(dir, "Untitled.Movie.2020.1080p.nzb"), time.Now())
+	untitled, err := client.Search(ctx, Query{Q: "untitled"})
+	if err != nil || len(untitled.Items) != 1 || untitled.Items[0].Title != "Untitled.Movie.2020.1080p" {
+		t.Fatalf("unexpected fallback title: %v, %v", titles(untitled), err)
+	}
+
+	nzb, err := client.Download(ctx, results.Items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parser.Title(nzb.Head.Meta) != "Late.Arrival" || len(nzb.Files) == 0 {
+		t.Fatalf("unexpected NZB: %+v", nzb.Head)
+	}
+}
+
+//Adds fixed headers to every request.
+type headerTransport map[string]string
+
+func (h headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	for name, value := range h {
+		r.Header.Set(name, value)
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestServerFeedLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeNzb(t, filepath.Join(dir, "show.nzb"), time.Now(), parser.Meta{Type: "title", Value: "Show.Name.S01E01"})
+	proxied := &http.Client{Transport: headerTransport{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "proxy.example, inner.example"}}
+
+	for _, trusted := range []bool{false, true} {
+		server, err := NewServer(ServerOptions{Directory: dir, APIKeys: []string{"a&t=b"}, TrustForwardedHeaders: trusted})
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpServer := httptest.NewServer(server)
+		defer httpServer.Close()
+
+		client := &Client{BaseURL: httpServer.URL + "/api", APIKey: "a&t=b", HTTP: proxied}
+		results, err := client.Search(context.Background(), Query{})
+		if err != nil || len(results.Items) != 1 {
+			t.Fatalf("unexpected results: %v, %v", results, err)
+		}
+		link, err := url.Parse(results.Items[0].Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//The key is escaped rather than able to add parameters of its own.
+		if query := link.Query(); query.Get("apikey") != "a&t=b" || query.Get("t") != "get" || query.Get("id") != results.Items[0].GUID {
+			t.Fatalf("unexpected link query %q", link.RawQuery)
+		}
+		expected := httpServer.URL + "/api"
+		if trusted {
+			expected = "https://proxy.example/api"
+		}
+		if got := link.Scheme + "://" + link.Host + link.Path; got != expected {
+			t.Fatalf("trusted %v: link points at %q, expected %q", trusted, got, expected)
+		}
+	}
+}
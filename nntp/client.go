@@ -0,0 +1,374 @@
+// Allows for communication with Usenet servers over NNTP (RFC 3977), including authentication (RFC 4643) and command pipelining.
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Returns the host:port a config points to, applying the default port.
+func Address(config Config) string {
+	port := config.Port
+	if port == 0 {
+		port = 119
+		if config.TLS {
+			port = 563
+		}
+	}
+	return net.JoinHostPort(config.Host, strconv.Itoa(port))
+}
+
+//Connects to a server, reads its greeting and authenticates if credentials are configured.
+func Dial(config Config) (*Client, error) {
+	return DialContext(context.Background(), config)
+}
+
+//Connects to a server like Dial, aborting if ctx is cancelled before the connection is established.
+func DialContext(ctx context.Context, config Config) (*Client, error) {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+
+	var (
+		conn net.Conn
+		err error
+	)
+	if config.TLS {
+		tlsDialer := &tls.Dialer{
+			NetDialer: dialer,
+			Config: &tls.Config{
+				ServerName: config.Host,
+				InsecureSkipVerify: config.InsecureSkipVerify,
+			},
+		}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", Address(config))
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", Address(config))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(conn, config.Timeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if config.Username != "" {
+		if err := client.Authenticate(config.Username, config.Password); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+//Wraps an established connection, reading the server greeting. Useful for custom transports.
+func NewClient(conn net.Conn, timeout time.Duration) (*Client, error) {
+	client := &Client{
+		conn: conn,
+		text: textproto.NewConn(conn),
+		timeout: timeout,
+	}
+
+	client.touch()
+	code, message, err := client.text.ReadCodeLine(0)
+	if err != nil {
+		return nil, err
+	}
+	if code != 200 && code != 201 {
+		return nil, &Error{Code: code, Message: message}
+	}
+	client.Welcome = message
+	client.PostingAllowed = code == 200
+	return client, nil
+}
+
+//Extends the connection deadline by the configured timeout.
+func (c *Client) touch() {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+//Converts errors from textproto into the module's error type.
+func convertError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return &Error{Code: protoErr.Code, Message: protoErr.Msg}
+	}
+	return err
+}
+
+//Wraps a message-ID in angle brackets if it is not already, as NZBs store them bare.
+func formatID(id string) string {
+	if strings.HasPrefix(id, "<") {
+		return id
+	}
+	return "<" + id + ">"
+}
+
+//Sends a command without waiting for its response, returning the pipeline ID to read it with. The ID is returned even when
+//sending failed, as its turn in the response sequence must still be taken, with skip, or later commands would wait for it forever.
+func (c *Client) send(format string, args ...any) (uint, error) {
+	c.touch()
+	id := c.text.Next()
+	c.text.StartRequest(id)
+	err := c.text.PrintfLine(format, args...)
+	c.text.EndRequest(id)
+	return id, err
+}
+
+//Takes the response turn of a command that was never sent, letting the commands after it read their responses.
+func (c *Client) skip(id uint) {
+	c.text.StartResponse(id)
+	c.text.EndResponse(id)
+}
+
+//Reads the status line of a previously sent command. When readBody is set and the status matches, the dot-terminated block that
+//follows is returned as well.
+func (c *Client) receive(id uint, expectCode int, readBody bool) (string, []byte, error) {
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+
+	c.touch()
+	_, message, err := c.text.ReadCodeLine(expectCode)
+	if err != nil {
+		return message, nil, convertError(err)
+	}
+	if !readBody {
+		return message, nil, nil
+	}
+
+	body, err := c.text.ReadDotBytes()
+	if err != nil {
+		return message, nil, err
+	}
+	return message, body, nil
+}
+
+//Sends a command and waits for its response.
+func (c *Client) command(expectCode int, readBody bool, format string, args ...any) (string, []byte, error) {
+	id, err := c.send(format, args...)
+	if err != nil {
+		c.skip(id)
+		return "", nil, err
+	}
+	return c.receive(id, expectCode, readBody)
+}
+
+//Authenticates using AUTHINFO USER/PASS. Failures are returned as ErrAuthRejected or ErrAuthOutOfSequence.
+func (c *Client) Authenticate(username string, password string) error {
+	id, err := c.send("AUTHINFO USER %s", username)
+	if err != nil {
+		c.skip(id)
+		return err
+	}
+
+	c.text.StartResponse(id)
+	c.touch()
+	code, message, err := c.text.ReadCodeLine(0)
+	c.text.EndResponse(id)
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case 281:
+		return nil
+	case 381:
+		_, _, err = c.command(281, false, "AUTHINFO PASS %s", password)
+		return err
+	default:
+		return &Error{Code: code, Message: message}
+	}
+}
+
+//Selects a newsgroup, returning its article count and number range.
+func (c *Client) Group(name string) (*GroupInfo, error) {
+	message, _, err := c.command(211, false, "GROUP %s", name)
+	if err != nil {
+		return nil, err
+	}
+
+	//"211 count low high group"
+	fields := strings.Fields(message)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("nntp: malformed GROUP response %q", message)
+	}
+	info := &GroupInfo{Name: fields[3]}
+	info.Count, _ = strconv.ParseInt(fields[0], 10, 64)
+	info.Low, _ = strconv.ParseInt(fields[1], 10, 64)
+	info.High, _ = strconv.ParseInt(fields[2], 10, 64)
+	return info, nil
+}
+
+//Checks whether an article exists without transferring it. A missing article yields ErrNoSuchArticle.
+func (c *Client) Stat(messageID string) error {
+	_, _, err := c.command(223, false, "STAT %s", formatID(messageID))
+	return err
+}
+
+//Retrieves the headers of an article.
+func (c *Client) Head(messageID string) (textproto.MIMEHeader, error) {
+	_, data, err := c.command(221, true, "HEAD %s", formatID(messageID))
+	if err != nil {
+		return nil, err
+	}
+	return parseHeader(data)
+}
+
+//Retrieves the body of an article, with dot-stuffing removed.
+func (c *Client) Body(messageID string) ([]byte, error) {
+	_, data, err := c.command(222, true, "BODY %s", formatID(messageID))
+	return data, err
+}
+
+//Retrieves the body of an article, satisfying Fetcher. ctx is only checked before the command is sent; use Config.Timeout to bound
+//the round trip.
+func (c *Client) Fetch(ctx context.Context, messageID string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Body(messageID)
+}
+
+//Retrieves a full article, split into its headers and body.
+func (c *Client) Article(messageID string) (*Article, error) {
+	_, data, err := c.command(220, true, "ARTICLE %s", formatID(messageID))
+	if err != nil {
+		return nil, err
+	}
+
+	headerData, body, _ := bytes.Cut(data, []byte("\n\n"))
+	header, err := parseHeader(append(headerData, '\n'))
+	if err != nil {
+		return nil, err
+	}
+	return &Article{
+		MessageID: strings.Trim(header.Get("Message-ID"), "<>"),
+		Header: header,
+		Body: body,
+	}, nil
+}
+
+//Parses a block of header lines into a MIMEHeader.
+func parseHeader(data []byte) (textproto.MIMEHeader, error) {
+	reader := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n"))))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return header, nil
+}
+
+//Posts an article, given in wire form (headers, a blank line and the body). Dot-stuffing is applied here. The request side of the
+//connection is held until the article is written, so pipelined commands from other goroutines cannot interleave with it.
+func (c *Client) Post(article []byte) error {
+	id := c.text.Next()
+	c.text.StartRequest(id)
+	requestDone := false
+	endRequest := func() {
+		if !requestDone {
+			c.text.EndRequest(id)
+			requestDone = true
+		}
+	}
+	defer endRequest()
+
+	c.touch()
+	if err := c.text.PrintfLine("POST"); err != nil {
+		c.skip(id)
+		return err
+	}
+
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	if _, _, err := c.text.ReadCodeLine(340); err != nil {
+		return convertError(err)
+	}
+
+	writer := c.text.DotWriter()
+	if _, err := writer.Write(article); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	endRequest()
+
+	c.touch()
+	if _, _, err := c.text.ReadCodeLine(240); err != nil {
+		return convertError(err)
+	}
+	return nil
+}
+
+//Issues STAT for every message-ID at once, then reads the responses back in order. The returned slice holds one entry per ID,
+//nil meaning the article exists.
+func (c *Client) PipelineStat(messageIDs []string) ([]error, error) {
+	results, _, err := c.pipeline("STAT", 223, false, messageIDs)
+	return results, err
+}
+
+//Issues BODY for every message-ID at once, then reads the bodies back in order. Per-article failures, such as ErrNoSuchArticle,
+//are reported in the errors slice; the final error is only set when the connection itself failed.
+func (c *Client) PipelineBody(messageIDs []string) ([][]byte, []error, error) {
+	results, bodies, err := c.pipeline("BODY", 222, true, messageIDs)
+	return bodies, results, err
+}
+
+//Sends the same command for several message-IDs before reading any responses.
+func (c *Client) pipeline(verb string, expectCode int, readBody bool, messageIDs []string) ([]error, [][]byte, error) {
+	ids := make([]uint, 0, len(messageIDs))
+	for _, m := range messageIDs {
+		id, err := c.send("%s %s", verb, formatID(m))
+		if err != nil {
+			//As below, the commands already issued are drained over a closed connection, then the failed one's turn is taken.
+			c.Close()
+			for _, issued := range ids {
+				c.receive(issued, expectCode, readBody)
+			}
+			c.skip(id)
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	results := make([]error, len(ids))
+	bodies := make([][]byte, len(ids))
+	for i, id := range ids {
+		_, body, err := c.receive(id, expectCode, readBody)
+		if err != nil && !IsProtocolError(err) {
+			//The connection is unusable; closing it makes the outstanding responses fail fast so the pipeline is not left blocked.
+			c.Close()
+			for _, rest := range ids[i+1:] {
+				c.receive(rest, expectCode, readBody)
+			}
+			return nil, nil, err
+		}
+		results[i] = err
+		bodies[i] = body
+	}
+	return results, bodies, nil
+}
+
+//Politely ends the session with QUIT and closes the connection.
+func (c *Client) Quit() error {
+	c.command(205, false, "QUIT")
+	return c.Close()
+}
+
+//Closes the connection without sending QUIT.
+func (c *Client) Close() error {
+	return c.text.Close()
+}
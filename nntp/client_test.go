@@ -0,0 +1,235 @@
+package nntp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//Starts a minimal scripted server that knows a single article and a single user.
+func startServer(t *testing.T) Config {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, listener)
+}
+
+//Runs the scripted server on a listener, returning the config to reach it.
+func serve(t *testing.T, listener net.Listener) Config {
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				write := func(s string) { conn.Write([]byte(s + "\r\n")) }
+				write("200 test server ready")
+				authed := false
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					verb := strings.ToUpper(fields[0])
+					switch {
+					case verb == "QUIT":
+						write("205 bye")
+						return
+					case verb == "AUTHINFO" && fields[1] == "USER":
+						write("381 password required")
+					case verb == "AUTHINFO" && fields[1] == "PASS":
+						if fields[2] == "secret" {
+							authed = true
+							write("281 ok")
+						} else {
+							write("481 rejected")
+						}
+					case !authed:
+						write("480 authentication required")
+					case verb == "GROUP":
+						write("211 3 1 3 " + fields[1])
+					case fields[1] != "<a@test>":
+						write("430 no such article")
+					case verb == "STAT":
+						write("223 0 <a@test>")
+					case verb == "BODY":
+						write("222 0 <a@test>")
+						write("..dotted line")
+						write("second line")
+						write(".")
+					case verb == "HEAD", verb == "ARTICLE":
+						write(map[string]string{"HEAD": "221", "ARTICLE": "220"}[verb] + " 0 <a@test>")
+						write("Subject: test " + strconv.Itoa(len(verb)))
+						write("Message-ID: <a@test>")
+						if verb == "ARTICLE" {
+							write("")
+							write("body")
+						}
+						write(".")
+					}
+				}
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return Config{Host: host, Port: portNumber, Username: "user", Password: "secret"}
+}
+
+func TestClientCommands(t *testing.T) {
+	config := startServer(t)
+	client, err := Dial(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	info, err := client.Group("alt.binaries.test")
+	if err != nil || info.Count != 3 || info.Name != "alt.binaries.test" {
+		t.Fatalf("unexpected GROUP result: %+v, %v", info, err)
+	}
+	if err := client.Stat("a@test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Stat("missing@test"); !IsNoSuchArticle(err) {
+		t.Fatalf("expected ErrNoSuchArticle, got %v", err)
+	}
+
+	body, err := client.Body("a@test")
+	if err != nil || string(body) != ".dotted line\nsecond line\n" {
+		t.Fatalf("unexpected body %q, %v", body, err)
+	}
+
+	header, err := client.Head("a@test")
+	if err != nil || header.Get("Subject") != "test 4" {
+		t.Fatalf("unexpected header %v, %v", header, err)
+	}
+
+	article, err := client.Article("a@test")
+	if err != nil || article.MessageID != "a@test" || string(article.Body) != "body\n" {
+		t.Fatalf("unexpected article %+v, %v", article, err)
+	}
+
+	bodies, results, err := client.PipelineBody([]string{"a@test", "missing@test", "a@test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0] != nil || !IsNoSuchArticle(results[1]) || results[2] != nil || string(bodies[2]) != string(body) {
+		t.Fatalf("unexpected pipeline results: %v", results)
+	}
+}
+
+func TestClientAuthFailure(t *testing.T) {
+	config := startServer(t)
+	config.Password = "wrong"
+	if _, err := Dial(config); !IsAuthFailure(err) {
+		t.Fatalf("expected an authentication failure, got %v", err)
+	}
+}
+
+//Connection that holds the first write of a held line until released, and fails the write of a failing line.
+type flakyConn struct {
+	net.Conn
+	hold string
+	fail string
+	held chan struct{}
+	release chan struct{}
+	once sync.Once
+}
+
+func (c *flakyConn) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), c.fail) {
+		return 0, errors.New("write timed out")
+	}
+	if strings.Contains(string(p), c.hold) {
+		c.once.Do(func() {
+			close(c.held)
+			<-c.release
+		})
+	}
+	return c.Conn.Write(p)
+}
+
+func TestDialTLS(t *testing.T) {
+	//httptest carries a self-signed certificate for 127.0.0.1, which the scripted server is served behind.
+	https := httptest.NewUnstartedServer(http.NotFoundHandler())
+	https.StartTLS()
+	https.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := serve(t, tls.NewListener(listener, https.TLS))
+	config.TLS = true
+
+	if _, err := Dial(config); err == nil {
+		t.Fatal("self-signed certificate was accepted without InsecureSkipVerify")
+	}
+
+	config.InsecureSkipVerify = true
+	client, err := Dial(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+	if _, ok := client.conn.(*tls.Conn); !ok {
+		t.Fatalf("connection is a %T, not TLS", client.conn)
+	}
+	if err := client.Stat("a@test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientSendFailureDoesNotBlock(t *testing.T) {
+	config := startServer(t)
+	raw, err := net.Dial("tcp", Address(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &flakyConn{Conn: raw, hold: "a@test", fail: "broken@test", held: make(chan struct{}), release: make(chan struct{})}
+	client, err := NewClient(conn, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Authenticate(config.Username, config.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	//A pipeline fails sending its second command after another goroutine's command went out between the two.
+	pipelined := make(chan error, 1)
+	go func() {
+		_, err := client.PipelineStat([]string{"a@test", "broken@test"})
+		pipelined <- err
+	}()
+	<-conn.held
+	stat := make(chan error, 1)
+	go func() { stat <- client.Stat("b@test") }()
+	time.Sleep(50 * time.Millisecond)
+	close(conn.release)
+
+	if err := <-pipelined; err == nil {
+		t.Fatal("pipeline with a failed write succeeded")
+	}
+	select {
+	case <-stat:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a command sent before the failed one blocked")
+	}
+}
@@ -0,0 +1,52 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+)
+
+//An NNTP error response. Compare against the sentinel errors below with errors.Is, which matches on Code alone.
+type Error struct {
+	Code int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("nntp: %d %s", e.Code, e.Message)
+}
+
+//Matches any *Error carrying the same response code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+//Response codes the module treats specially.
+var (
+	ErrNoSuchArticle = &Error{Code: 430, Message: "no such article"}
+	ErrAuthRejected = &Error{Code: 481, Message: "authentication failed"}
+	ErrAuthOutOfSequence = &Error{Code: 482, Message: "authentication commands out of sequence"}
+	ErrAccessDenied = &Error{Code: 502, Message: "access denied"}
+	ErrNoSuchGroup = &Error{Code: 411, Message: "no such newsgroup"}
+	ErrPostingNotPermitted = &Error{Code: 440, Message: "posting not permitted"}
+	ErrPostingFailed = &Error{Code: 441, Message: "posting failed"}
+	ErrNoGroupSelected = &Error{Code: 412, Message: "no newsgroup selected"}
+	ErrUnknownCommand = &Error{Code: 500, Message: "unknown command"}
+)
+
+//Checks whether an error means the article does not exist on the server.
+func IsNoSuchArticle(err error) bool {
+	return errors.Is(err, ErrNoSuchArticle)
+}
+
+//Checks whether an error is an authentication failure (481 or 482).
+func IsAuthFailure(err error) bool {
+	return errors.Is(err, ErrAuthRejected) || errors.Is(err, ErrAuthOutOfSequence)
+}
+
+//Checks whether an error is a protocol-level response from the server, as opposed to a network failure.
+//Connections that returned such errors remain usable.
+func IsProtocolError(err error) bool {
+	var nntpErr *Error
+	return errors.As(err, &nntpErr)
+}
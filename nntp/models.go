@@ -0,0 +1,68 @@
+package nntp
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"time"
+)
+
+//Connection settings for a single Usenet server. Port defaults to 119, or 563 when TLS is enabled.
+type Config struct {
+	Host string
+	Port int
+	TLS bool
+	//Skips certificate verification; only meant for self-signed test servers.
+	InsecureSkipVerify bool
+	Username string
+	Password string
+	//Deadline applied to connecting and to every command's round trip. No deadline if zero.
+	Timeout time.Duration
+}
+
+//A connection to an NNTP server. Commands may be issued from several goroutines at once; they are pipelined over the connection
+//and their responses are read back in order.
+type Client struct {
+	conn net.Conn
+	text *textproto.Conn
+	timeout time.Duration
+	//Greeting line sent by the server when connecting.
+	Welcome string
+	//Whether the server allows posting, per its 200/201 greeting.
+	PostingAllowed bool
+}
+
+//Response to a GROUP command.
+type GroupInfo struct {
+	Name string
+	Count int64
+	Low int64
+	High int64
+}
+
+//A full article as returned by ARTICLE. Body has had its dot-stuffing removed and lines end in LF.
+type Article struct {
+	MessageID string
+	Header textproto.MIMEHeader
+	Body []byte
+}
+
+//Source of article bodies, implemented by *Client, pool.Pool and cache.Fetcher. Components that only need bodies, such as probes
+//and streams, accept this so they work over a single connection, a pool or a cache alike.
+type Fetcher interface {
+	Fetch(ctx context.Context, messageID string) ([]byte, error)
+}
+
+//A line of overview data, as returned by OVER/XOVER. Header values are unfolded; Date is zero if it could not be parsed.
+type Overview struct {
+	Number int64
+	Subject string
+	From string
+	Date time.Time
+	MessageID string
+	References string
+	Bytes int64
+	Lines int64
+	//Value of the Xref header when the server includes it, e.g. "news.example.com alt.binaries.test:1234".
+	Xref string
+}
@@ -0,0 +1,80 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+//Retrieves overview data for a range of article numbers in the selected group, using OVER and falling back to XOVER on servers
+//that predate RFC 3977. A high of zero requests every article from low onwards.
+func (c *Client) Over(low int64, high int64) ([]Overview, error) {
+	span := fmt.Sprintf("%d-", low)
+	if high > 0 {
+		span += strconv.FormatInt(high, 10)
+	}
+
+	_, data, err := c.command(224, true, "OVER %s", span)
+	if errors.Is(err, ErrUnknownCommand) {
+		_, data, err = c.command(224, true, "XOVER %s", span)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseOverviews(data)
+}
+
+//Parses a block of overview lines, as found in an OVER response or a dump of one. Blank lines are skipped.
+func ParseOverviews(data []byte) ([]Overview, error) {
+	var overviews []Overview
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		overview, err := ParseOverview(line)
+		if err != nil {
+			return nil, err
+		}
+		overviews = append(overviews, overview)
+	}
+	return overviews, scanner.Err()
+}
+
+//Parses a single tab-separated overview line: number, subject, from, date, message-id, references, bytes, lines and optional
+//extra headers such as "Xref: ...".
+func ParseOverview(line string) (Overview, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 8 {
+		return Overview{}, fmt.Errorf("nntp: malformed overview line %q", line)
+	}
+
+	number, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Overview{}, fmt.Errorf("nntp: malformed overview line %q", line)
+	}
+	overview := Overview{
+		Number: number,
+		Subject: fields[1],
+		From: fields[2],
+		MessageID: strings.Trim(fields[4], "<>"),
+		References: fields[5],
+	}
+	overview.Date, _ = mail.ParseDate(fields[3])
+	overview.Bytes, _ = strconv.ParseInt(fields[6], 10, 64)
+	overview.Lines, _ = strconv.ParseInt(fields[7], 10, 64)
+
+	for _, extra := range fields[8:] {
+		name, value, ok := strings.Cut(extra, ":")
+		if ok && strings.EqualFold(name, "Xref") {
+			overview.Xref = strings.TrimSpace(value)
+		}
+	}
+	return overview, nil
+}
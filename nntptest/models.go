@@ -0,0 +1,79 @@
+package nntptest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Faults the server injects into its responses. They may be changed while the server is running with SetFaults.
+type Faults struct {
+	//Message-IDs answered with 430, as if they had expired or been taken down.
+	Missing map[string]bool
+	//Rejects every AUTHINFO PASS with 481.
+	FailAuth bool
+	//Pause before every response.
+	Delay time.Duration
+	//Drops a connection without a response once it has sent this many commands. Disabled if zero.
+	DropAfter int
+	//Message-IDs whose bodies are sent with a wrong pcrc32/crc32, as if they had been damaged in transit.
+	CorruptCRC map[string]bool
+	//Rejects this many POST commands with 441 before accepting articles again.
+	FailPosts int
+}
+
+//Settings for a server. Authentication is required when Username is set.
+type Options struct {
+	Username string
+	Password string
+	//Encoded characters per line of served yEnc bodies; yenc.DefaultLineLength if zero.
+	LineLength int
+	Faults Faults
+}
+
+//A command received from a client. Conn numbers connections in the order they were accepted, starting at 1.
+type Command struct {
+	Conn int
+	Verb string
+	Args string
+}
+
+//An article stored on the server. Binary articles keep their part and are yEnc-encoded when served; raw articles carry a ready body.
+type article struct {
+	header map[string]string
+	part *yenc.Part
+	body []byte
+	groups []string
+	//Article number, shared by every group the article is in. Assigned in the order articles were added.
+	number int64
+}
+
+//State of a single client connection.
+type session struct {
+	number int
+	writer *bufio.Writer
+	authenticated bool
+	pendingUser string
+	commands int
+	//Newsgroup selected with GROUP, which OVER ranges refer to.
+	group string
+}
+
+//An in-memory NNTP server listening on a loopback address.
+type Server struct {
+	listener net.Listener
+	//host:port the server listens on.
+	Addr string
+
+	options Options
+	mu sync.Mutex
+	articles map[string]*article
+	lastNumber int64
+	commands []Command
+	conns map[net.Conn]struct{}
+	connCount int
+	wg sync.WaitGroup
+}
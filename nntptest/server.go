@@ -0,0 +1,566 @@
+// Allows for testing NNTP consumers against an in-process Usenet server seeded from NZBs, with fault injection and command recording.
+package nntptest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Starts an empty server on a loopback listener.
+func Start(options Options) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		listener: listener,
+		Addr: listener.Addr().String(),
+		options: options,
+		articles: map[string]*article{},
+		conns: map[net.Conn]struct{}{},
+	}
+
+	server.wg.Add(1)
+	go server.serve()
+	return server, nil
+}
+
+//Starts a server holding every segment of an NZB. contents maps filenames, as returned by parser.ExtractFilename, to file data;
+//files missing from it are filled with deterministic pseudo-random data the size of parser.FileSize.
+//Each file's data is split evenly over its segments, in segment number order.
+func NewServer(nzb *parser.Nzb, contents map[string][]byte, options Options) (*Server, error) {
+	server, err := Start(options)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range nzb.Files {
+		server.AddFile(f, contents[parser.ExtractFilename(f)])
+	}
+	return server, nil
+}
+
+//Generates reproducible filler data for a file that has no contents of its own.
+func FillerData(name string, size int) []byte {
+	var seed int64
+	for _, c := range name {
+		seed = seed*31 + int64(c)
+	}
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+//Stores the segments of a file, splitting data over them. A nil data slice is replaced with filler data.
+func (s *Server) AddFile(file parser.File, data []byte) {
+	name := parser.ExtractFilename(file)
+	if data == nil {
+		data = FillerData(name, parser.FileSize(file))
+	}
+
+	segments := append([]parser.Segment{}, file.Segments...)
+	sort.Slice(segments, func(a, b int) bool {
+		return segments[a].Number < segments[b].Number
+	})
+	if len(segments) == 0 {
+		return
+	}
+
+	partSize := max((len(data)+len(segments)-1)/len(segments), 1)
+	s.storeParts(file, segments, yenc.SplitParts(name, data, partSize), len(data))
+}
+
+//Stores the parts of a file under its segments, ordered by number. Segments beyond the parts get empty ones.
+func (s *Server) storeParts(file parser.File, segments []parser.Segment, parts []yenc.Part, size int) {
+	name := parser.ExtractFilename(file)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, seg := range segments {
+		var part yenc.Part
+		if i < len(parts) {
+			part = parts[i]
+		} else {
+			//More segments than data to fill them; the rest are empty parts.
+			part = yenc.Part{Name: name, Begin: int64(size) + 1, End: int64(size), FileSize: int64(size)}
+		}
+		part.Number = i + 1
+		part.Total = len(segments)
+
+		s.store(seg.ID, &article{
+			header: map[string]string{
+				"From": file.Poster,
+				"Subject": file.Subject,
+				"Newsgroups": strings.Join(file.Groups, ","),
+				"Message-ID": "<" + seg.ID + ">",
+				"Date": parser.DatePosted(file).Format(time.RFC1123Z),
+			},
+			part: &part,
+			groups: file.Groups,
+		})
+	}
+}
+
+//Appends a file to an NZB and stores its segments, splitting data into parts of partSize bytes, the last one shorter, as posting
+//tools do. Segments carry their encoded article size, as an indexer would list them. At least one segment is made, even for
+//empty data. Panics if partSize is not positive, as that is a mistake in the calling test.
+func (s *Server) AddNzbFile(nzb *parser.Nzb, name string, data []byte, partSize int) parser.File {
+	if partSize <= 0 {
+		panic("nntptest: AddNzbFile needs a positive part size")
+	}
+	parts := yenc.SplitParts(name, data, partSize)
+	file := parser.File{
+		Poster: "poster <poster@example.com>",
+		Date: 1706440709,
+		Subject: yenc.Subject(name, len(nzb.Files)+1, len(nzb.Files)+1, 1, len(parts), int64(len(data))),
+		Groups: []string{"alt.binaries.test"},
+	}
+	for _, part := range parts {
+		encoded := len(yenc.Encode(part.Data, 0))
+		file.Segments = append(file.Segments, parser.Segment{Bytes: encoded + yenc.ArticleOverhead, Number: part.Number, ID: yenc.NewMessageID("test")})
+	}
+	s.storeParts(file, file.Segments, parts, len(data))
+	nzb.Files = append(nzb.Files, file)
+	return file
+}
+
+//Stores an article with a ready-made body, served verbatim.
+func (s *Server) AddArticle(messageID string, header map[string]string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := &article{header: map[string]string{}, body: body}
+	for k, v := range header {
+		stored.header[k] = v
+	}
+	stored.header["Message-ID"] = "<" + messageID + ">"
+	if groups := header["Newsgroups"]; groups != "" {
+		stored.groups = strings.Split(groups, ",")
+	}
+	s.store(messageID, stored)
+}
+
+//Stores an article under its message-ID, numbering it unless it replaces an existing one. Callers hold s.mu.
+func (s *Server) store(messageID string, stored *article) {
+	if existing := s.articles[messageID]; existing != nil {
+		stored.number = existing.number
+	} else {
+		s.lastNumber++
+		stored.number = s.lastNumber
+	}
+	s.articles[messageID] = stored
+}
+
+//Replaces the faults injected into responses.
+func (s *Server) SetFaults(faults Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options.Faults = faults
+}
+
+//Returns a client configuration pointing at the server, using its credentials.
+func (s *Server) Config() nntp.Config {
+	host, port, _ := net.SplitHostPort(s.Addr)
+	portNumber, _ := strconv.Atoi(port)
+	return nntp.Config{
+		Host: host,
+		Port: portNumber,
+		Username: s.options.Username,
+		Password: s.options.Password,
+		Timeout: 10 * time.Second,
+	}
+}
+
+//Returns a copy of every command received so far.
+func (s *Server) Commands() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Command{}, s.commands...)
+}
+
+//Counts the received commands with the given verb, such as "BODY".
+func (s *Server) CommandCount(verb string) int {
+	count := 0
+	for _, c := range s.Commands() {
+		if c.Verb == strings.ToUpper(verb) {
+			count++
+		}
+	}
+	return count
+}
+
+//Counts the connections accepted so far.
+func (s *Server) ConnectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connCount
+}
+
+//Stops listening, closes every open connection and waits for their handlers to return.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+//Accepts connections until the listener is closed.
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connCount++
+		number := s.connCount
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn, number)
+	}
+}
+
+//Snapshot of the current faults.
+func (s *Server) faults() Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.options.Faults
+}
+
+//Looks up an article by message-ID, with or without angle brackets, honouring the Missing fault.
+func (s *Server) lookup(id string) (*article, string) {
+	id = strings.Trim(id, "<>")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.options.Faults.Missing[id] {
+		return nil, id
+	}
+	return s.articles[id], id
+}
+
+//Produces the body served for an article, encoding binary parts on the fly.
+func (s *Server) body(stored *article, id string) []byte {
+	if stored.part == nil {
+		return stored.body
+	}
+	part := *stored.part
+	if s.faults().CorruptCRC[id] {
+		part.CRC32 ^= 0xffffffff
+		part.FileCRC32 ^= 0xffffffff
+	}
+	return yenc.EncodePart(part, s.options.LineLength)
+}
+
+//Returns the articles in a newsgroup, ordered by article number.
+func (s *Server) groupArticles(group string) []*article {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var members []*article
+	for _, a := range s.articles {
+		for _, g := range a.groups {
+			if g == group {
+				members = append(members, a)
+				break
+			}
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].number < members[j].number
+	})
+	return members
+}
+
+//Parses an OVER range: "n", "n-" or "n-m". An open end is returned as a high of zero.
+func parseRange(span string) (int64, int64, bool) {
+	lowText, highText, isRange := strings.Cut(span, "-")
+	low, err := strconv.ParseInt(lowText, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !isRange {
+		return low, low, true
+	}
+	if highText == "" {
+		return low, 0, true
+	}
+	high, err := strconv.ParseInt(highText, 10, 64)
+	return low, high, err == nil
+}
+
+//Writes the overview line of an article, followed by an Xref field listing its groups.
+func (s *Server) writeOverview(w *bufio.Writer, stored *article) {
+	id := strings.Trim(stored.header["Message-ID"], "<>")
+	body := s.body(stored, id)
+	xref := "nntptest"
+	for _, g := range stored.groups {
+		xref += fmt.Sprintf(" %s:%d", g, stored.number)
+	}
+	fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\tXref: %s\r\n",
+		stored.number, stored.header["Subject"], stored.header["From"], stored.header["Date"], stored.header["Message-ID"],
+		stored.header["References"], len(body), bytes.Count(body, []byte("\n")), xref)
+}
+
+//Writes the header lines of an article.
+func writeHeader(w *bufio.Writer, stored *article) {
+	keys := make([]string, 0, len(stored.header))
+	for k := range stored.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\r\n", k, stored.header[k])
+	}
+}
+
+//Writes a block of data line by line, dot-stuffing lines that start with a dot. The terminating dot line is not written.
+func writeBlock(w *bufio.Writer, data []byte) {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if len(data) == 0 {
+		return
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.HasPrefix(line, []byte(".")) {
+			w.WriteByte('.')
+		}
+		w.Write(line)
+		w.WriteString("\r\n")
+	}
+}
+
+//Writes a formatted response line.
+func (c *session) reply(format string, args ...any) {
+	fmt.Fprintf(c.writer, format+"\r\n", args...)
+}
+
+//Serves a single client connection.
+func (s *Server) handle(conn net.Conn, number int) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	client := &session{
+		number: number,
+		writer: bufio.NewWriter(conn),
+		authenticated: s.options.Username == "",
+	}
+
+	client.reply("200 nntptest server ready")
+	client.writer.Flush()
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		verb = strings.ToUpper(verb)
+
+		s.mu.Lock()
+		s.commands = append(s.commands, Command{Conn: number, Verb: verb, Args: args})
+		s.mu.Unlock()
+
+		faults := s.faults()
+		client.commands++
+		if faults.DropAfter > 0 && client.commands > faults.DropAfter {
+			return
+		}
+		if faults.Delay > 0 {
+			time.Sleep(faults.Delay)
+		}
+
+		//Posting needs the reader for the article, so it is handled here rather than in dispatch.
+		if verb == "POST" && client.authenticated {
+			if err := s.post(client, reader); err != nil {
+				return
+			}
+			if err := client.writer.Flush(); err != nil {
+				return
+			}
+			continue
+		}
+
+		keepOpen := s.dispatch(client, verb, args, faults)
+		if err := client.writer.Flush(); err != nil || !keepOpen {
+			return
+		}
+	}
+}
+
+//Reads a posted article up to its terminating dot line, undoing dot-stuffing. Returns its headers and body.
+func readPosted(reader *bufio.Reader) (map[string]string, []byte, error) {
+	header := map[string]string{}
+	var body bytes.Buffer
+	inBody := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			return header, body.Bytes(), nil
+		}
+		line = strings.TrimPrefix(line, ".")
+
+		if inBody {
+			body.WriteString(line + "\r\n")
+		} else if line == "" {
+			inBody = true
+		} else if key, value, ok := strings.Cut(line, ":"); ok {
+			header[key] = strings.TrimSpace(value)
+		}
+	}
+}
+
+//Accepts a POST, storing the article under its Message-ID. FailPosts rejects it after it has been sent, as real servers do.
+func (s *Server) post(client *session, reader *bufio.Reader) error {
+	client.reply("340 send article")
+	if err := client.writer.Flush(); err != nil {
+		return err
+	}
+	header, body, err := readPosted(reader)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	reject := s.options.Faults.FailPosts > 0
+	if reject {
+		s.options.Faults.FailPosts--
+	}
+	s.mu.Unlock()
+
+	id := strings.Trim(header["Message-ID"], "<>")
+	if reject || id == "" {
+		client.reply("441 posting failed")
+		return nil
+	}
+	s.AddArticle(id, header, body)
+	client.reply("240 article received")
+	return nil
+}
+
+//Answers a single command. Returns false once the connection should be closed.
+func (s *Server) dispatch(client *session, verb string, args string, faults Faults) bool {
+	w, reply := client.writer, client.reply
+	switch verb {
+	case "QUIT":
+		reply("205 closing connection")
+		return false
+	case "MODE":
+		reply("200 reader mode")
+		return true
+	case "CAPABILITIES":
+		reply("101 capability list follows")
+		reply("VERSION 2")
+		reply("READER")
+		reply("AUTHINFO USER")
+		reply(".")
+		return true
+	case "AUTHINFO":
+		kind, value, _ := strings.Cut(args, " ")
+		switch strings.ToUpper(kind) {
+		case "USER":
+			client.pendingUser = value
+			reply("381 password required")
+		case "PASS":
+			if client.pendingUser == "" {
+				reply("482 authentication commands issued out of sequence")
+			} else if faults.FailAuth || client.pendingUser != s.options.Username || value != s.options.Password {
+				reply("481 authentication failed")
+			} else {
+				client.authenticated = true
+				reply("281 authentication accepted")
+			}
+			client.pendingUser = ""
+		default:
+			reply("501 unknown AUTHINFO option")
+		}
+		return true
+	}
+
+	if !client.authenticated {
+		reply("480 authentication required")
+		return true
+	}
+
+	switch verb {
+	case "GROUP":
+		members := s.groupArticles(args)
+		if len(members) == 0 {
+			reply("411 no such newsgroup")
+		} else {
+			client.group = args
+			reply("211 %d %d %d %s", len(members), members[0].number, members[len(members)-1].number, args)
+		}
+	case "OVER", "XOVER":
+		if client.group == "" {
+			reply("412 no newsgroup selected")
+			return true
+		}
+		low, high, ok := parseRange(args)
+		if !ok {
+			reply("501 invalid range")
+			return true
+		}
+		reply("224 overview information follows")
+		for _, a := range s.groupArticles(client.group) {
+			if a.number >= low && (high == 0 || a.number <= high) {
+				s.writeOverview(w, a)
+			}
+		}
+		reply(".")
+	case "STAT", "HEAD", "BODY", "ARTICLE":
+		stored, id := s.lookup(args)
+		if stored == nil {
+			reply("430 no such article")
+			return true
+		}
+
+		switch verb {
+		case "STAT":
+			reply("223 0 <%s>", id)
+		case "HEAD":
+			reply("221 0 <%s>", id)
+			writeHeader(w, stored)
+			reply(".")
+		case "BODY":
+			reply("222 0 <%s>", id)
+			writeBlock(w, s.body(stored, id))
+			reply(".")
+		case "ARTICLE":
+			reply("220 0 <%s>", id)
+			writeHeader(w, stored)
+			reply("")
+			writeBlock(w, s.body(stored, id))
+			reply(".")
+		}
+	default:
+		reply("500 unknown command")
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package nntptest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func TestServerServesNzbSegments(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainFile := parser.MainFile(nzb)
+	name := parser.ExtractFilename(mainFile)
+	content := bytes.Repeat([]byte("0123456789"), 5000)
+
+	server, err := NewServer(nzb, map[string][]byte{name: content}, Options{Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	var joined []byte
+	for _, seg := range mainFile.Segments {
+		body, err := client.Body(seg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := yenc.Decode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.Name != name {
+			t.Fatalf("unexpected yEnc name %q", part.Name)
+		}
+		joined = append(joined, part.Data...)
+	}
+	if !bytes.Equal(joined, content) {
+		t.Fatal("served segments do not reassemble the file")
+	}
+	if server.CommandCount("BODY") != len(mainFile.Segments) {
+		t.Fatalf("recorded %d BODY commands", server.CommandCount("BODY"))
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	nzb, _ := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	first := nzb.Files[0].Segments[0].ID
+
+	server, err := NewServer(nzb, nil, Options{Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	server.SetFaults(Faults{FailAuth: true})
+	if _, err := nntp.Dial(server.Config()); !nntp.IsAuthFailure(err) {
+		t.Fatalf("expected an auth failure, got %v", err)
+	}
+
+	server.SetFaults(Faults{Missing: map[string]bool{first: true}, CorruptCRC: map[string]bool{nzb.Files[1].Segments[0].ID: true}})
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Stat(first); !nntp.IsNoSuchArticle(err) {
+		t.Fatalf("expected a missing article, got %v", err)
+	}
+	body, err := client.Body(nzb.Files[1].Segments[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := yenc.Decode(body); err != yenc.ErrCRCMismatch {
+		t.Fatalf("expected a CRC mismatch, got %v", err)
+	}
+	client.Close()
+
+	server.SetFaults(Faults{DropAfter: 2, Delay: 10 * time.Millisecond})
+	client, err = nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Stat(nzb.Files[1].Segments[0].ID); err == nil || nntp.IsProtocolError(err) {
+		t.Fatalf("expected a dropped connection, got %v", err)
+	}
+}
+
+func TestAddNzbFileStoresDescribedParts(t *testing.T) {
+	server, err := Start(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	nzb := &parser.Nzb{}
+	data := FillerData("file.bin", 2500)
+	file := server.AddNzbFile(nzb, "file.bin", data, 1000)
+	if len(nzb.Files) != 1 || len(file.Segments) != 3 {
+		t.Fatalf("unexpected NZB: %+v", nzb)
+	}
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+	for i, segment := range file.Segments {
+		body, err := client.Body(segment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := yenc.Decode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		begin := int64(i*1000) + 1
+		if part.Begin != begin || !bytes.Equal(part.Data, data[begin-1:min(begin+999, 2500)]) {
+			t.Fatalf("segment %d holds bytes %d-%d", i+1, part.Begin, part.End)
+		}
+		if encoded := len(yenc.Encode(part.Data, 0)) + yenc.ArticleOverhead; segment.Bytes != encoded {
+			t.Fatalf("segment %d claims %d bytes, its part encodes to %d", i+1, segment.Bytes, encoded)
+		}
+	}
+}
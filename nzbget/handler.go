@@ -0,0 +1,364 @@
+// Allows for exposing a download engine through NZBGet's JSON-RPC and XML-RPC interfaces, so tools that only support NZBGet can use
+// it as a download client.
+package nzbget
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/metaeditor"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Largest request body accepted, enough for a base64-encoded NZB of 64 MiB.
+const maxRequest = 96 << 20
+
+//Largest NZB accepted, once decompressed.
+const maxNzb = 64 << 20
+
+//Post-processing parameter carrying the archive password.
+const passwordParameter = "*Unpack:Password"
+
+//Creates a handler serving the RPC interface on top of a backend.
+func New(backend Backend, options Options) *Handler {
+	if options.Version == "" {
+		options.Version = "21.1"
+	}
+	if options.HTTP == nil {
+		options.HTTP = http.DefaultClient
+	}
+	return &Handler{backend: backend, options: options}
+}
+
+//Serves RPC requests on paths ending in /jsonrpc or /xmlrpc, optionally prefixed with "/username:password".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	credentials, endpoint, found := strings.Cut(path, "/")
+	if !found {
+		credentials, endpoint = "", path
+	}
+	if !h.authorized(r, credentials) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="NZBGet"`)
+		http.Error(w, "Access denied", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequest))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch endpoint {
+	case "jsonrpc":
+		w.Header().Set("Content-Type", "application/json")
+		method, params, id, err := decodeJSON(body)
+		if err != nil {
+			encodeJSON(w, nil, nil, &Fault{Code: faultParse, Message: "Invalid request: " + err.Error()})
+			return
+		}
+		result, fault := h.call(r.Context(), method, params)
+		encodeJSON(w, id, result, fault)
+	case "xmlrpc":
+		w.Header().Set("Content-Type", "text/xml")
+		method, params, err := decodeXML(body)
+		if err != nil {
+			encodeXML(w, nil, &Fault{Code: faultParse, Message: "Invalid request: " + err.Error()})
+			return
+		}
+		result, fault := h.call(r.Context(), method, params)
+		encodeXML(w, result, fault)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+//Checks the credentials from the path prefix or, failing that, basic auth.
+func (h *Handler) authorized(r *http.Request, credentials string) bool {
+	if h.options.Username == "" {
+		return true
+	}
+	username, password, ok := strings.Cut(credentials, ":")
+	if !ok {
+		username, password, ok = r.BasicAuth()
+	}
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(h.options.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(h.options.Password)) == 1
+}
+
+//Dispatches a call to its method, converting errors into faults.
+func (h *Handler) call(ctx context.Context, method string, params []any) (any, *Fault) {
+	var (
+		result any
+		err error
+	)
+	switch method {
+	case "version":
+		result = h.options.Version
+	case "append":
+		result, err = h.append(ctx, params)
+	case "listgroups":
+		result, err = h.listGroups(ctx)
+	case "history":
+		result, err = h.history(ctx, argBool(params, 0))
+	case "editqueue":
+		result, err = h.editQueue(ctx, params)
+	case "status":
+		result, err = h.status(ctx)
+	default:
+		return nil, &Fault{Code: faultMethod, Message: "Invalid procedure: " + method}
+	}
+
+	var fault *Fault
+	if errors.As(err, &fault) {
+		return nil, fault
+	}
+	if err != nil {
+		return nil, &Fault{Code: faultCall, Message: err.Error()}
+	}
+	return result, nil
+}
+
+//append(NZBFilename, NZBContent, Category, Priority, AddToTop, AddPaused, DupeKey, DupeScore, DupeMode, PPParameters). NZBContent is
+//the base64-encoded NZB, or a URL to fetch it from. Returns the new job's ID.
+func (h *Handler) append(ctx context.Context, params []any) (int, error) {
+	priority, err := argInt(params, 3)
+	if err != nil {
+		return 0, &Fault{Code: faultParams, Message: err.Error()}
+	}
+	score, err := argInt(params, 7)
+	if err != nil {
+		return 0, &Fault{Code: faultParams, Message: err.Error()}
+	}
+	job := &Job{
+		Filename: argString(params, 0),
+		Category: strings.TrimSpace(argString(params, 2)),
+		Priority: priority,
+		AddToTop: argBool(params, 4),
+		AddPaused: argBool(params, 5),
+		DupeKey: argString(params, 6),
+		DupeScore: score,
+		DupeMode: strings.ToUpper(argString(params, 8)),
+		Parameters: argParameters(params, 9),
+	}
+	job.Name = parser.StripNzbExtension(job.Filename)
+
+	nzb, name, err := h.content(ctx, params)
+	if err != nil {
+		return 0, &Fault{Code: faultParams, Message: "Invalid NZB content: " + err.Error()}
+	}
+	if job.Name == "" {
+		job.Name = name
+	}
+	job.Nzb = nzb
+
+	editor := &metaeditor.NzbMetaEditor{Metadata: nzb.Head.Meta, Nzb: nzb}
+	metaeditor.Put(editor, "title", job.Name)
+	metaeditor.Put(editor, "category", job.Category)
+	for _, p := range job.Parameters {
+		if p.Name == passwordParameter && p.Value != "" && !metaeditor.Contains(editor, "password", p.Value) {
+			metaeditor.Append(editor, "password", p.Value)
+		}
+	}
+	nzb.Head.Meta = editor.Metadata
+	if job.Name == "" {
+		job.Name = parser.Title(nzb.Head.Meta)
+	}
+	if job.Category == "" {
+		job.Category = parser.Category(nzb.Head.Meta)
+	}
+	//A password only present in the NZB is passed on, so the backend sees it where NZBGet scripts would.
+	if password := parser.Passwords(nzb.Head.Meta); len(password) > 0 && parameter(job.Parameters, passwordParameter) == "" {
+		job.Parameters = append(job.Parameters, Parameter{Name: passwordParameter, Value: password[0]})
+	}
+
+	id, err := h.backend.Append(ctx, job)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//Decodes NZBContent, fetching it first if it is a URL. The name suggested by the download, if any, is returned alongside.
+func (h *Handler) content(ctx context.Context, params []any) (*parser.Nzb, string, error) {
+	if len(params) < 2 {
+		return nil, "", errors.New("no content")
+	}
+	var data []byte
+	switch content := params[1].(type) {
+	case []byte:
+		data = content
+	case string:
+		content = strings.TrimSpace(content)
+		if strings.HasPrefix(content, "http://") || strings.HasPrefix(content, "https://") {
+			return parser.FromURLContext(ctx, h.options.HTTP, content)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(content), ""))
+		if err != nil {
+			return nil, "", err
+		}
+		data = decoded
+	}
+	if len(data) == 0 {
+		return nil, "", errors.New("no content")
+	}
+
+	data, err := parser.Decompress(data, maxNzb)
+	if err != nil {
+		return nil, "", err
+	}
+	nzb, err := parser.FromStr(string(data))
+	return nzb, "", err
+}
+
+//Looks up a post-processing parameter's value.
+func parameter(parameters []Parameter, name string) string {
+	for _, p := range parameters {
+		if strings.EqualFold(p.Name, name) {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+//Lists parameters as {Name, Value} structs.
+func parameterList(parameters []Parameter) []any {
+	list := make([]any, 0, len(parameters))
+	for _, p := range parameters {
+		list = append(list, map[string]any{"Name": p.Name, "Value": p.Value})
+	}
+	return list
+}
+
+//Adds a size to a result in NZBGet's form: the low and high 32 bits, plus whole megabytes.
+func putSize(result map[string]any, prefix string, size int64) {
+	result[prefix+"Lo"] = int64(uint32(size))
+	result[prefix+"Hi"] = size >> 32
+	result[prefix+"MB"] = size >> 20
+}
+
+func (h *Handler) listGroups(ctx context.Context) ([]any, error) {
+	groups, err := h.backend.Groups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]any, 0, len(groups))
+	for _, g := range groups {
+		active := 0
+		if g.Status == "DOWNLOADING" {
+			active = 1
+		}
+		group := map[string]any{
+			"NZBID": g.ID,
+			"NZBName": g.Name,
+			"NZBNicename": g.Name,
+			"NZBFilename": g.Filename,
+			"Kind": "NZB",
+			"Category": g.Category,
+			"Status": g.Status,
+			"MaxPriority": g.Priority,
+			"MinPriority": g.Priority,
+			"ActiveDownloads": active,
+			"DupeKey": g.DupeKey,
+			"DupeScore": g.DupeScore,
+			"DestDir": g.DestDir,
+			"Parameters": parameterList(g.Parameters),
+		}
+		putSize(group, "FileSize", g.Size)
+		putSize(group, "RemainingSize", g.Remaining)
+		putSize(group, "PausedSize", g.Paused)
+		list = append(list, group)
+	}
+	return list, nil
+}
+
+func (h *Handler) history(ctx context.Context, hidden bool) ([]any, error) {
+	items, err := h.backend.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]any, 0, len(items))
+	for _, item := range items {
+		if item.Hidden && !hidden {
+			continue
+		}
+		//Tools written for older versions read the delete status on its own.
+		deleteStatus := "NONE"
+		if kind, detail, _ := strings.Cut(item.Status, "/"); kind == "DELETED" {
+			deleteStatus = detail
+		}
+		entry := map[string]any{
+			"NZBID": item.ID,
+			"ID": item.ID,
+			"Kind": "NZB",
+			"Name": item.Name,
+			"NZBName": item.Name,
+			"Category": item.Category,
+			"Status": item.Status,
+			"DeleteStatus": deleteStatus,
+			"HistoryTime": item.Time.Unix(),
+			"DestDir": item.DestDir,
+			"FinalDir": "",
+			"DupeKey": item.DupeKey,
+			"Parameters": parameterList(item.Parameters),
+		}
+		putSize(entry, "FileSize", item.Size)
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+//editqueue(Command, Param, IDs). The older form editqueue(Command, Offset, Param, IDs) is accepted too, its offset ignored.
+func (h *Handler) editQueue(ctx context.Context, params []any) (bool, error) {
+	command := argString(params, 0)
+	param, idsAt := argString(params, 1), 2
+	if len(params) >= 4 {
+		param, idsAt = argString(params, 2), 3
+	}
+	ids, err := argIDs(params, idsAt)
+	if err != nil {
+		return false, &Fault{Code: faultParams, Message: err.Error()}
+	}
+	if command == "" {
+		return false, &Fault{Code: faultParams, Message: "missing command"}
+	}
+	if err := h.backend.Edit(ctx, command, param, ids); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *Handler) status(ctx context.Context) (map[string]any, error) {
+	status, err := h.backend.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var uptime int64
+	if !status.Started.IsZero() {
+		uptime = int64(time.Since(status.Started).Seconds())
+	}
+	result := map[string]any{
+		"DownloadRate": status.Rate,
+		"DownloadPaused": status.Paused,
+		"Download2Paused": status.Paused,
+		"ServerPaused": status.Paused,
+		"ServerStandBy": status.Paused || status.Remaining == 0,
+		"ThreadCount": status.Threads,
+		"PostJobCount": status.PostJobs,
+		"UpTimeSec": uptime,
+	}
+	putSize(result, "RemainingSize", status.Remaining)
+	putSize(result, "DownloadedSize", status.Downloaded)
+	return result, nil
+}
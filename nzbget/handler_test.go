@@ -0,0 +1,193 @@
+package nzbget
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//In-memory backend recording what the handler asks of it.
+type fakeBackend struct {
+	jobs []*Job
+	edits []string
+}
+
+func (b *fakeBackend) Append(ctx context.Context, job *Job) (int, error) {
+	b.jobs = append(b.jobs, job)
+	return len(b.jobs), nil
+}
+
+func (b *fakeBackend) Groups(ctx context.Context) ([]Group, error) {
+	var groups []Group
+	for i, job := range b.jobs {
+		groups = append(groups, Group{ID: i + 1, Name: job.Name, Category: job.Category, Status: "DOWNLOADING", Size: 5<<32 + 7, Remaining: 3 << 20, Parameters: job.Parameters})
+	}
+	return groups, nil
+}
+
+func (b *fakeBackend) History(ctx context.Context) ([]HistoryItem, error) {
+	return []HistoryItem{
+		{ID: 9, Name: "Old.Release", Status: "SUCCESS/ALL", Size: 1 << 20},
+		{ID: 10, Name: "Dupe.Release", Status: "DELETED/DUPE", Hidden: true},
+	}, nil
+}
+
+func (b *fakeBackend) Edit(ctx context.Context, command string, param string, ids []int) error {
+	b.edits = append(b.edits, fmt.Sprintf("%s %q %v", command, param, ids))
+	return nil
+}
+
+func (b *fakeBackend) Status(ctx context.Context) (*Status, error) {
+	return &Status{Rate: 1024, Remaining: 3 << 20, Threads: 4}, nil
+}
+
+//POSTs a JSON-RPC call and decodes its response.
+func callJSON(t *testing.T, url string, method string, params any) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"method": method, "params": params, "id": 7})
+	response, err := http.Post(url+"/jsonrpc", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var decoded map[string]any
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+//POSTs an XML-RPC call and returns the raw response.
+func callXML(t *testing.T, url string, request string) string {
+	t.Helper()
+	response, err := http.Post(url+"/xmlrpc", "text/xml", strings.NewReader(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, _ := io.ReadAll(response.Body)
+	return string(data)
+}
+
+func TestHandler(t *testing.T) {
+	sample, err := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := base64.StdEncoding.EncodeToString(sample)
+	backend := &fakeBackend{}
+	server := httptest.NewServer(New(backend, Options{Username: "nzbget", Password: "tegbzn6789"}))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/jsonrpc", "application/json", strings.NewReader(`{"method":"version"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated call got %d", response.StatusCode)
+	}
+	url := strings.Replace(server.URL, "http://", "http://nzbget:tegbzn6789@", 1)
+	prefixed := server.URL + "/nzbget:tegbzn6789"
+
+	//Positional JSON-RPC append, with a password and a script parameter.
+	got := callJSON(t, url, "append", []any{"Show.S01E01.nzb", content, "tv", 50, false, false, "show-s01e01", 10, "score",
+		[]any{map[string]any{"Name": "*Unpack:Password", "Value": "secret"}, map[string]any{"Name": "Notify.py:", "Value": "yes"}}})
+	if got["result"] != float64(1) || got["id"] != float64(7) {
+		t.Fatalf("unexpected append response %v", got)
+	}
+	job := backend.jobs[0]
+	if job.Name != "Show.S01E01" || job.Category != "tv" || job.Priority != PriorityHigh || job.DupeKey != "show-s01e01" ||
+		job.DupeScore != 10 || job.DupeMode != "SCORE" || len(job.Parameters) != 2 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	meta := job.Nzb.Head.Meta
+	if parser.Title(meta) != "Show.S01E01" || parser.Category(meta) != "tv" || !slices.Contains(parser.Passwords(meta), "secret") {
+		t.Fatalf("meta was not updated: %+v", meta)
+	}
+
+	//Named parameters through the path credentials; the NZB's own title, category and password are kept.
+	got = callJSON(t, prefixed, "append", map[string]any{"NZBFilename": "", "NZBContent": content})
+	if got["result"] != float64(2) {
+		t.Fatalf("unexpected append response %v", got)
+	}
+	if job := backend.jobs[1]; job.Name != "title" || job.Category != "misc." || parameter(job.Parameters, "*unpack:password") != "foobar!" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	if got := callJSON(t, url, "append", []any{"Broken.nzb", "not base64!"}); got["error"] == nil {
+		t.Fatalf("broken content was accepted: %v", got)
+	}
+	if got := callJSON(t, url, "shutdown", nil); got["error"].(map[string]any)["code"] != float64(faultMethod) {
+		t.Fatalf("unknown method was not rejected: %v", got)
+	}
+
+	groups := callJSON(t, url, "listgroups", []any{0})["result"].([]any)
+	first := groups[0].(map[string]any)
+	if len(groups) != 2 || first["NZBName"] != "Show.S01E01" || first["FileSizeHi"] != float64(5) || first["FileSizeLo"] != float64(7) ||
+		first["RemainingSizeMB"] != float64(3) || len(first["Parameters"].([]any)) != 2 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	if history := callJSON(t, url, "history", []any{false})["result"].([]any); len(history) != 1 {
+		t.Fatalf("hidden history entry was listed: %v", history)
+	}
+	history := callJSON(t, url, "history", []any{true})["result"].([]any)
+	if len(history) != 2 || history[1].(map[string]any)["DeleteStatus"] != "DUPE" {
+		t.Fatalf("unexpected history %v", history)
+	}
+
+	callJSON(t, url, "editqueue", []any{"GroupSetCategory", "movies", []any{1, 2}})
+	callJSON(t, url, "editqueue", []any{"GroupPause", 0, "", []any{2}})
+	if fmt.Sprint(backend.edits) != `[GroupSetCategory "movies" [1 2] GroupPause "" [2]]` {
+		t.Fatalf("unexpected edits %v", backend.edits)
+	}
+
+	status := callJSON(t, url, "status", nil)["result"].(map[string]any)
+	if status["DownloadRate"] != float64(1024) || status["RemainingSizeMB"] != float64(3) || status["ServerStandBy"] != false {
+		t.Fatalf("unexpected status %v", status)
+	}
+
+	//XML-RPC append with base64-typed content and a struct parameter.
+	xmlResponse := callXML(t, url, `<?xml version="1.0"?><methodCall><methodName>append</methodName><params>
+		<param><value><string>Movie.2020.nzb</string></value></param>
+		<param><value><base64>`+content+`</base64></value></param>
+		<param><value>movies</value></param>
+		<param><value><i4>900</i4></value></param>
+		<param><value><boolean>1</boolean></value></param>
+		<param><value><boolean>0</boolean></value></param>
+		<param><value><string></string></value></param>
+		<param><value><int>0</int></value></param>
+		<param><value><string>ALL</string></value></param>
+		<param><value><array><data><value><struct>
+			<member><name>Name</name><value><string>*Unpack:Password</string></value></member>
+			<member><name>Value</name><value><string>x&amp;y</string></value></member>
+		</struct></value></data></array></value></param>
+	</params></methodCall>`)
+	if !strings.Contains(xmlResponse, "<params><param><value><i4>3</i4></value></param></params>") {
+		t.Fatalf("unexpected XML-RPC response %s", xmlResponse)
+	}
+	if job := backend.jobs[2]; job.Name != "Movie.2020" || job.Category != "movies" || job.Priority != PriorityForce || !job.AddToTop ||
+		!slices.Contains(parser.Passwords(job.Nzb.Head.Meta), "x&y") {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	xmlResponse = callXML(t, url, `<methodCall><methodName>status</methodName><params></params></methodCall>`)
+	if !strings.Contains(xmlResponse, "<member><name>DownloadRate</name><value><i4>1024</i4></value></member>") {
+		t.Fatalf("unexpected XML-RPC status %s", xmlResponse)
+	}
+	xmlResponse = callXML(t, url, `<methodCall><methodName>missing</methodName></methodCall>`)
+	if !strings.Contains(xmlResponse, "<fault>") || !strings.Contains(xmlResponse, "Invalid procedure: missing") {
+		t.Fatalf("unexpected XML-RPC fault %s", xmlResponse)
+	}
+}
@@ -0,0 +1,181 @@
+package nzbget
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Job priorities, as used by NZBGet.
+const (
+	PriorityVeryLow = -100
+	PriorityLow = -50
+	PriorityNormal = 0
+	PriorityHigh = 50
+	PriorityVeryHigh = 100
+	PriorityForce = 900
+)
+
+//A post-processing parameter, such as "*Unpack:Password" or a script option like "Script.py:" = "yes".
+type Parameter struct {
+	Name string
+	Value string
+}
+
+//A job submitted through append. Its meta has already been updated with Name, Category and the *Unpack:Password parameter.
+type Job struct {
+	Nzb *parser.Nzb
+	//NZBFilename as given by the caller.
+	Filename string
+	Name string
+	Category string
+	Priority int
+	AddToTop bool
+	AddPaused bool
+	//Duplicate handling, as described in NZBGet's duplicate check: jobs sharing a key are compared by score, and DupeMode is "SCORE",
+	//"ALL" or "FORCE".
+	DupeKey string
+	DupeScore int
+	DupeMode string
+	Parameters []Parameter
+}
+
+//A queued job as reported by the backend. Sizes are in bytes.
+type Group struct {
+	ID int
+	Name string
+	Filename string
+	Category string
+	Priority int
+	//Such as "QUEUED", "DOWNLOADING", "PAUSED" or "PP_QUEUED".
+	Status string
+	Size int64
+	Remaining int64
+	//Part of Remaining held back by paused files, such as par2 recovery volumes.
+	Paused int64
+	DupeKey string
+	DupeScore int
+	DestDir string
+	Parameters []Parameter
+}
+
+//A finished job.
+type HistoryItem struct {
+	ID int
+	Name string
+	Category string
+	//Overall result in NZBGet's "SUCCESS/ALL" or "FAILURE/UNPACK" form.
+	Status string
+	Size int64
+	Time time.Time
+	DestDir string
+	DupeKey string
+	//Whether the entry is hidden, such as duplicates that were skipped. Only listed when history is called with Hidden set.
+	Hidden bool
+	Parameters []Parameter
+}
+
+//State of the whole queue, as reported by status.
+type Status struct {
+	Paused bool
+	//Download rate in bytes per second.
+	Rate int64
+	Remaining int64
+	Downloaded int64
+	Threads int
+	PostJobs int
+	Started time.Time
+}
+
+//Download engine behind the RPC interface.
+type Backend interface {
+	//Queues a job, returning its ID, which must be positive.
+	Append(ctx context.Context, job *Job) (int, error)
+	Groups(ctx context.Context) ([]Group, error)
+	History(ctx context.Context) ([]HistoryItem, error)
+	//Applies an editqueue command, such as "GroupPause", "GroupDelete", "GroupSetCategory" or "HistoryDelete", to the given IDs.
+	//param is the command's argument, empty for commands that take none.
+	Edit(ctx context.Context, command string, param string, ids []int) error
+	Status(ctx context.Context) (*Status, error)
+}
+
+//Settings for the RPC handler.
+type Options struct {
+	//Credentials required through basic auth or a "/username:password/" path prefix. No authentication if Username is empty.
+	Username string
+	Password string
+	//Version reported by the version method, which tools check for compatibility.
+	Version string
+	//Used to fetch NZBContent given as a URL; http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+//An http.Handler implementing NZBGet's RPC methods over JSON-RPC and XML-RPC.
+type Handler struct {
+	backend Backend
+	options Options
+}
+
+//A failed call, returned as a JSON-RPC error or an XML-RPC fault.
+type Fault struct {
+	Code int
+	Message string
+}
+
+//JSON-RPC request. Params is either a positional array or an object of named parameters.
+type jsonRequest struct {
+	Method string `json:"method"`
+	Params any `json:"params"`
+	ID any `json:"id"`
+}
+
+type jsonResponse struct {
+	Version string `json:"version"`
+	ID any `json:"id"`
+	Result any `json:"result,omitempty"`
+	Error *jsonError `json:"error,omitempty"`
+}
+
+type jsonError struct {
+	Name string `json:"name"`
+	Code int `json:"code"`
+	Message string `json:"message"`
+}
+
+//XML-RPC request.
+type methodCall struct {
+	XMLName xml.Name `xml:"methodCall"`
+	Method string `xml:"methodName"`
+	Params []xmlValue `xml:"params>param>value"`
+}
+
+//An XML-RPC value. Exactly one of the typed fields is set; a value with none of them is a string held in Text.
+type xmlValue struct {
+	Int *string `xml:"int"`
+	I4 *string `xml:"i4"`
+	I8 *string `xml:"i8"`
+	Boolean *string `xml:"boolean"`
+	String *string `xml:"string"`
+	Double *string `xml:"double"`
+	Base64 *string `xml:"base64"`
+	DateTime *string `xml:"dateTime.iso8601"`
+	Array *xmlArray `xml:"array"`
+	Struct *xmlStruct `xml:"struct"`
+	Text string `xml:",chardata"`
+}
+
+type xmlArray struct {
+	Values []xmlValue `xml:"data>value"`
+}
+
+type xmlStruct struct {
+	Members []xmlMember `xml:"member"`
+}
+
+type xmlMember struct {
+	Name string `xml:"name"`
+	Value xmlValue `xml:"value"`
+}
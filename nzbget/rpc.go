@@ -0,0 +1,281 @@
+package nzbget
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("nzbget: %d %s", f.Code, f.Message)
+}
+
+//Fault codes returned to callers.
+const (
+	faultParse = 1
+	faultMethod = 2
+	faultParams = 3
+	faultCall = 4
+)
+
+//Parameter names of each method, in positional order, used to place JSON-RPC named parameters.
+var methodParams = map[string][]string{
+	"append": {"NZBFilename", "NZBContent", "Category", "Priority", "AddToTop", "AddPaused", "DupeKey", "DupeScore", "DupeMode", "PPParameters"},
+	"listgroups": {"NumberOfLogEntries"},
+	"history": {"Hidden"},
+	"editqueue": {"Command", "Param", "IDs"},
+}
+
+//Decodes a JSON-RPC request into its method, positional parameters and ID.
+func decodeJSON(body []byte) (string, []any, any, error) {
+	var request jsonRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return "", nil, nil, err
+	}
+	switch params := request.Params.(type) {
+	case []any:
+		return request.Method, params, request.ID, nil
+	case map[string]any:
+		names := methodParams[request.Method]
+		positional := make([]any, len(names))
+		for i, name := range names {
+			positional[i] = params[name]
+		}
+		return request.Method, positional, request.ID, nil
+	}
+	return request.Method, nil, request.ID, nil
+}
+
+//Encodes a JSON-RPC response, carrying either a result or a fault.
+func encodeJSON(w io.Writer, id any, result any, fault *Fault) error {
+	response := jsonResponse{Version: "1.1", ID: id, Result: result}
+	if fault != nil {
+		response.Result = nil
+		response.Error = &jsonError{Name: "JSONRPCError", Code: fault.Code, Message: fault.Message}
+	}
+	return json.NewEncoder(w).Encode(response)
+}
+
+//Decodes an XML-RPC request into its method and parameters.
+func decodeXML(body []byte) (string, []any, error) {
+	var call methodCall
+	if err := xml.Unmarshal(body, &call); err != nil {
+		return "", nil, err
+	}
+	params := make([]any, 0, len(call.Params))
+	for _, v := range call.Params {
+		value, err := v.decode()
+		if err != nil {
+			return "", nil, err
+		}
+		params = append(params, value)
+	}
+	return strings.TrimSpace(call.Method), params, nil
+}
+
+//Converts an XML-RPC value into int64, bool, string, float64, []byte, []any or map[string]any.
+func (v xmlValue) decode() (any, error) {
+	switch {
+	case v.Int != nil, v.I4 != nil, v.I8 != nil:
+		text := v.Int
+		if text == nil {
+			text = v.I4
+		}
+		if text == nil {
+			text = v.I8
+		}
+		return strconv.ParseInt(strings.TrimSpace(*text), 10, 64)
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1", nil
+	case v.String != nil:
+		return *v.String, nil
+	case v.Double != nil:
+		return strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+	case v.Base64 != nil:
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(*v.Base64), ""))
+	case v.DateTime != nil:
+		return strings.TrimSpace(*v.DateTime), nil
+	case v.Array != nil:
+		values := make([]any, 0, len(v.Array.Values))
+		for _, element := range v.Array.Values {
+			value, err := element.decode()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case v.Struct != nil:
+		members := make(map[string]any, len(v.Struct.Members))
+		for _, m := range v.Struct.Members {
+			value, err := m.Value.decode()
+			if err != nil {
+				return nil, err
+			}
+			members[strings.TrimSpace(m.Name)] = value
+		}
+		return members, nil
+	}
+	return v.Text, nil
+}
+
+//Encodes an XML-RPC response, carrying either a result or a fault.
+func encodeXML(w io.Writer, result any, fault *Fault) error {
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header + "<methodResponse>")
+	if fault != nil {
+		buffer.WriteString("<fault>")
+		writeXMLValue(&buffer, map[string]any{"faultCode": fault.Code, "faultString": fault.Message})
+		buffer.WriteString("</fault>")
+	} else {
+		buffer.WriteString("<params><param>")
+		writeXMLValue(&buffer, result)
+		buffer.WriteString("</param></params>")
+	}
+	buffer.WriteString("</methodResponse>\n")
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+//Writes a result value as an XML-RPC <value>. Struct members are sorted by name, so output is stable.
+func writeXMLValue(buffer *bytes.Buffer, value any) {
+	buffer.WriteString("<value>")
+	switch v := value.(type) {
+	case int:
+		fmt.Fprintf(buffer, "<i4>%d</i4>", v)
+	case int64:
+		fmt.Fprintf(buffer, "<i4>%d</i4>", v)
+	case bool:
+		if v {
+			buffer.WriteString("<boolean>1</boolean>")
+		} else {
+			buffer.WriteString("<boolean>0</boolean>")
+		}
+	case float64:
+		fmt.Fprintf(buffer, "<double>%s</double>", strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		buffer.WriteString("<string>")
+		xml.EscapeText(buffer, []byte(v))
+		buffer.WriteString("</string>")
+	case []any:
+		buffer.WriteString("<array><data>")
+		for _, element := range v {
+			writeXMLValue(buffer, element)
+		}
+		buffer.WriteString("</data></array>")
+	case map[string]any:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buffer.WriteString("<struct>")
+		for _, name := range names {
+			buffer.WriteString("<member><name>")
+			xml.EscapeText(buffer, []byte(name))
+			buffer.WriteString("</name>")
+			writeXMLValue(buffer, v[name])
+			buffer.WriteString("</member>")
+		}
+		buffer.WriteString("</struct>")
+	}
+	buffer.WriteString("</value>")
+}
+
+//Reads a string parameter. Missing parameters yield "".
+func argString(params []any, i int) string {
+	if i >= len(params) || params[i] == nil {
+		return ""
+	}
+	switch v := params[i].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(params[i])
+}
+
+//Reads an integer parameter, accepting JSON numbers, XML-RPC integers and numeric strings. Missing parameters yield 0.
+func argInt(params []any, i int) (int, error) {
+	if i >= len(params) || params[i] == nil {
+		return 0, nil
+	}
+	switch v := params[i].(type) {
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(strings.TrimSpace(v))
+	}
+	return 0, fmt.Errorf("parameter %d is not a number", i+1)
+}
+
+//Reads a boolean parameter. Missing parameters yield false.
+func argBool(params []any, i int) bool {
+	if i >= len(params) {
+		return false
+	}
+	switch v := params[i].(type) {
+	case bool:
+		return v
+	case string:
+		parsed, _ := strconv.ParseBool(v)
+		return parsed
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	}
+	return false
+}
+
+//Reads an array of integer IDs.
+func argIDs(params []any, i int) ([]int, error) {
+	if i >= len(params) || params[i] == nil {
+		return nil, nil
+	}
+	values, ok := params[i].([]any)
+	if !ok {
+		return nil, fmt.Errorf("parameter %d is not an array", i+1)
+	}
+	ids := make([]int, 0, len(values))
+	for j := range values {
+		id, err := argInt(values, j)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+//Reads post-processing parameters, given as an array of {Name, Value} structs.
+func argParameters(params []any, i int) []Parameter {
+	if i >= len(params) {
+		return nil
+	}
+	values, _ := params[i].([]any)
+	var parameters []Parameter
+	for _, value := range values {
+		member, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		pair := []any{member["Name"], member["Value"]}
+		if name := argString(pair, 0); name != "" {
+			parameters = append(parameters, Parameter{Name: name, Value: argString(pair, 1)})
+		}
+	}
+	return parameters
+}
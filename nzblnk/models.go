@@ -0,0 +1,45 @@
+package nzblnk
+
+import (
+	"context"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/newznab"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//An nzblnk link, as shared on forums in place of an NZB file.
+type Link struct {
+	Title string
+	//Text to search Usenet subjects for, such as a release name or a file's subject.
+	Header string
+	Password string
+	//Newsgroups the release was posted to, with "a.b." abbreviations expanded.
+	Groups []string
+	//When the release was posted; zero unless the link carries a "d" parameter.
+	Date time.Time
+}
+
+//Turns a link into an NZB.
+type Resolver interface {
+	Resolve(ctx context.Context, link *Link) (*parser.Nzb, error)
+}
+
+//Header-search backend, such as a Usenet search engine or an indexer. Returns candidate NZBs for a header, best first; the resolver
+//checks them against the link itself.
+type Searcher interface {
+	Search(ctx context.Context, header string) ([]*parser.Nzb, error)
+}
+
+//Resolves links by asking each searcher in turn, taking the first candidate whose subjects contain the header and, if the link names
+//groups, that was posted to one of them. The link's title and password are applied to the NZB's meta.
+type SearchResolver struct {
+	Searchers []Searcher
+}
+
+//Searcher backed by a newznab indexer, downloading the NZBs of its top results.
+type NewznabSearcher struct {
+	Client *newznab.Client
+	//Results downloaded per search; 3 if zero.
+	Limit int
+}
@@ -0,0 +1,266 @@
+// Allows for parsing, generating and resolving nzblnk links (nzblnk:?t=title&h=header&p=password&g=group), which identify a release
+// by a searchable header rather than carrying its NZB.
+package nzblnk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/metaeditor"
+	"github.com/jgr0sz/nzbgo/newznab"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Errors returned while parsing, generating and resolving links.
+var (
+	ErrNotNzblnk = errors.New("nzblnk: not an nzblnk link")
+	ErrMissingTitle = errors.New("nzblnk: link has no title")
+	ErrMissingHeader = errors.New("nzblnk: link has no header")
+	ErrNoFiles = errors.New("nzblnk: NZB has no files to take a header from")
+	ErrNotFound = errors.New("nzblnk: no NZB found for the header")
+)
+
+//Compiled regex for the (n/m) and [n/m] counters, the byte size yenc.Subject appends after the last counter and the yEnc marker in
+//binary subjects, which differ between a release's articles and files and so are left out of headers.
+var SUBJECT_NOISE_PATTERN = *regexp.MustCompile(`(?i)[\[(]\d+/\d+[\])](\s+\d+\s*$)?|\byEnc\b`)
+
+//Layout of the "d" parameter.
+const dateLayout = "02.01.2006"
+
+//Parses an nzblnk link. Both "nzblnk:?" and "nzblnk://?" forms are accepted, as are links copied with HTML-escaped ampersands.
+func Parse(link string) (*Link, error) {
+	link = strings.TrimSpace(link)
+	scheme, rest, found := strings.Cut(link, ":")
+	if !found || !strings.EqualFold(scheme, "nzblnk") {
+		return nil, ErrNotNzblnk
+	}
+	rest = strings.TrimLeft(rest, "/")
+	rest = strings.TrimPrefix(rest, "?")
+	rest = strings.ReplaceAll(rest, "&amp;", "&")
+
+	//ParseQuery reports malformed pairs but still returns the well-formed ones, which is as much as a link copied from a forum
+	//deserves.
+	values, _ := url.ParseQuery(rest)
+	parsed := &Link{
+		Title: strings.TrimSpace(values.Get("t")),
+		Header: strings.TrimSpace(values.Get("h")),
+		Password: password(rest),
+	}
+	for _, g := range values["g"] {
+		for _, group := range strings.Split(g, ",") {
+			if group = expandGroup(strings.TrimSpace(group)); group != "" {
+				parsed.Groups = append(parsed.Groups, group)
+			}
+		}
+	}
+	if d := strings.TrimSpace(values.Get("d")); d != "" {
+		parsed.Date = parseDate(d)
+	}
+
+	switch {
+	case parsed.Title == "":
+		return nil, ErrMissingTitle
+	case parsed.Header == "":
+		return nil, ErrMissingHeader
+	}
+	return parsed, nil
+}
+
+//Reads the "p" parameter of a query. Unlike ParseQuery, a "+" is kept as is, since passwords are often pasted into links unescaped.
+func password(query string) string {
+	for _, pair := range strings.Split(query, "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if key != "p" {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			return unescaped
+		}
+		return value
+	}
+	return ""
+}
+
+//Expands the "a.b." abbreviation links commonly use for alt.binaries groups.
+func expandGroup(group string) string {
+	if strings.HasPrefix(strings.ToLower(group), "a.b.") {
+		return "alt.binaries." + group[4:]
+	}
+	return group
+}
+
+//Parses the "d" parameter, given either as dd.mm.yyyy or as a Unix timestamp. Zero if it is neither.
+func parseDate(value string) time.Time {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC()
+	}
+	date, _ := time.Parse(dateLayout, value)
+	return date
+}
+
+//Formats a link as an nzblnk URI. The password and date are left out when empty; spaces in the password are written as %20, as
+//Parse reads a "+" there literally.
+func (l *Link) String() string {
+	var builder strings.Builder
+	builder.WriteString("nzblnk:?t=" + url.QueryEscape(l.Title))
+	builder.WriteString("&h=" + url.QueryEscape(l.Header))
+	if l.Password != "" {
+		builder.WriteString("&p=" + strings.ReplaceAll(url.QueryEscape(l.Password), "+", "%20"))
+	}
+	for _, g := range l.Groups {
+		builder.WriteString("&g=" + url.QueryEscape(g))
+	}
+	if !l.Date.IsZero() {
+		builder.WriteString("&d=" + l.Date.UTC().Format(dateLayout))
+	}
+	return builder.String()
+}
+
+//Strips counters and the yEnc marker from a subject, leaving what search engines index it by.
+func cleanSubject(subject string) string {
+	return strings.Join(strings.Fields(SUBJECT_NOISE_PATTERN.ReplaceAllString(subject, " ")), " ")
+}
+
+//Builds a link describing an NZB: its title, first password and groups, with the main file's subject as the header. The NZB's title
+//falls back to the main file's set name.
+func FromNzb(nzb *parser.Nzb) (*Link, error) {
+	main, ok := mainFile(nzb)
+	if !ok {
+		return nil, ErrNoFiles
+	}
+	link := &Link{
+		Title: parser.Title(nzb.Head.Meta),
+		Header: cleanSubject(main.Subject),
+		Groups: parser.Groups(nzb),
+	}
+	if link.Title == "" {
+		link.Title = parser.SetName(parser.ExtractFilename(main))
+	}
+	if passwords := parser.Passwords(nzb.Head.Meta); len(passwords) > 0 {
+		link.Password = passwords[0]
+	}
+	if main.Date != 0 {
+		link.Date = parser.DatePosted(main)
+	}
+	if link.Header == "" {
+		return nil, ErrMissingHeader
+	}
+	return link, nil
+}
+
+//Builds an nzblnk URI for an NZB, as FromNzb describes.
+func Generate(nzb *parser.Nzb) (string, error) {
+	link, err := FromNzb(nzb)
+	if err != nil {
+		return "", err
+	}
+	return link.String(), nil
+}
+
+//Picks the main file as parser.MainFile does, falling back to the first file for NZBs holding nothing but par2 files, which MainFile
+//cannot handle.
+func mainFile(nzb *parser.Nzb) (parser.File, bool) {
+	if len(nzb.Files) == 0 {
+		return parser.File{}, false
+	}
+	for _, f := range nzb.Files {
+		if !parser.IsPar2(&f) && parser.FileSize(f) > 0 {
+			return parser.MainFile(nzb), true
+		}
+	}
+	return nzb.Files[0], true
+}
+
+//Checks whether a candidate NZB matches a link: a subject containing the header and, if the link names groups, a shared group.
+func matches(nzb *parser.Nzb, link *Link) bool {
+	header := strings.ToLower(cleanSubject(link.Header))
+	found := false
+	for _, f := range nzb.Files {
+		if strings.Contains(strings.ToLower(cleanSubject(f.Subject)), header) {
+			found = true
+			break
+		}
+	}
+	if !found || len(link.Groups) == 0 {
+		return found
+	}
+	for _, g := range parser.Groups(nzb) {
+		for _, wanted := range link.Groups {
+			if strings.EqualFold(g, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Resolves a link through the configured searchers. Searcher errors are only returned if no searcher found a match.
+func (r *SearchResolver) Resolve(ctx context.Context, link *Link) (*parser.Nzb, error) {
+	var errs []error
+	for _, searcher := range r.Searchers {
+		candidates, err := searcher.Search(ctx, link.Header)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			errs = append(errs, err)
+			continue
+		}
+		for _, candidate := range candidates {
+			if candidate != nil && matches(candidate, link) {
+				apply(candidate, link)
+				return candidate, nil
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(append([]error{ErrNotFound}, errs...)...)
+	}
+	return nil, ErrNotFound
+}
+
+//Copies the link's title and password into the NZB's meta.
+func apply(nzb *parser.Nzb, link *Link) {
+	editor := &metaeditor.NzbMetaEditor{Metadata: nzb.Head.Meta, Nzb: nzb}
+	metaeditor.Put(editor, "title", link.Title)
+	if link.Password != "" && !metaeditor.Contains(editor, "password", link.Password) {
+		metaeditor.Append(editor, "password", link.Password)
+	}
+	nzb.Head.Meta = editor.Metadata
+}
+
+//Searches the indexer for the header and downloads the NZBs of the top results.
+func (s *NewznabSearcher) Search(ctx context.Context, header string) ([]*parser.Nzb, error) {
+	limit := s.Limit
+	if limit <= 0 {
+		limit = 3
+	}
+	results, err := s.Client.Search(ctx, newznab.Query{Q: header, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	//A failed download only matters if it leaves nothing to check.
+	var (
+		nzbs []*parser.Nzb
+		failure error
+	)
+	for _, item := range results.Items[:min(limit, len(results.Items))] {
+		nzb, err := s.Client.Download(ctx, item)
+		if err != nil {
+			failure = fmt.Errorf("nzblnk: downloading %q: %w", item.Title, err)
+			continue
+		}
+		nzbs = append(nzbs, nzb)
+	}
+	if len(nzbs) == 0 {
+		return nil, failure
+	}
+	return nzbs, nil
+}
@@ -0,0 +1,110 @@
+package nzblnk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func TestParse(t *testing.T) {
+	//A "+" in the password is taken literally rather than as a space.
+	link, err := Parse("nzblnk://?t=Some.Release.2020&amp;h=abc123def&p=p%40ss+w%20rd&g=a.b.teevee&g=alt.binaries.hdtv,a.b.misc&d=17.12.2003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Title != "Some.Release.2020" || link.Header != "abc123def" || link.Password != "p@ss+w rd" {
+		t.Fatalf("unexpected link %+v", link)
+	}
+	if !slices.Equal(link.Groups, []string{"alt.binaries.teevee", "alt.binaries.hdtv", "alt.binaries.misc"}) {
+		t.Fatalf("unexpected groups %v", link.Groups)
+	}
+	if !link.Date.Equal(time.Date(2003, 12, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected date %v", link.Date)
+	}
+
+	//Formatting and parsing again gives the same link.
+	again, err := Parse(link.String())
+	if err != nil || again.String() != link.String() || again.Password != link.Password {
+		t.Fatalf("link did not survive a round trip: %v %+v", err, again)
+	}
+
+	if _, err := Parse("nzblnk:?t=Title&d=1071674882&h=x"); err != nil {
+		t.Fatal(err)
+	}
+	for input, want := range map[string]error{
+		"https://example.com/?t=a&h=b": ErrNotNzblnk,
+		"nzblnk:?h=header": ErrMissingTitle,
+		"NZBLNK:?t=title": ErrMissingHeader,
+	} {
+		if _, err := Parse(input); !errors.Is(err, want) {
+			t.Fatalf("%s: got %v, want %v", input, err, want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := Generate(nzb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "nzblnk:?t=title&h=Here%27s+your+file%21+abc-mr2a.r01&p=foobar%21&g=alt.binaries.newzbin&g=alt.binaries.mojo&d=17.12.2003"
+	if link != want {
+		t.Fatalf("got %s, want %s", link, want)
+	}
+	//The byte size ending the default subject format differs between files and is left out too.
+	posted := &parser.Nzb{Files: []parser.File{{Subject: yenc.Subject("Release.part1.rar", 1, 3, 1, 20, 15000000), Segments: []parser.Segment{{Number: 1, Bytes: 100}}}}}
+	if link, err := FromNzb(posted); err != nil || link.Header != `- "Release.part1.rar"` {
+		t.Fatalf("unexpected header for the default subject format: %+v, %v", link, err)
+	}
+	if _, err := Generate(&parser.Nzb{}); !errors.Is(err, ErrNoFiles) {
+		t.Fatalf("empty NZB gave %v", err)
+	}
+}
+
+//Searcher returning fixed candidates, or an error.
+type fakeSearcher struct {
+	candidates []*parser.Nzb
+	err error
+	headers []string
+}
+
+func (s *fakeSearcher) Search(ctx context.Context, header string) ([]*parser.Nzb, error) {
+	s.headers = append(s.headers, header)
+	return s.candidates, s.err
+}
+
+func TestResolve(t *testing.T) {
+	wrongGroup := &parser.Nzb{Files: []parser.File{{Subject: `"Wanted.Release.part1.rar" yEnc (1/3)`, Groups: []string{"alt.binaries.other"}}}}
+	right := &parser.Nzb{Files: []parser.File{{Subject: `[1/2] "Wanted.Release.part1.rar" yEnc (1/3)`, Groups: []string{"alt.binaries.teevee"}}}}
+	unrelated := &parser.Nzb{Files: []parser.File{{Subject: "Something else", Groups: []string{"alt.binaries.teevee"}}}}
+
+	failing := &fakeSearcher{err: errors.New("engine down")}
+	searcher := &fakeSearcher{candidates: []*parser.Nzb{unrelated, wrongGroup, right}}
+	resolver := &SearchResolver{Searchers: []Searcher{failing, searcher}}
+
+	link, _ := Parse(`nzblnk:?t=Wanted Release&h="wanted.release.part1.rar" yEnc&p=secret&g=a.b.teevee`)
+	nzb, err := resolver.Resolve(context.Background(), link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nzb != right || parser.Title(nzb.Head.Meta) != "Wanted Release" || !slices.Equal(parser.Passwords(nzb.Head.Meta), []string{"secret"}) {
+		t.Fatalf("unexpected resolution %+v", nzb)
+	}
+	if len(failing.headers) != 1 || searcher.headers[0] != link.Header {
+		t.Fatalf("searchers were not both asked: %v %v", failing.headers, searcher.headers)
+	}
+
+	link.Groups = []string{"alt.binaries.nothing"}
+	if _, err := resolver.Resolve(context.Background(), link); !errors.Is(err, ErrNotFound) || err.Error() == ErrNotFound.Error() {
+		t.Fatalf("expected not found with the searcher error, got %v", err)
+	}
+}
@@ -31,11 +31,29 @@ var OBFUSCATION_PATTERNS = [5]*regexp.Regexp {
 	regexp.MustCompile(`^abc\.xyz`),
 }
 
+//Compiled regex for the archive volume and par2 suffixes that tie files to a common set.
+var SET_PATTERN = *regexp.MustCompile(`(?i)(\.part\d+\.rar|\.rar|\.r\d{2,3}|\.[s-v]\d{2}|\.vol\d+[+-]\d+\.par2|\.par2|\.7z(\.\d{3})?|\.zip|\.z\d{2}|\.\d{3})$`)
+
 //Converts and retrieves the Unix-timestamped File field into UTC format.
 func DatePosted(file File) time.Time {
 	return time.Unix(file.Date, 0).UTC()
 }
 
+//Determines when an NZB was posted, taken as its oldest file date. Zero if no file carries a date.
+func Posted(nzb *Nzb) time.Time {
+	var posted time.Time
+	for _, f := range nzb.Files {
+		if f.Date == 0 {
+			continue
+		}
+		date := DatePosted(f)
+		if posted.IsZero() || date.Before(posted) {
+			posted = date
+		}
+	}
+	return posted
+}
+
 //Adds up all of the segment byte sizes of a file, returning its overall size.
 func FileSize(file File) int {
 	var segmentByteSize int
@@ -174,3 +192,8 @@ func HasExtension(file File, ext string) bool {
 	//Under case-folding, check if there is said extension within our filename.
 	return strings.EqualFold(fileExtension, strings.TrimPrefix(ext, "."))
 }
+
+//Determines the set a filename belongs to by stripping archive volume and par2 suffixes, so "show.part01.rar" and "show.vol00+01.par2" both yield "show".
+func SetName(filename string) string {
+	return SET_PATTERN.ReplaceAllString(filename, "")
+}
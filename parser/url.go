@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+//Returned when an NZB, once decompressed, is larger than allowed.
+var ErrTooLarge = errors.New("parser: NZB exceeds the size limit")
+
+//Largest NZB FromURLContext accepts, after decompression.
+const maxDownload = 256 << 20
+
+//Maps the X-DNZB headers indexers send with NZB downloads to the meta types they are stored under.
+var DNZB_HEADERS = [][2]string{
+	{"X-DNZB-Name", "title"},
+	{"X-DNZB-Category", "category"},
+	{"X-DNZB-Password", "password"},
+	{"X-DNZB-MoreInfo", "moreinfo"},
+	{"X-DNZB-Propername", "propername"},
+}
+
+//Downloads and instantiates an Nzb from a URL, merging the indexer's X-DNZB headers into its meta. Also returns the suggested
+//job name. See FromURLContext.
+func FromURL(url string) (*Nzb, string, error) {
+	return FromURLContext(context.Background(), nil, url)
+}
+
+//Downloads and instantiates an Nzb from a URL using client (http.DefaultClient if nil), following redirects and undoing gzip or
+//deflate compression. Meta from the X-DNZB headers is added unless the NZB already carries it: a title or category is only
+//added if the NZB has none, other types if the same value is not present yet.
+//The job name is taken from X-DNZB-Name, then the Content-Disposition filename, then the NZB's title, then the URL's last path element.
+func FromURLContext(ctx context.Context, client *http.Client, url string) (*Nzb, string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("parser: downloading %s: unexpected HTTP status %s", url, response.Status)
+	}
+
+	data, err := readBody(response)
+	if err != nil {
+		return nil, "", err
+	}
+	var nzb Nzb
+	if err := xml.Unmarshal(data, &nzb); err != nil {
+		return nil, "", err
+	}
+
+	for _, header := range DNZB_HEADERS {
+		if value := strings.TrimSpace(response.Header.Get(header[0])); value != "" {
+			mergeMeta(&nzb, header[1], value)
+		}
+	}
+
+	name := strings.TrimSpace(response.Header.Get("X-DNZB-Name"))
+	if name == "" {
+		name = StripNzbExtension(dispositionFilename(response.Header.Get("Content-Disposition")))
+	}
+	if name == "" {
+		name = Title(nzb.Head.Meta)
+	}
+	if name == "" {
+		//After redirects, the final URL usually names the file best.
+		name = StripNzbExtension(path.Base(response.Request.URL.Path))
+		if name == "." || name == "/" {
+			name = ""
+		}
+	}
+	return &nzb, name, nil
+}
+
+//Reads a response body, decompressing it if the server compressed it without the transport undoing it, or sent a gzipped file.
+func readBody(response *http.Response) ([]byte, error) {
+	var reader io.Reader = bufio.NewReader(response.Body)
+	switch strings.ToLower(response.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	case "deflate":
+		zlibReader, err := zlib.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer zlibReader.Close()
+		reader = zlibReader
+	}
+
+	data, err := readLimited(reader, maxDownload)
+	if err != nil {
+		return nil, err
+	}
+	//.nzb.gz files are often served as they are, without a Content-Encoding.
+	return Decompress(data, maxDownload)
+}
+
+//Undoes the gzip compression of a .nzb.gz file, recognised by its magic bytes. Other data is returned unchanged. The limit
+//applies to the decompressed data, as a small upload can expand to any size; ErrTooLarge is returned beyond it.
+func Decompress(data []byte, limit int64) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLimited(reader, limit)
+}
+
+//Reads everything from reader, failing with ErrTooLarge rather than reading more than limit bytes.
+func readLimited(reader io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+//Strips a trailing .nzb or .nzb.gz, case-insensitively.
+func StripNzbExtension(filename string) string {
+	lower := strings.ToLower(filename)
+	for _, extension := range []string{".nzb.gz", ".nzb"} {
+		if strings.HasSuffix(lower, extension) {
+			return filename[:len(filename)-len(extension)]
+		}
+	}
+	return filename
+}
+
+//Adds a meta entry unless the NZB already has it. Titles and categories are single-valued, so existing ones win.
+func mergeMeta(nzb *Nzb, metaType string, value string) {
+	for _, m := range nzb.Head.Meta {
+		if m.Type != metaType {
+			continue
+		}
+		if m.Value == value || metaType == "title" || metaType == "category" {
+			return
+		}
+	}
+	nzb.Head.Meta = append(nzb.Head.Meta, Meta{Type: metaType, Value: value})
+}
+
+//Extracts the filename from a Content-Disposition header, including RFC 2231 encoded ones. Directory parts are dropped.
+func dispositionFilename(disposition string) string {
+	if disposition == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(disposition)
+	if err != nil {
+		return ""
+	}
+	filename := strings.ReplaceAll(params["filename"], "\\", "/")
+	if filename == "" {
+		return ""
+	}
+	return path.Base(filename)
+}
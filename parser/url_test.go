@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestFromURL(t *testing.T) {
+	sample, err := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(sample)
+	gz.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/getnzb/1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/download/1", http.StatusFound)
+	})
+	mux.HandleFunc("/download/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-DNZB-Name", "Show.Name.S01E01.720p-GRP")
+		w.Header().Set("X-DNZB-Category", "TV > HD")
+		w.Header().Set("X-DNZB-Password", "foobar!")
+		w.Header().Set("X-DNZB-MoreInfo", "https://www.tvmaze.com/shows/1")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	})
+	mux.HandleFunc("/download/2", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''Caf%C3%A9.Movie.2020.nzb.gz`)
+		w.Header().Set("X-DNZB-Password", "second")
+		w.Write(compressed.Bytes())
+	})
+	mux.HandleFunc("/files/Plain.Name.nzb", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb"></nzb>`))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	nzb, name, err := FromURL(server.URL + "/getnzb/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Show.Name.S01E01.720p-GRP" || len(nzb.Files) == 0 {
+		t.Fatalf("unexpected name %q", name)
+	}
+	//The NZB already has a title, category and this password, so only the more-info link is added.
+	if len(nzb.Head.Meta) != 5 || Title(nzb.Head.Meta) != "title" || Category(nzb.Head.Meta) != "misc." || len(Passwords(nzb.Head.Meta)) != 1 {
+		t.Fatalf("meta was not merged without duplicates: %+v", nzb.Head.Meta)
+	}
+	if last := nzb.Head.Meta[4]; last.Type != "moreinfo" || last.Value != "https://www.tvmaze.com/shows/1" {
+		t.Fatalf("unexpected merged meta %+v", last)
+	}
+
+	nzb, name, err = FromURL(server.URL + "/download/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Café.Movie.2020" || len(Passwords(nzb.Head.Meta)) != 2 {
+		t.Fatalf("unexpected name %q or passwords %v", name, Passwords(nzb.Head.Meta))
+	}
+
+	if _, name, err = FromURL(server.URL + "/files/Plain.Name.nzb"); err != nil || name != "Plain.Name" {
+		t.Fatalf("unexpected name %q, %v", name, err)
+	}
+	if _, _, err = FromURL(server.URL + "/missing"); err == nil {
+		t.Fatal("expected an error for a 404")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(make([]byte, 1<<20))
+	gz.Close()
+
+	if data, err := Decompress(compressed.Bytes(), 1<<20); err != nil || len(data) != 1<<20 {
+		t.Fatalf("expected data within the limit to decompress, got %d bytes, %v", len(data), err)
+	}
+	//A few kilobytes expanding past the limit.
+	if _, err := Decompress(compressed.Bytes(), 1<<20-1); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+	if data, _ := Decompress([]byte("<nzb/>"), 1); string(data) != "<nzb/>" {
+		t.Fatal("uncompressed data was changed")
+	}
+	if name := StripNzbExtension("Some.Show.S01E01.NZB.gz"); name != "Some.Show.S01E01" {
+		t.Fatalf("unexpected name %q", name)
+	}
+}
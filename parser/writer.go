@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"log"
+	"os"
+)
+
+//XML prologue and doctype written at the top of every NZB.
+const nzbHeader = xml.Header + `<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">` + "\n"
+
+//Namespace of the <nzb> root element.
+const nzbNamespace = "http://www.newzbin.com/DTD/2003/nzb"
+
+//Serializes an Nzb instance into NZB 1.1 XML, with the doctype and namespaced <nzb> root other tools expect.
+func ToXML(nzb *Nzb) (string, error) {
+	var out bytes.Buffer
+	out.WriteString(nzbHeader)
+
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	root := xml.StartElement{
+		Name: xml.Name{Local: "nzb"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: nzbNamespace}},
+	}
+	if err := encoder.EncodeElement(nzb, root); err != nil {
+		log.Printf("Unable to marshal Nzb instance to XML: %v", err)
+		return "", err
+	}
+	out.WriteString("\n")
+	return out.String(), nil
+}
+
+//Writes an Nzb instance to a file path as NZB 1.1 XML.
+func WriteFile(nzb *Nzb, path string) error {
+	data, err := ToXML(nzb)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(data), 0644)
+}
@@ -0,0 +1,47 @@
+package planner
+
+import "time"
+
+//A server available for downloading. Zero values mean unlimited retention and unknown bandwidth.
+type Server struct {
+	Name string
+	RetentionDays int
+	Connections int
+	//Bandwidth of the account in bytes per second.
+	Bandwidth int64
+}
+
+//Settings for estimating a plan.
+type Options struct {
+	//Time the plan is made at; time.Now() if zero.
+	Now time.Time
+	//Throughput a single connection achieves, in bytes per second. Unlimited if zero.
+	PerConnection int64
+	//Bandwidth of the local line in bytes per second, capping the servers' combined throughput. Unlimited if zero.
+	LineBandwidth int64
+}
+
+//Retention verdict for a single server.
+type ServerPlan struct {
+	Name string
+	InRetention bool
+	//Throughput this server contributes, in bytes per second; 0 if unknown or out of retention.
+	Throughput int64
+}
+
+//Estimate for downloading an NZB. Sizes are in bytes. DownloadTime is zero when no server carries the post or no bandwidth figure was available.
+type Plan struct {
+	Posted time.Time
+	Age time.Duration
+	Servers []ServerPlan
+	//Whether at least one server still carries the post.
+	Available bool
+	EncodedSize int64
+	DecodedSize int64
+	//Decoded size of the archives (rar, 7z, zip) that will be extracted.
+	ArchiveSize int64
+	Par2Size int64
+	//Disk space needed to hold the download and its extracted contents at the same time.
+	RequiredSpace int64
+	DownloadTime time.Duration
+}
@@ -0,0 +1,89 @@
+// Allows for planning a download before queueing it: the age of a post, which servers still carry it, how large it will be once
+// decoded and extracted, and how long it will take.
+package planner
+
+import (
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Estimates the decoded size of a file by subtracting yEnc overhead from each of its segments.
+func DecodedSize(file parser.File) int64 {
+	var size int64
+	for _, s := range file.Segments {
+		size += yenc.EstimateDecodedSize(s.Bytes)
+	}
+	return size
+}
+
+//Estimates the throughput of a server from its bandwidth and connection count.
+func throughput(server Server, options Options) int64 {
+	byConnections := int64(0)
+	if options.PerConnection > 0 {
+		byConnections = int64(max(server.Connections, 1)) * options.PerConnection
+	}
+
+	switch {
+	case server.Bandwidth > 0 && byConnections > 0:
+		return min(server.Bandwidth, byConnections)
+	case server.Bandwidth > 0:
+		return server.Bandwidth
+	default:
+		return byConnections
+	}
+}
+
+//Builds a download plan for an NZB against a set of servers.
+func Estimate(nzb *parser.Nzb, servers []Server, options Options) *Plan {
+	now := options.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	plan := &Plan{Posted: parser.Posted(nzb)}
+	if !plan.Posted.IsZero() {
+		plan.Age = now.Sub(plan.Posted)
+	}
+
+	var combined int64
+	for _, s := range servers {
+		retention := time.Duration(s.RetentionDays) * 24 * time.Hour
+		serverPlan := ServerPlan{
+			Name: s.Name,
+			InRetention: s.RetentionDays <= 0 || plan.Age <= retention,
+		}
+		if serverPlan.InRetention {
+			serverPlan.Throughput = throughput(s, options)
+			combined += serverPlan.Throughput
+			plan.Available = true
+		}
+		plan.Servers = append(plan.Servers, serverPlan)
+	}
+	//The line only bounds servers that can serve the post at all.
+	if plan.Available && options.LineBandwidth > 0 && (combined == 0 || combined > options.LineBandwidth) {
+		combined = options.LineBandwidth
+	}
+
+	for _, f := range nzb.Files {
+		decoded := DecodedSize(f)
+		plan.EncodedSize += int64(parser.FileSize(f))
+		plan.DecodedSize += decoded
+
+		name := parser.ExtractFilename(f)
+		switch {
+		case parser.IsPar2(&f):
+			plan.Par2Size += decoded
+		//Par2 files match the set pattern too, but are taken by the case above.
+		case parser.IsRar(&f), parser.SET_PATTERN.MatchString(name):
+			plan.ArchiveSize += decoded
+		}
+	}
+
+	plan.RequiredSpace = plan.DecodedSize + plan.ArchiveSize
+	if combined > 0 {
+		plan.DownloadTime = time.Duration(float64(plan.EncodedSize) / float64(combined) * float64(time.Second))
+	}
+	return plan
+}
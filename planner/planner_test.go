@@ -0,0 +1,66 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func TestEstimate(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := parser.Posted(nzb).Add(40 * 24 * time.Hour)
+
+	plan := Estimate(nzb, []Server{
+		{Name: "block", RetentionDays: 30, Bandwidth: 1 << 30},
+		{Name: "main", Connections: 10, Bandwidth: 10 << 20},
+	}, Options{Now: now, PerConnection: 2 << 20})
+
+	if plan.Age != 40*24*time.Hour {
+		t.Fatalf("unexpected age %v", plan.Age)
+	}
+	if plan.Servers[0].InRetention || !plan.Servers[1].InRetention || !plan.Available {
+		t.Fatalf("unexpected retention verdicts: %+v", plan.Servers)
+	}
+	if plan.EncodedSize != int64(parser.Size(nzb)) || plan.DecodedSize >= plan.EncodedSize {
+		t.Fatalf("unexpected sizes: %d encoded, %d decoded", plan.EncodedSize, plan.DecodedSize)
+	}
+	if plan.RequiredSpace != plan.DecodedSize+plan.ArchiveSize {
+		t.Fatalf("unexpected required space %d", plan.RequiredSpace)
+	}
+
+	//Only the main server contributes, capped by its bandwidth rather than its ten connections.
+	expected := time.Duration(float64(plan.EncodedSize) / float64(10<<20) * float64(time.Second))
+	if plan.DownloadTime != expected {
+		t.Fatalf("download time %v, expected %v", plan.DownloadTime, expected)
+	}
+
+	if decoded := yenc.EstimateDecodedSize(739843); decoded < 716000 || decoded > 717600 {
+		t.Fatalf("a full 700 KiB article was estimated at %d bytes", decoded)
+	}
+}
+
+func TestEstimateArchivesAndUnavailablePosts(t *testing.T) {
+	nzb := &parser.Nzb{}
+	for _, name := range []string{"movie.part01.rar", "movie.r00", "movie.7z.001", "movie.mkv", "movie.vol0+1.par2"} {
+		nzb.Files = append(nzb.Files, parser.File{
+			Subject: `"` + name + `" yEnc (1/1)`,
+			Date: 1000000000,
+			Segments: []parser.Segment{{Number: 1, Bytes: 739843}},
+		})
+	}
+	decoded := yenc.EstimateDecodedSize(739843)
+
+	plan := Estimate(nzb, []Server{{Name: "block", RetentionDays: 30}}, Options{LineBandwidth: 10 << 20})
+	if plan.ArchiveSize != 3*decoded || plan.Par2Size != decoded {
+		t.Fatalf("unexpected archive and par2 sizes: %d, %d", plan.ArchiveSize, plan.Par2Size)
+	}
+	//No server carries a post this old, so the line's bandwidth says nothing about how long it would take.
+	if plan.Available || plan.DownloadTime != 0 {
+		t.Fatalf("unavailable post planned to take %v", plan.DownloadTime)
+	}
+}
@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+)
+
+//A server in the pool. Lower Priority values are tried first. Optional servers, such as block accounts, are only asked for
+//articles that every non-optional server reported missing. RetentionDays of zero means unlimited retention.
+type Server struct {
+	Name string
+	Config nntp.Config
+	//Cap on simultaneous connections, 1 if zero.
+	MaxConnections int
+	Priority int
+	Optional bool
+	RetentionDays int
+}
+
+//Settings for a pool's failure backoff. A failing server is skipped for BackoffBase, doubling with every consecutive failure up to
+//BackoffMax. They default to one second and five minutes.
+type Options struct {
+	BackoffBase time.Duration
+	BackoffMax time.Duration
+}
+
+//Health of a single server as tracked by the pool.
+type Health struct {
+	Name string
+	Failures int
+	RetryAt time.Time
+	LastError error
+	//Connections currently handed out, and open connections in total.
+	Busy int
+	Open int
+}
+
+//Connection and health state of one server.
+type member struct {
+	server Server
+	slots chan struct{}
+	idle chan *nntp.Client
+
+	mu sync.Mutex
+	busy int
+	failures int
+	retryAt time.Time
+	lastErr error
+}
+
+//A set of servers handing out connections for article fetches, failing over between priority tiers.
+type Pool struct {
+	members []*member
+	options Options
+	//Clock used for retention and backoff; replaceable in tests.
+	now func() time.Time
+}
@@ -0,0 +1,280 @@
+// Allows for spreading article fetches over several Usenet servers, with per-server connection limits, priority tiers,
+// fill-only servers, retention awareness and backoff for failing servers.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+)
+
+//Errors returned by the pool.
+var (
+	//No server could be asked: all are backing off, or none carry articles of that age.
+	ErrNoServerAvailable = errors.New("pool: no server available")
+	//Wrap an error returned from a Do callback with this to move on to the next server, optional ones included, without counting a
+	//failure against the current one, for example when an article's CRC did not match.
+	ErrNextServer = errors.New("pool: try next server")
+)
+
+//Creates a pool over the given servers. Connections are dialed lazily.
+func New(servers []Server, options Options) *Pool {
+	if options.BackoffBase <= 0 {
+		options.BackoffBase = time.Second
+	}
+	if options.BackoffMax <= 0 {
+		options.BackoffMax = 5 * time.Minute
+	}
+
+	p := &Pool{options: options, now: time.Now}
+	for _, s := range servers {
+		size := max(s.MaxConnections, 1)
+		p.members = append(p.members, &member{
+			server: s,
+			slots: make(chan struct{}, size),
+			idle: make(chan *nntp.Client, size),
+		})
+	}
+	return p
+}
+
+//Checks whether a server still carries articles posted at the given time. A zero time is always within retention.
+func (m *member) inRetention(posted time.Time, now time.Time) bool {
+	if m.server.RetentionDays <= 0 || posted.IsZero() {
+		return true
+	}
+	return now.Sub(posted) <= time.Duration(m.server.RetentionDays)*24*time.Hour
+}
+
+//Checks whether a server is outside its backoff window.
+func (m *member) healthy(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !now.Before(m.retryAt)
+}
+
+//Number of connections currently handed out.
+func (m *member) load() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.busy
+}
+
+//Orders the servers to try: non-optional before optional, then by priority, then least busy first so load spreads within a tier.
+func (p *Pool) order() []*member {
+	ordered := append([]*member{}, p.members...)
+	loads := map[*member]int{}
+	for _, m := range ordered {
+		loads[m] = m.load()
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		ma, mb := ordered[a], ordered[b]
+		if ma.server.Optional != mb.server.Optional {
+			return !ma.server.Optional
+		}
+		if ma.server.Priority != mb.server.Priority {
+			return ma.server.Priority < mb.server.Priority
+		}
+		return loads[ma] < loads[mb]
+	})
+	return ordered
+}
+
+//Takes an idle connection to a server, dialing a new one if its cap allows and waiting otherwise.
+func (p *Pool) acquire(ctx context.Context, m *member) (*nntp.Client, error) {
+	var client *nntp.Client
+	select {
+	case client = <-m.idle:
+	default:
+		select {
+		case client = <-m.idle:
+		case m.slots <- struct{}{}:
+			var err error
+			client, err = nntp.DialContext(ctx, m.server.Config)
+			if err != nil {
+				<-m.slots
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	m.mu.Lock()
+	m.busy++
+	m.mu.Unlock()
+	return client, nil
+}
+
+//Hands a connection back, keeping it for reuse if it is still usable.
+func (p *Pool) release(m *member, client *nntp.Client, usable bool) {
+	m.mu.Lock()
+	m.busy--
+	m.mu.Unlock()
+
+	if usable {
+		m.idle <- client
+		return
+	}
+	client.Close()
+	<-m.slots
+}
+
+//Records a failure, pushing the server's retry time back exponentially.
+func (p *Pool) fail(m *member, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+	m.lastErr = err
+	backoff := p.options.BackoffBase << min(m.failures-1, 30)
+	if backoff <= 0 || backoff > p.options.BackoffMax {
+		backoff = p.options.BackoffMax
+	}
+	m.retryAt = p.now().Add(backoff)
+}
+
+//Records a success, clearing the server's failure count.
+func (p *Pool) succeed(m *member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = 0
+	m.retryAt = time.Time{}
+	m.lastErr = nil
+}
+
+//Runs fn with a connection from each eligible server in turn until it succeeds, returning the name of the server that did.
+//A 430 or ErrNextServer from fn moves on to the next server and, like a server skipped for retention, unlocks optional servers, as
+//a fill server may hold an intact copy of an article the others have damaged. Network failures
+//and other server errors count against the server's health and also move on. posted is the article's posting time, used to skip
+//servers whose retention no longer covers it; pass the zero time if unknown.
+func (p *Pool) Do(ctx context.Context, posted time.Time, fn func(client *nntp.Client) error) (string, error) {
+	now := p.now()
+	var lastErr error = ErrNoServerAvailable
+
+	//Optional servers are usable from the start only when there is nothing else.
+	missing := true
+	for _, m := range p.members {
+		missing = missing && m.server.Optional
+	}
+
+	for _, m := range p.order() {
+		if m.server.Optional && !missing {
+			continue
+		}
+		//A server whose retention no longer covers the article cannot have it, which unlocks optional servers like a 430 does.
+		if !m.inRetention(posted, now) {
+			missing = true
+			continue
+		}
+		if !m.healthy(now) {
+			continue
+		}
+
+		client, err := p.acquire(ctx, m)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			p.fail(m, err)
+			lastErr = err
+			continue
+		}
+
+		err = fn(client)
+		switch {
+		case err == nil:
+			p.release(m, client, true)
+			p.succeed(m)
+			return m.server.Name, nil
+		case nntp.IsNoSuchArticle(err):
+			p.release(m, client, true)
+			missing = true
+		case errors.Is(err, ErrNextServer):
+			p.release(m, client, true)
+			missing = true
+		case nntp.IsProtocolError(err):
+			p.release(m, client, true)
+			p.fail(m, err)
+		default:
+			p.release(m, client, false)
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			p.fail(m, err)
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+//Fetches an article body from the first server that has it.
+func (p *Pool) Body(ctx context.Context, messageID string, posted time.Time) ([]byte, string, error) {
+	var body []byte
+	name, err := p.Do(ctx, posted, func(client *nntp.Client) error {
+		var err error
+		body, err = client.Body(messageID)
+		return err
+	})
+	return body, name, err
+}
+
+//Fetches an article body from the first server that has it, satisfying nntp.Fetcher. Retention is not taken into account.
+func (p *Pool) Fetch(ctx context.Context, messageID string) ([]byte, error) {
+	body, _, err := p.Body(ctx, messageID, time.Time{})
+	return body, err
+}
+
+//Checks whether any server has an article, returning the first that does.
+func (p *Pool) Stat(ctx context.Context, messageID string, posted time.Time) (string, error) {
+	return p.Do(ctx, posted, func(client *nntp.Client) error {
+		return client.Stat(messageID)
+	})
+}
+
+//Total number of connections the non-optional servers allow, a sensible number of concurrent fetchers to run against the pool.
+func (p *Pool) Capacity() int {
+	capacity := 0
+	for _, m := range p.members {
+		if !m.server.Optional {
+			capacity += cap(m.slots)
+		}
+	}
+	return capacity
+}
+
+//Reports the health of every server, in the order they were given.
+func (p *Pool) Health() []Health {
+	health := make([]Health, 0, len(p.members))
+	for _, m := range p.members {
+		m.mu.Lock()
+		health = append(health, Health{
+			Name: m.server.Name,
+			Failures: m.failures,
+			RetryAt: m.retryAt,
+			LastError: m.lastErr,
+			Busy: m.busy,
+			Open: len(m.slots),
+		})
+		m.mu.Unlock()
+	}
+	return health
+}
+
+//Closes every idle connection. Connections still handed out should be released first, and the pool not used afterwards.
+func (p *Pool) Close() {
+	for _, m := range p.members {
+		for {
+			select {
+			case client := <-m.idle:
+				client.Quit()
+				<-m.slots
+				continue
+			default:
+			}
+			break
+		}
+	}
+}
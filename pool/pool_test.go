@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+func TestPoolTiersAndFills(t *testing.T) {
+	nzb, err := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	present := nzb.Files[0].Segments[0].ID
+	onSecondTier := nzb.Files[1].Segments[0].ID
+	onFillOnly := nzb.Files[1].Segments[1].ID
+
+	main, _ := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Missing: map[string]bool{onSecondTier: true, onFillOnly: true}}})
+	defer main.Close()
+	secondary, _ := nntptest.NewServer(nzb, nil, nntptest.Options{Faults: nntptest.Faults{Missing: map[string]bool{onFillOnly: true}}})
+	defer secondary.Close()
+	fill, _ := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	defer fill.Close()
+
+	p := New([]Server{
+		{Name: "fill", Config: fill.Config(), Optional: true},
+		{Name: "secondary", Config: secondary.Config(), Priority: 1},
+		{Name: "main", Config: main.Config(), MaxConnections: 2},
+	}, Options{})
+	defer p.Close()
+
+	ctx := context.Background()
+	for id, want := range map[string]string{present: "main", onSecondTier: "secondary", onFillOnly: "fill"} {
+		name, err := p.Stat(ctx, id, time.Time{})
+		if err != nil || name != want {
+			t.Fatalf("%s served by %q (%v), expected %q", id, name, err, want)
+		}
+	}
+	if fill.CommandCount("STAT") != 1 {
+		t.Fatalf("fill server was asked %d times, expected only for the missing article", fill.CommandCount("STAT"))
+	}
+
+	//Concurrent fetches never exceed the main server's connection cap.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Body(ctx, present, time.Time{})
+		}()
+	}
+	wg.Wait()
+	if main.ConnectionCount() > 2 {
+		t.Fatalf("main server got %d connections", main.ConnectionCount())
+	}
+}
+
+func TestPoolRetentionAndBackoff(t *testing.T) {
+	nzb, _ := parser.FromFile("../_tests/nzbs/parser_test.nzb")
+	id := nzb.Files[0].Segments[0].ID
+	server, _ := nntptest.NewServer(nzb, nil, nntptest.Options{})
+	defer server.Close()
+
+	//A listener that is closed straight away gives an address nothing answers on.
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := nntp.Config{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, Timeout: time.Second}
+	listener.Close()
+
+	p := New([]Server{
+		{Name: "dead", Config: dead},
+		{Name: "short", Config: server.Config(), Priority: 1, RetentionDays: 30},
+		{Name: "long", Config: server.Config(), Priority: 2},
+	}, Options{BackoffBase: time.Hour})
+	defer p.Close()
+
+	posted := time.Now().AddDate(0, 0, -100)
+	name, err := p.Stat(context.Background(), id, posted)
+	if err != nil || name != "long" {
+		t.Fatalf("served by %q (%v), expected the long-retention server", name, err)
+	}
+
+	health := p.Health()
+	if health[0].Failures != 1 || !health[0].RetryAt.After(time.Now()) {
+		t.Fatalf("expected the dead server to back off: %+v", health[0])
+	}
+	if name, _ := p.Stat(context.Background(), id, time.Now()); name != "short" {
+		t.Fatalf("recent article served by %q, expected the short-retention server", name)
+	}
+
+	//Old articles fall through to fill servers when the only main server's retention does not reach them.
+	p = New([]Server{
+		{Name: "main", Config: server.Config(), RetentionDays: 30},
+		{Name: "fill", Config: server.Config(), Optional: true},
+	}, Options{})
+	defer p.Close()
+	if name, err := p.Stat(context.Background(), id, posted); err != nil || name != "fill" {
+		t.Fatalf("old article served by %q (%v), expected the fill server", name, err)
+	}
+	if name, _ := p.Stat(context.Background(), id, time.Now()); name != "main" {
+		t.Fatalf("recent article served by %q, expected the main server", name)
+	}
+
+	p = New([]Server{{Name: "dead", Config: dead}}, Options{BackoffBase: time.Hour})
+	p.Stat(context.Background(), id, time.Time{})
+	if _, err := p.Stat(context.Background(), id, time.Time{}); !errors.Is(err, ErrNoServerAvailable) {
+		t.Fatalf("expected ErrNoServerAvailable while backing off, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package poster
+
+import (
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//A file to post. Data is used when set; otherwise the file is read from Path. Name defaults to the base name of Path.
+type Input struct {
+	Name string
+	Path string
+	Data []byte
+}
+
+//Settings for posting.
+type Options struct {
+	Newsgroups []string
+	//Poster identity, as in the From header, such as "poster <poster@example.com>".
+	From string
+	//text/template for article subjects, given the fields of SubjectFields. Defaults to the yenc.Subject format, which
+	//parser.ExtractFilename understands.
+	SubjectTemplate string
+	//Domain of generated message-IDs; "nzbgo" if empty.
+	Domain string
+	//Raw bytes per article and encoded characters per line; the yenc defaults if zero.
+	PartSize int
+	LineLength int
+	//Attempts per article before giving up, 3 if zero.
+	Retries int
+	//Wait before retrying an article, doubling with every failed attempt up to BackoffMax. They default to one second and five
+	//minutes.
+	BackoffBase time.Duration
+	BackoffMax time.Duration
+	//Simultaneous connections used for posting, 1 if zero.
+	Connections int
+	//Metadata written into the head of the resulting NZB, such as a title or password.
+	Meta []parser.Meta
+	//Path the resulting NZB is written to; not written if empty.
+	Output string
+	//Posting date; time.Now() if zero.
+	Date time.Time
+}
+
+//Values available to SubjectTemplate.
+type SubjectFields struct {
+	Name string
+	FileNumber int
+	FileTotal int
+	Part int
+	Parts int
+	Size int64
+}
@@ -0,0 +1,236 @@
+// Allows for posting files to Usenet as yEnc-encoded articles and building the NZB that describes them.
+package poster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Errors returned by Post.
+var (
+	ErrNoNewsgroups = errors.New("poster: no newsgroups given")
+	ErrNoFiles = errors.New("poster: no files given")
+)
+
+//Subject template matching yenc.Subject.
+const defaultSubject = `[{{.FileNumber}}/{{.FileTotal}}] - "{{.Name}}" yEnc ({{.Part}}/{{.Parts}}) {{.Size}}`
+
+//An article waiting to be posted, with its place in the resulting NZB.
+type job struct {
+	file int
+	segment int
+	article yenc.Article
+}
+
+//Posts every input file to the configured newsgroups and returns an NZB describing the posted articles. Articles are posted over
+//options.Connections connections; each is retried on failure, reconnecting if the connection broke. The NZB is also written to
+//options.Output when it is set.
+func Post(ctx context.Context, config nntp.Config, inputs []Input, options Options) (*parser.Nzb, error) {
+	if len(options.Newsgroups) == 0 {
+		return nil, ErrNoNewsgroups
+	}
+	if len(inputs) == 0 {
+		return nil, ErrNoFiles
+	}
+	if options.Retries <= 0 {
+		options.Retries = 3
+	}
+	if options.BackoffBase <= 0 {
+		options.BackoffBase = time.Second
+	}
+	if options.BackoffMax <= 0 {
+		options.BackoffMax = 5 * time.Minute
+	}
+	if options.SubjectTemplate == "" {
+		options.SubjectTemplate = defaultSubject
+	}
+	if options.Date.IsZero() {
+		options.Date = time.Now()
+	}
+
+	subject, err := template.New("subject").Parse(options.SubjectTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	nzb := &parser.Nzb{
+		Head: parser.Head{Meta: options.Meta},
+		Files: make([]parser.File, len(inputs)),
+	}
+
+	var jobs []job
+	for i, input := range inputs {
+		name, data, err := load(input)
+		if err != nil {
+			return nil, err
+		}
+
+		articles := yenc.BuildArticles(name, data, yenc.ArticleOptions{
+			From: options.From,
+			Newsgroups: options.Newsgroups,
+			PartSize: options.PartSize,
+			LineLength: options.LineLength,
+			FileNumber: i + 1,
+			FileTotal: len(inputs),
+			Domain: options.Domain,
+			Date: options.Date,
+		})
+
+		nzb.Files[i] = parser.File{
+			Poster: options.From,
+			Date: options.Date.Unix(),
+			Groups: options.Newsgroups,
+			Segments: make([]parser.Segment, len(articles)),
+		}
+		for j := range articles {
+			var rendered bytes.Buffer
+			err := subject.Execute(&rendered, SubjectFields{
+				Name: name,
+				FileNumber: i + 1,
+				FileTotal: len(inputs),
+				Part: articles[j].Part.Number,
+				Parts: articles[j].Part.Total,
+				Size: articles[j].Part.FileSize,
+			})
+			if err != nil {
+				return nil, err
+			}
+			articles[j].Subject = rendered.String()
+			jobs = append(jobs, job{file: i, segment: j, article: articles[j]})
+		}
+		//NZBs carry the subject of a file's first article.
+		nzb.Files[i].Subject = articles[0].Subject
+	}
+
+	if err := postAll(ctx, config, jobs, nzb, options); err != nil {
+		return nil, err
+	}
+
+	if options.Output != "" {
+		if err := parser.WriteFile(nzb, options.Output); err != nil {
+			return nil, err
+		}
+	}
+	return nzb, nil
+}
+
+//Resolves an input's name and contents.
+func load(input Input) (string, []byte, error) {
+	name := input.Name
+	if name == "" {
+		name = filepath.Base(input.Path)
+	}
+	if input.Data != nil {
+		return name, input.Data, nil
+	}
+	data, err := os.ReadFile(input.Path)
+	return name, data, err
+}
+
+//Posts every job over a set of connections, filling in the NZB's segments as articles are accepted.
+func postAll(ctx context.Context, config nntp.Config, jobs []job, nzb *parser.Nzb, options Options) error {
+	queue := make(chan job)
+	go func() {
+		defer close(queue)
+		for _, j := range jobs {
+			select {
+			case queue <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	connections := max(options.Connections, 1)
+	errs := make([]error, connections)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for w := 0; w < connections; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var client *nntp.Client
+			defer func() {
+				if client != nil {
+					client.Quit()
+				}
+			}()
+
+			for j := range queue {
+				raw := yenc.ArticleBytes(&j.article)
+				if err := postWithRetry(ctx, config, &client, raw, options); err != nil {
+					errs[w] = fmt.Errorf("poster: posting part %d of %q: %w", j.article.Part.Number, j.article.Part.Name, err)
+					return
+				}
+
+				mu.Lock()
+				nzb.Files[j.file].Segments[j.segment] = parser.Segment{
+					Bytes: len(raw),
+					Number: j.article.Part.Number,
+					ID: j.article.MessageID,
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	//Draining in case every worker stopped early.
+	for range queue {
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+//Posts a single article, reconnecting after connection failures and retrying rejected posts once the backoff has passed.
+func postWithRetry(ctx context.Context, config nntp.Config, client **nntp.Client, raw []byte, options Options) error {
+	var err error
+	for attempt := 0; attempt < options.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := options.BackoffBase << min(attempt-1, 30)
+			if backoff <= 0 || backoff > options.BackoffMax {
+				backoff = options.BackoffMax
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if *client == nil {
+			if *client, err = nntp.DialContext(ctx, config); err != nil {
+				continue
+			}
+		}
+
+		if err = (*client).Post(raw); err == nil {
+			return nil
+		}
+		if !nntp.IsProtocolError(err) {
+			(*client).Close()
+			*client = nil
+		}
+		//Posting being forbidden outright will not change on a retry.
+		if errors.Is(err, nntp.ErrPostingNotPermitted) || nntp.IsAuthFailure(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package poster
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func TestPostBuildsReadableNzb(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{
+		Username: "user",
+		Password: "pass",
+		Faults: nntptest.Faults{FailPosts: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	contents := map[string][]byte{
+		"release.part01.rar": nntptest.FillerData("release.part01.rar", 2500),
+		"release.part02.rar": nntptest.FillerData("release.part02.rar", 1200),
+	}
+	output := filepath.Join(t.TempDir(), "release.nzb")
+	nzb, err := Post(context.Background(), server.Config(), []Input{
+		{Name: "release.part01.rar", Data: contents["release.part01.rar"]},
+		{Name: "release.part02.rar", Data: contents["release.part02.rar"]},
+	}, Options{
+		Newsgroups: []string{"alt.binaries.test"},
+		From: "poster <poster@example.com>",
+		PartSize: 1000,
+		Connections: 2,
+		BackoffBase: time.Millisecond,
+		Meta: []parser.Meta{{Type: "title", Value: "release"}},
+		Output: output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.CommandCount("POST"); got != 5+2 {
+		t.Fatalf("expected 7 POST commands including 2 retries, got %d", got)
+	}
+
+	read, err := parser.FromFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parser.Title(read.Head.Meta) != "release" || len(read.Files) != 2 {
+		t.Fatalf("written NZB does not round-trip: %+v", read)
+	}
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	for i, file := range nzb.Files {
+		name := parser.ExtractFilename(file)
+		if len(file.Groups) != 1 || file.Groups[0] != "alt.binaries.test" || file.Poster != "poster <poster@example.com>" || file.Date == 0 {
+			t.Fatalf("file %d has unexpected attributes: %+v", i, file)
+		}
+
+		var joined []byte
+		for j, segment := range file.Segments {
+			if segment.Number != j+1 || segment.Bytes == 0 || read.Files[i].Segments[j].ID != segment.ID {
+				t.Fatalf("unexpected segment %+v", segment)
+			}
+			body, err := client.Body(segment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part, err := yenc.Decode(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			joined = append(joined, part.Data...)
+		}
+		if !bytes.Equal(joined, contents[name]) {
+			t.Fatalf("posted articles of %q do not reassemble the file", name)
+		}
+	}
+}
+
+func TestPostSubjectTemplate(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	nzb, err := Post(context.Background(), server.Config(), []Input{{Name: "file.bin", Data: []byte("data")}}, Options{
+		Newsgroups: []string{"alt.binaries.test"},
+		SubjectTemplate: `{{.Name}} yEnc ({{.Part}}/{{.Parts}})`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nzb.Files[0].Subject != "file.bin yEnc (1/1)" {
+		t.Fatalf("unexpected subject %q", nzb.Files[0].Subject)
+	}
+}
+
+func TestPostGivesUpAfterRetries(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{Faults: nntptest.Faults{FailPosts: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	start := time.Now()
+	_, err = Post(context.Background(), server.Config(), []Input{{Name: "file.bin", Data: []byte("data")}}, Options{
+		Newsgroups: []string{"alt.binaries.test"},
+		Retries: 3,
+		BackoffBase: 50 * time.Millisecond,
+	})
+	if err == nil || !nntp.IsProtocolError(err) {
+		t.Fatalf("expected a posting error, got %v", err)
+	}
+	//Waiting 50ms before the second attempt and 100ms before the third.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("retries ran back to back, taking %v", elapsed)
+	}
+}
@@ -0,0 +1,60 @@
+package postprocess
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+//Opens the history file at path for appending, creating it if missing.
+func OpenHistory(path string) (*History, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &History{file: file}, nil
+}
+
+//Durably appends a result.
+func (h *History) Record(result *Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return h.file.Sync()
+}
+
+//Reads every recorded result, oldest first. A torn last line from a crash is ignored.
+func (h *History) Entries() ([]Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file, err := os.Open(h.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Result
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry Result
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+//Closes the history file.
+func (h *History) Close() error {
+	return h.file.Close()
+}
@@ -0,0 +1,135 @@
+package postprocess
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//A post-processing job: a downloaded NZB and the directory holding its files. Stages read and update it as the pipeline runs,
+//which is how later stages learn what earlier ones did.
+type Job struct {
+	Nzb *parser.Nzb
+	//Used to name deobfuscated files and the final folder.
+	Name string
+	Directory string
+	Category string
+	//Outcome of the last par2 verification, one entry per recovery set. Empty until Verify has run, or if there are no par2 files.
+	Verification []*Verification
+	//Archive sets extracted so far, by set name as returned by parser.SetName.
+	Extracted []string
+}
+
+//A step of post-processing. Returning an error wrapping ErrSkipped records the stage as skipped rather than failed.
+type Stage interface {
+	Name() string
+	Run(ctx context.Context, job *Job) error
+}
+
+//Outcome of a stage.
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed Status = "failed"
+	StatusSkipped Status = "skipped"
+	StatusCancelled Status = "cancelled"
+	StatusNotRun Status = "not run"
+)
+
+//Outcome of a single stage. Err is not serialized; Error carries its message into the history.
+type StageResult struct {
+	Stage string `json:"stage"`
+	Status Status `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Error string `json:"error,omitempty"`
+	Err error `json:"-"`
+}
+
+//Outcome of a pipeline run, also stored as a history entry. Directory is where the job's files ended up.
+type Result struct {
+	Name string `json:"name"`
+	Category string `json:"category,omitempty"`
+	Directory string `json:"directory"`
+	Started time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Success bool `json:"success"`
+	Stages []StageResult `json:"stages"`
+}
+
+//An ordered chain of stages. The first failing stage stops the chain.
+type Pipeline struct {
+	Stages []Stage
+	//Records every run when set.
+	History *History
+}
+
+//Append-only log of pipeline results, one JSON object per line.
+type History struct {
+	file *os.File
+	mu sync.Mutex
+}
+
+//State of a file described by a par2 set.
+type FileCheck struct {
+	Name string
+	Size int64
+	//Whether the file was found, possibly under another name.
+	Found bool
+	//Whether the file's MD5 matched.
+	Intact bool
+	//Name the file was found under when par2 identified an obfuscated or misnamed file; it has been renamed to Name.
+	RenamedFrom string
+	//Slices that failed their checksum, or all of them for a missing file.
+	BadSlices int
+}
+
+//Outcome of verifying a par2 recovery set.
+type Verification struct {
+	//Par2 file to hand to a repair tool; the index file when there is one.
+	Par2File string
+	SliceSize int64
+	Files []FileCheck
+	//Slices needing repair, and recovery slices available across the set's par2 files.
+	BlocksNeeded int
+	RecoveryBlocks int
+}
+
+//Verifies every par2 set in the job directory, renaming files par2 identifies by content. Fails if a set is damaged, unless
+//AllowDamaged is set so a following Repair stage can fix it.
+type Verify struct {
+	AllowDamaged bool
+}
+
+//Repairs damaged par2 sets with an external tool, then verifies again. Skipped when every set verified intact.
+type Repair struct {
+	//Command and leading arguments; the par2 file is appended. Defaults to par2cmdline's "par2 repair -q".
+	Command []string
+}
+
+//Extracts archives in the job directory: zip files natively, split .001 files by joining them, and rar and 7z sets with external
+//tools. The NZB's passwords are tried in order.
+type Extract struct {
+	//Commands and leading arguments for rar and 7z sets. The password, output and archive arguments are appended.
+	//Default to "unrar x -o+ -y" and "7z x -y".
+	Unrar []string
+	SevenZip []string
+}
+
+//Renames files whose names look obfuscated after the job. The largest file takes the plain job name.
+type Deobfuscate struct{}
+
+//Deletes par2 files and the volumes of extracted archive sets, plus files with any of Extensions, such as ".sfv".
+type Cleanup struct {
+	Extensions []string
+}
+
+//Moves the job directory under the directory of its category, falling back to Default. Skipped when neither applies.
+type Move struct {
+	//Destination directories by category, matched case-insensitively.
+	Categories map[string]string
+	Default string
+}
@@ -0,0 +1,338 @@
+package postprocess
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//Par2 packet magic and the packet types verification needs.
+var (
+	par2Magic = []byte("PAR2\x00PKT")
+	par2Main = []byte("PAR 2.0\x00Main\x00\x00\x00\x00")
+	par2FileDesc = []byte("PAR 2.0\x00FileDesc")
+	par2Checksums = []byte("PAR 2.0\x00IFSC\x00\x00\x00\x00")
+	par2Recovery = []byte("PAR 2.0\x00RecvSlic")
+)
+
+//Size of a par2 packet header: magic, length, packet hash, recovery set ID and type.
+const par2HeaderSize = 64
+
+//Bytes covered by a par2 file's "16k" hash, used to identify renamed files.
+const par2HashPrefix = 16384
+
+//Largest slice size accepted. Verification reads a slice at a time, so a main packet must not be able to demand any buffer size.
+const par2MaxSliceSize = 256 << 20
+
+//Description of a file in a recovery set.
+type par2File struct {
+	name string
+	size int64
+	hash [16]byte
+	hash16k [16]byte
+	slices [][16]byte
+}
+
+//Packets of a recovery set, gathered over every par2 file that carries them.
+type par2Set struct {
+	sliceSize int64
+	files map[[16]byte]*par2File
+	exponents map[uint32]bool
+	par2Files []string
+}
+
+//Reads every par2 file in dir and verifies the recovery sets they describe. Files par2 identifies by size and 16k hash under another
+//name are renamed back to their real names. Returns nil if dir holds no par2 files.
+func VerifyPar2(ctx context.Context, dir string) ([]*Verification, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := map[[16]byte]*par2Set{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".par2") {
+			continue
+		}
+		if err := readPar2(filepath.Join(dir, entry.Name()), sets); err != nil {
+			return nil, err
+		}
+	}
+
+	var verifications []*Verification
+	for _, set := range sets {
+		//A set is only usable once its main packet, and thus the slice size, was found.
+		if set.sliceSize == 0 {
+			continue
+		}
+		verification, err := verifySet(ctx, dir, set)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, verification)
+	}
+	sort.Slice(verifications, func(i, j int) bool {
+		return verifications[i].Par2File < verifications[j].Par2File
+	})
+	return verifications, nil
+}
+
+//Whether every file of the set is present and intact.
+func (v *Verification) OK() bool {
+	return v.BlocksNeeded == 0
+}
+
+//Whether the set has enough recovery slices to repair its damage.
+func (v *Verification) Repairable() bool {
+	return v.BlocksNeeded <= v.RecoveryBlocks
+}
+
+//Reads the packets of a par2 file into sets, keyed by recovery set ID. Recovery slices are counted but not read. Packets failing
+//their hash are skipped, and reading stops at the first damaged header, including one claiming more bytes than the file has left.
+func readPar2(path string, sets map[[16]byte]*par2Set) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	remaining := uint64(info.Size())
+	reader := bufio.NewReader(file)
+
+	header := make([]byte, par2HeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return nil
+		}
+		length := binary.LittleEndian.Uint64(header[8:16])
+		if !bytes.Equal(header[:8], par2Magic) || length < par2HeaderSize || length%4 != 0 || length > remaining {
+			return nil
+		}
+		remaining -= length
+
+		var setID [16]byte
+		copy(setID[:], header[32:48])
+		set := sets[setID]
+		if set == nil {
+			set = &par2Set{files: map[[16]byte]*par2File{}, exponents: map[uint32]bool{}}
+			sets[setID] = set
+		}
+		if len(set.par2Files) == 0 || set.par2Files[len(set.par2Files)-1] != path {
+			set.par2Files = append(set.par2Files, path)
+		}
+
+		bodySize := int64(length - par2HeaderSize)
+		packetType := header[48:64]
+		if bytes.Equal(packetType, par2Recovery) {
+			if bodySize < 4 {
+				return nil
+			}
+			var exponent [4]byte
+			if _, err := io.ReadFull(reader, exponent[:]); err != nil {
+				return nil
+			}
+			set.exponents[binary.LittleEndian.Uint32(exponent[:])] = true
+			if _, err := reader.Discard(int(bodySize - 4)); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		body := make([]byte, bodySize)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil
+		}
+		hash := md5.New()
+		hash.Write(header[32:])
+		hash.Write(body)
+		if !bytes.Equal(hash.Sum(nil), header[16:32]) {
+			continue
+		}
+		parsePacket(set, packetType, body)
+	}
+}
+
+//Records the contents of a verified packet.
+func parsePacket(set *par2Set, packetType []byte, body []byte) {
+	fileFor := func(id []byte) *par2File {
+		var key [16]byte
+		copy(key[:], id)
+		if set.files[key] == nil {
+			set.files[key] = &par2File{}
+		}
+		return set.files[key]
+	}
+
+	switch {
+	case bytes.Equal(packetType, par2Main) && len(body) >= 12:
+		set.sliceSize = int64(binary.LittleEndian.Uint64(body[:8]))
+	case bytes.Equal(packetType, par2FileDesc) && len(body) >= 56:
+		f := fileFor(body[:16])
+		copy(f.hash[:], body[16:32])
+		copy(f.hash16k[:], body[32:48])
+		f.size = int64(binary.LittleEndian.Uint64(body[48:56]))
+		f.name = string(bytes.TrimRight(body[56:], "\x00"))
+	case bytes.Equal(packetType, par2Checksums) && len(body) >= 16:
+		f := fileFor(body[:16])
+		f.slices = f.slices[:0]
+		for entry := body[16:]; len(entry) >= 20; entry = entry[20:] {
+			var sliceHash [16]byte
+			copy(sliceHash[:], entry[:16])
+			f.slices = append(f.slices, sliceHash)
+		}
+	}
+}
+
+//Checks every file of a recovery set against its hashes.
+func verifySet(ctx context.Context, dir string, set *par2Set) (*Verification, error) {
+	verification := &Verification{
+		Par2File: set.par2Files[0],
+		SliceSize: set.sliceSize,
+		RecoveryBlocks: len(set.exponents),
+	}
+	//Repair tools expect the index file, the one without a .volNN+NN suffix.
+	for _, p := range set.par2Files {
+		if !strings.Contains(strings.ToLower(filepath.Base(p)), ".vol") {
+			verification.Par2File = p
+			break
+		}
+	}
+
+	var files []*par2File
+	for _, f := range set.files {
+		if f.name != "" {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		check, err := checkFile(dir, f, set.sliceSize)
+		if err != nil {
+			return nil, err
+		}
+		verification.Files = append(verification.Files, check)
+		verification.BlocksNeeded += check.BadSlices
+	}
+	return verification, nil
+}
+
+//Verifies a single file, looking for it by content when it is missing under its own name.
+func checkFile(dir string, f *par2File, sliceSize int64) (FileCheck, error) {
+	check := FileCheck{Name: f.name, Size: f.size}
+	//Names come from the par2 file, so they must not escape the job directory.
+	if f.name != filepath.Base(f.name) {
+		return check, fmt.Errorf("postprocess: par2 file name %q is not a plain filename", f.name)
+	}
+	//Sizes come from the par2 file too; they are checked before dividing by or allocating them.
+	if sliceSize <= 0 || sliceSize > par2MaxSliceSize || sliceSize%4 != 0 {
+		return check, fmt.Errorf("postprocess: par2 slice size %d is invalid", sliceSize)
+	}
+	if f.size < 0 {
+		return check, fmt.Errorf("postprocess: par2 size %d of %q is invalid", f.size, f.name)
+	}
+	path := filepath.Join(dir, f.name)
+	totalSlices := int(f.size / sliceSize)
+	if f.size%sliceSize != 0 {
+		totalSlices++
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		found, err := findByHash(dir, f)
+		if err != nil {
+			return check, err
+		}
+		if found == "" {
+			check.BadSlices = totalSlices
+			return check, nil
+		}
+		if err := os.Rename(filepath.Join(dir, found), path); err != nil {
+			return check, err
+		}
+		check.RenamedFrom = found
+	} else if err != nil {
+		return check, err
+	}
+	check.Found = true
+
+	file, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	defer file.Close()
+
+	whole := md5.New()
+	buffer := make([]byte, sliceSize)
+	for i := 0; i < totalSlices; i++ {
+		n, err := io.ReadFull(file, buffer)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return check, err
+		}
+		//A file shorter than described has every remaining slice missing.
+		if n == 0 {
+			check.BadSlices += totalSlices - i
+			break
+		}
+		whole.Write(buffer[:n])
+		//Slice hashes cover the slice padded with zeros.
+		clear(buffer[n:])
+		if i >= len(f.slices) || md5.Sum(buffer) != f.slices[i] {
+			check.BadSlices++
+		}
+	}
+	//Data beyond the described size also makes the file differ.
+	extra, _ := io.Copy(whole, file)
+
+	check.Intact = extra == 0 && bytes.Equal(whole.Sum(nil), f.hash[:])
+	if !check.Intact && check.BadSlices == 0 {
+		//Only data beyond the described size differs; repair tools truncate it, but the file still needs repairing.
+		check.BadSlices = 1
+	}
+	return check, nil
+}
+
+//Looks for a file in dir with the described size and 16k hash, returning its name.
+func findByHash(dir string, f *par2File) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || info.Size() != f.size || strings.EqualFold(filepath.Ext(entry.Name()), ".par2") {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		hash := md5.New()
+		_, err = io.CopyN(hash, file, par2HashPrefix)
+		file.Close()
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if bytes.Equal(hash.Sum(nil), f.hash16k[:]) {
+			return entry.Name(), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,96 @@
+// Allows for running post-processing on downloaded jobs (par2 verification and repair, extraction, deobfuscation, cleanup and
+// moving) as a pipeline of pluggable stages, recording a history of every run.
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Returned by stages that had nothing to do.
+var ErrSkipped = errors.New("postprocess: stage skipped")
+
+//Creates a job for an NZB downloaded into dir, named after its title (or the directory) and filed under its category.
+func NewJob(nzb *parser.Nzb, dir string) *Job {
+	job := &Job{Nzb: nzb, Directory: dir, Name: filepath.Base(dir)}
+	if nzb != nil {
+		if title := strings.TrimSpace(parser.Title(nzb.Head.Meta)); title != "" {
+			job.Name = title
+		}
+		job.Category = parser.Category(nzb.Head.Meta)
+	}
+	return job
+}
+
+//Returns the usual chain: verify, repair, extract, deobfuscate, clean up and move.
+func DefaultStages(move Move) []Stage {
+	return []Stage{Verify{AllowDamaged: true}, Repair{}, Extract{}, Deobfuscate{}, Cleanup{}, move}
+}
+
+//Creates a pipeline running the given stages in order.
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+//Runs every stage in order until one fails or ctx is cancelled, then records the result in the history. The returned error is
+//that of the failing stage, or ctx's error; the result is returned either way.
+func (p *Pipeline) Run(ctx context.Context, job *Job) (*Result, error) {
+	result := &Result{
+		Name: job.Name,
+		Category: job.Category,
+		Started: time.Now(),
+		Stages: make([]StageResult, 0, len(p.Stages)),
+	}
+
+	var runErr error
+	for _, stage := range p.Stages {
+		stageResult := StageResult{Stage: stage.Name(), Status: StatusNotRun}
+		if runErr == nil {
+			if runErr = ctx.Err(); runErr != nil {
+				stageResult.Status = StatusCancelled
+			} else {
+				stageResult, runErr = runStage(ctx, stage, job)
+			}
+		}
+		result.Stages = append(result.Stages, stageResult)
+	}
+
+	result.Success = runErr == nil
+	result.Directory = job.Directory
+	result.Finished = time.Now()
+	if p.History != nil {
+		if err := p.History.Record(result); err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+	}
+	return result, runErr
+}
+
+//Runs a single stage, classifying its outcome. The returned error is set only when the pipeline has to stop.
+func runStage(ctx context.Context, stage Stage, job *Job) (StageResult, error) {
+	started := time.Now()
+	err := stage.Run(ctx, job)
+	result := StageResult{Stage: stage.Name(), Duration: time.Since(started), Status: StatusSucceeded}
+
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.Is(err, ErrSkipped):
+		result.Status = StatusSkipped
+		return result, nil
+	case ctx.Err() != nil:
+		result.Status = StatusCancelled
+		err = ctx.Err()
+	default:
+		result.Status = StatusFailed
+	}
+	result.Err = err
+	result.Error = err.Error()
+	return result, fmt.Errorf("postprocess: %s: %w", stage.Name(), err)
+}
@@ -0,0 +1,250 @@
+package postprocess
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Builds a par2 packet of the given type.
+func par2Packet(setID []byte, packetType []byte, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	hash := md5.New()
+	hash.Write(setID)
+	hash.Write(packetType)
+	hash.Write(body)
+
+	packet := append([]byte{}, par2Magic...)
+	packet = binary.LittleEndian.AppendUint64(packet, uint64(par2HeaderSize+len(body)))
+	packet = append(packet, hash.Sum(nil)...)
+	packet = append(packet, setID...)
+	packet = append(packet, packetType...)
+	return append(packet, body...)
+}
+
+//Writes a par2 file protecting files, with the given number of (meaningless) recovery slices.
+func writePar2(t *testing.T, path string, sliceSize int, files map[string][]byte, recovery int) {
+	setID := bytes.Repeat([]byte{7}, 16)
+	main := binary.LittleEndian.AppendUint64(nil, uint64(sliceSize))
+	main = binary.LittleEndian.AppendUint32(main, uint32(len(files)))
+	for name := range files {
+		id := md5.Sum([]byte(name))
+		main = append(main, id[:]...)
+	}
+	out := par2Packet(setID, par2Main, main)
+
+	for name, data := range files {
+		id := md5.Sum([]byte(name))
+		whole := md5.Sum(data)
+		prefix := md5.Sum(data[:min(len(data), par2HashPrefix)])
+
+		desc := append(append(append([]byte{}, id[:]...), whole[:]...), prefix[:]...)
+		desc = binary.LittleEndian.AppendUint64(desc, uint64(len(data)))
+		out = append(out, par2Packet(setID, par2FileDesc, append(desc, name...))...)
+
+		checksums := append([]byte{}, id[:]...)
+		for offset := 0; offset < len(data); offset += sliceSize {
+			slice := make([]byte, sliceSize)
+			copy(slice, data[offset:])
+			sum := md5.Sum(slice)
+			checksums = append(append(checksums, sum[:]...), 0, 0, 0, 0)
+		}
+		out = append(out, par2Packet(setID, par2Checksums, checksums)...)
+	}
+	for i := 0; i < recovery; i++ {
+		out = append(out, par2Packet(setID, par2Recovery, append(binary.LittleEndian.AppendUint32(nil, uint32(i)), make([]byte, sliceSize)...))...)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//Builds a zip archive holding files.
+func zipData(t *testing.T, files map[string][]byte) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, data := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func statuses(result *Result) map[string]Status {
+	found := map[string]Status{}
+	for _, s := range result.Stages {
+		found[s.Stage] = s.Status
+	}
+	return found
+}
+
+func TestPipelineProcessesJob(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "incoming")
+	os.Mkdir(dir, 0755)
+
+	video := bytes.Repeat([]byte("frame"), 5000)
+	archive := zipData(t, map[string][]byte{"5f1e5e9a2c0d4b7e8a6f3c1d9b2e4a70.mkv": video})
+	writePar2(t, filepath.Join(dir, "release.par2"), 4096, map[string][]byte{"release.zip": archive}, 2)
+	//The archive was posted under an obfuscated name that only par2 can map back.
+	os.WriteFile(filepath.Join(dir, "c0ffee.bin"), archive, 0644)
+	os.WriteFile(filepath.Join(dir, "release.sfv"), []byte("release.zip 00000000"), 0644)
+
+	history, err := OpenHistory(filepath.Join(root, "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	nzb := &parser.Nzb{Head: parser.Head{Meta: []parser.Meta{{Type: "title", Value: "Show.Name.S01E01"}, {Type: "category", Value: "TV"}}}}
+	job := NewJob(nzb, dir)
+	pipeline := New(DefaultStages(Move{Categories: map[string]string{"tv": filepath.Join(root, "tv")}})...)
+	pipeline.Stages[4] = Cleanup{Extensions: []string{"sfv"}}
+	pipeline.History = history
+
+	result, err := pipeline.Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{"verify": StatusSucceeded, "repair": StatusSkipped, "extract": StatusSucceeded, "deobfuscate": StatusSucceeded, "cleanup": StatusSucceeded, "move": StatusSucceeded}
+	for stage, status := range want {
+		if statuses(result)[stage] != status {
+			t.Fatalf("stage %s: expected %s, got %+v", stage, status, result.Stages)
+		}
+	}
+	if job.Verification[0].Files[0].RenamedFrom != "c0ffee.bin" {
+		t.Fatalf("par2 did not identify the renamed archive: %+v", job.Verification[0].Files)
+	}
+
+	final := filepath.Join(root, "tv", "Show.Name.S01E01")
+	if result.Directory != final || job.Directory != final {
+		t.Fatalf("job ended up in %q", result.Directory)
+	}
+	entries, _ := os.ReadDir(final)
+	if len(entries) != 1 || entries[0].Name() != "Show.Name.S01E01.mkv" {
+		t.Fatalf("unexpected final contents: %v", entries)
+	}
+	if data, _ := os.ReadFile(filepath.Join(final, "Show.Name.S01E01.mkv")); !bytes.Equal(data, video) {
+		t.Fatal("extracted file does not match")
+	}
+
+	recorded, err := history.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 1 || !recorded[0].Success || recorded[0].Category != "TV" || len(recorded[0].Stages) != 6 {
+		t.Fatalf("unexpected history: %+v", recorded)
+	}
+}
+
+func TestRepairRunsToolAndReverifies(t *testing.T) {
+	dir := t.TempDir()
+	good := bytes.Repeat([]byte("0123456789"), 1000)
+	writePar2(t, filepath.Join(dir, "data.par2"), 1024, map[string][]byte{"data.bin": good}, 3)
+
+	damaged := append([]byte{}, good...)
+	damaged[10] ^= 0xff
+	damaged[5000] ^= 0xff
+	os.WriteFile(filepath.Join(dir, "data.bin"), damaged, 0644)
+	source := filepath.Join(t.TempDir(), "good.bin")
+	os.WriteFile(source, good, 0644)
+
+	job := NewJob(nil, dir)
+	pipeline := New(Verify{AllowDamaged: true}, Repair{Command: []string{"sh", "-c", `cp "$0" data.bin.1 && cp "$0" data.bin`, source}})
+	result, err := pipeline.Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses(result)["repair"] != StatusSucceeded || !job.Verification[0].OK() {
+		t.Fatalf("repair did not succeed: %+v", result.Stages)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data.bin.1")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("the repair backup was not removed")
+	}
+
+	//Two damaged slices with one recovery slice cannot be repaired.
+	writePar2(t, filepath.Join(dir, "data.par2"), 1024, map[string][]byte{"data.bin": good}, 1)
+	os.WriteFile(filepath.Join(dir, "data.bin"), damaged, 0644)
+	pipeline = New(DefaultStages(Move{})...)
+	result, err = pipeline.Run(context.Background(), NewJob(nil, dir))
+	if !errors.Is(err, ErrNotRepairable) || result.Success {
+		t.Fatalf("expected ErrNotRepairable, got %v", err)
+	}
+	if got := statuses(result); got["verify"] != StatusSucceeded || got["repair"] != StatusFailed || got["extract"] != StatusNotRun {
+		t.Fatalf("unexpected stage results: %+v", result.Stages)
+	}
+}
+
+func TestExtractJoinsSplitFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "movie.mkv.001"), []byte("first "), 0644)
+	os.WriteFile(filepath.Join(dir, "movie.mkv.002"), []byte("second"), 0644)
+
+	job := NewJob(nil, dir)
+	if _, err := New(Extract{}, Cleanup{}).Run(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("split pieces were not cleaned up: %v", entries)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "movie.mkv")); string(data) != "first second" {
+		t.Fatalf("unexpected joined data %q", data)
+	}
+}
+
+func TestPipelineCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := New(DefaultStages(Move{})...).Run(ctx, NewJob(nil, t.TempDir()))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if result.Stages[0].Status != StatusCancelled || result.Stages[1].Status != StatusNotRun {
+		t.Fatalf("unexpected stage results: %+v", result.Stages)
+	}
+}
+
+func TestVerifyPar2MalformedSizes(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	os.WriteFile(filepath.Join(dir, "data.bin"), data, 0644)
+
+	//A packet claiming more bytes than the file holds ends reading rather than being allocated.
+	setID := bytes.Repeat([]byte{7}, 16)
+	packet := par2Packet(setID, par2Main, binary.LittleEndian.AppendUint64(nil, 1024))
+	binary.LittleEndian.PutUint64(packet[8:16], 1<<40)
+	os.WriteFile(filepath.Join(dir, "data.par2"), packet, 0644)
+	verifications, err := VerifyPar2(context.Background(), dir)
+	if err != nil || len(verifications) != 0 {
+		t.Fatalf("expected the oversized packet to be ignored, got %+v, %v", verifications, err)
+	}
+
+	//Slice sizes that are absurd, negative as an int64 or not a multiple of four.
+	for _, sliceSize := range []uint64{1 << 40, 1 << 63, 1022} {
+		writePar2(t, filepath.Join(dir, "data.par2"), 1024, map[string][]byte{"data.bin": data}, 0)
+		out, _ := os.ReadFile(filepath.Join(dir, "data.par2"))
+		main := par2Packet(setID, par2Main, append(binary.LittleEndian.AppendUint64(nil, sliceSize), out[par2HeaderSize+8:par2HeaderSize+28]...))
+		os.WriteFile(filepath.Join(dir, "data.par2"), append(main, out[len(main):]...), 0644)
+		if _, err := VerifyPar2(context.Background(), dir); err == nil {
+			t.Fatalf("slice size %d was accepted", sliceSize)
+		}
+	}
+}
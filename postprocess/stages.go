@@ -0,0 +1,491 @@
+package postprocess
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/probe"
+)
+
+//Errors reported by the built-in stages.
+var (
+	ErrDamaged = errors.New("postprocess: par2 set is damaged")
+	ErrNotRepairable = errors.New("postprocess: not enough par2 recovery blocks")
+	ErrEncrypted = errors.New("postprocess: encrypted zip files are not supported")
+)
+
+func (Verify) Name() string { return "verify" }
+
+func (v Verify) Run(ctx context.Context, job *Job) error {
+	verifications, err := VerifyPar2(ctx, job.Directory)
+	if err != nil {
+		return err
+	}
+	job.Verification = verifications
+	if len(verifications) == 0 {
+		return ErrSkipped
+	}
+	if v.AllowDamaged {
+		return nil
+	}
+	return damage(verifications, ErrDamaged)
+}
+
+//Describes the first damaged set, wrapping kind.
+func damage(verifications []*Verification, kind error) error {
+	for _, v := range verifications {
+		if !v.OK() {
+			return fmt.Errorf("%w: %s needs %d slices, %d available", kind, filepath.Base(v.Par2File), v.BlocksNeeded, v.RecoveryBlocks)
+		}
+	}
+	return nil
+}
+
+func (Repair) Name() string { return "repair" }
+
+func (r Repair) Run(ctx context.Context, job *Job) error {
+	var damaged []*Verification
+	for _, v := range job.Verification {
+		if !v.OK() {
+			damaged = append(damaged, v)
+		}
+	}
+	if len(damaged) == 0 {
+		return ErrSkipped
+	}
+	for _, v := range damaged {
+		if !v.Repairable() {
+			return damage([]*Verification{v}, ErrNotRepairable)
+		}
+	}
+
+	command := r.Command
+	if len(command) == 0 {
+		command = []string{"par2", "repair", "-q"}
+	}
+	for _, v := range damaged {
+		if err := runTool(ctx, job.Directory, command, filepath.Base(v.Par2File)); err != nil {
+			return err
+		}
+	}
+
+	verifications, err := VerifyPar2(ctx, job.Directory)
+	if err != nil {
+		return err
+	}
+	job.Verification = verifications
+	if err := damage(verifications, ErrDamaged); err != nil {
+		return err
+	}
+
+	//par2cmdline keeps the damaged originals as name.1.
+	for _, v := range damaged {
+		for _, f := range v.Files {
+			if f.Found && !f.Intact {
+				os.Remove(filepath.Join(job.Directory, f.Name+".1"))
+			}
+		}
+	}
+	return nil
+}
+
+//Runs an external tool in dir, reporting its last line of output on failure.
+func runTool(ctx context.Context, dir string, command []string, args ...string) error {
+	cmd := exec.CommandContext(ctx, command[0], append(append([]string{}, command[1:]...), args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return fmt.Errorf("postprocess: %s: %w: %s", command[0], err, strings.TrimSpace(lines[len(lines)-1]))
+	}
+	return nil
+}
+
+func (Extract) Name() string { return "extract" }
+
+func (e Extract) Run(ctx context.Context, job *Job) error {
+	names, err := regularFiles(job.Directory)
+	if err != nil {
+		return err
+	}
+
+	var passwords []string
+	if job.Nzb != nil {
+		passwords = parser.Passwords(job.Nzb.Head.Meta)
+	}
+
+	extracted := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !probe.IsFirstVolume(name) {
+			continue
+		}
+
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasSuffix(lower, ".zip"):
+			err = extractZip(ctx, job.Directory, name)
+		case strings.HasSuffix(lower, ".rar"):
+			unrar := e.Unrar
+			if len(unrar) == 0 {
+				unrar = []string{"unrar", "x", "-o+", "-y"}
+			}
+			err = withPasswords(passwords, func(password string) error {
+				flag := "-p-"
+				if password != "" {
+					flag = "-p" + password
+				}
+				return runTool(ctx, job.Directory, unrar, flag, name, "."+string(filepath.Separator))
+			})
+		case strings.HasSuffix(lower, ".7z"), strings.HasSuffix(lower, ".7z.001"):
+			sevenZip := e.SevenZip
+			if len(sevenZip) == 0 {
+				sevenZip = []string{"7z", "x", "-y"}
+			}
+			err = withPasswords(passwords, func(password string) error {
+				return runTool(ctx, job.Directory, sevenZip, "-p"+password, "-o.", name)
+			})
+		case strings.HasSuffix(lower, ".001"):
+			err = joinSplit(job.Directory, name)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		job.Extracted = append(job.Extracted, parser.SetName(name))
+		extracted++
+	}
+
+	if extracted == 0 {
+		return ErrSkipped
+	}
+	return nil
+}
+
+//Tries each password in turn until extract succeeds, or no password at all if there are none. Returns the last failure.
+func withPasswords(passwords []string, extract func(password string) error) error {
+	if len(passwords) == 0 {
+		passwords = []string{""}
+	}
+	var err error
+	for _, p := range passwords {
+		if err = extract(p); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+//Extracts a zip archive into dir, refusing entries that would land outside it.
+func extractZip(ctx context.Context, dir string, name string) error {
+	archive, err := zip.OpenReader(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !filepath.IsLocal(f.Name) {
+			return fmt.Errorf("postprocess: zip entry %q escapes the job directory", f.Name)
+		}
+		target := filepath.Join(dir, f.Name)
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		//Bit 0 of the general purpose flags marks encrypted entries.
+		if f.Flags&0x1 != 0 {
+			return ErrEncrypted
+		}
+		if err := extractZipEntry(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Writes a single zip entry to target.
+func extractZipEntry(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	source, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, source); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+//Joins name.001, name.002, ... into name, stopping at the first missing piece. The joined file only appears once complete.
+func joinSplit(dir string, first string) error {
+	base := first[:len(first)-len(".001")]
+	target := filepath.Join(dir, base)
+	out, err := os.Create(target + ".joining")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	for i := 1; ; i++ {
+		piece, err := os.Open(filepath.Join(dir, fmt.Sprintf("%s.%03d", base, i)))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+		_, err = io.Copy(out, piece)
+		piece.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), target)
+}
+
+func (Deobfuscate) Name() string { return "deobfuscate" }
+
+func (Deobfuscate) Run(ctx context.Context, job *Job) error {
+	name := safeName(job.Name)
+	if name == "" {
+		return ErrSkipped
+	}
+	names, err := regularFiles(job.Directory)
+	if err != nil {
+		return err
+	}
+
+	type candidate struct {
+		name string
+		extension string
+		size int64
+	}
+	var candidates []candidate
+	for _, n := range names {
+		//Archive volumes and par2 files are left for Cleanup.
+		if parser.SetName(n) != n {
+			continue
+		}
+		stem, extension := parser.SplitFilename(n)
+		if !parser.IsObfuscated(stem) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(job.Directory, n))
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, candidate{name: n, extension: extension, size: info.Size()})
+	}
+	if len(candidates) == 0 {
+		return ErrSkipped
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].size > candidates[j].size
+	})
+
+	for i, c := range candidates {
+		stem := name
+		if i > 0 {
+			stem = fmt.Sprintf("%s.%d", name, i+1)
+		}
+		target := stem
+		if c.extension != "" {
+			target += "." + c.extension
+		}
+		target = uniquePath(filepath.Join(job.Directory, target))
+		if err := os.Rename(filepath.Join(job.Directory, c.name), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (Cleanup) Name() string { return "cleanup" }
+
+func (c Cleanup) Run(ctx context.Context, job *Job) error {
+	names, err := regularFiles(job.Directory)
+	if err != nil {
+		return err
+	}
+
+	extracted := map[string]bool{}
+	for _, set := range job.Extracted {
+		extracted[set] = true
+	}
+	//par2 files are kept while a set is still damaged, so repair can be retried later.
+	keepPar2 := damage(job.Verification, ErrDamaged) != nil
+
+	removed := 0
+	for _, name := range names {
+		extension := strings.ToLower(filepath.Ext(name))
+		set := parser.SetName(name)
+
+		remove := false
+		switch {
+		case extension == ".par2":
+			remove = !keepPar2
+		case set != name && extracted[set]:
+			remove = true
+		default:
+			for _, e := range c.Extensions {
+				if strings.EqualFold(extension, "."+strings.TrimPrefix(e, ".")) {
+					remove = true
+				}
+			}
+		}
+		if !remove {
+			continue
+		}
+		if err := os.Remove(filepath.Join(job.Directory, name)); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	if removed == 0 {
+		return ErrSkipped
+	}
+	return nil
+}
+
+func (Move) Name() string { return "move" }
+
+func (m Move) Run(ctx context.Context, job *Job) error {
+	root := m.Default
+	for category, dir := range m.Categories {
+		if strings.EqualFold(category, job.Category) {
+			root = dir
+			break
+		}
+	}
+	if root == "" {
+		return ErrSkipped
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	name := safeName(job.Name)
+	if name == "" {
+		name = filepath.Base(job.Directory)
+	}
+	target := uniquePath(filepath.Join(root, name))
+
+	err := os.Rename(job.Directory, target)
+	if errors.Is(err, syscall.EXDEV) {
+		//Renaming cannot cross filesystems, so the files are copied over instead.
+		if err = copyTree(job.Directory, target); err == nil {
+			err = os.RemoveAll(job.Directory)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	job.Directory = target
+	return nil
+}
+
+//Copies a directory tree, preserving file modes.
+func copyTree(source string, target string) error {
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(source, path)
+		destination := filepath.Join(target, relative)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(destination, info.Mode().Perm())
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+//Lists the regular, non-hidden files directly in dir, sorted by name.
+func regularFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+//Makes a job name usable as a single path element.
+func safeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return ' '
+		}
+		return r
+	}, name)
+	return strings.Trim(strings.TrimSpace(name), ".")
+}
+
+//Returns path, or path with a numeric suffix if it already exists.
+func uniquePath(path string) string {
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s.%d", path, i)
+	}
+}
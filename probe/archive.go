@@ -0,0 +1,104 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/jgr0sz/nzbgo/internal/rar"
+)
+
+//Leading bytes of the supported container types.
+var (
+	sevenZipMagic = []byte("7z\xbc\xaf\x27\x1c")
+	zipMagic = []byte("PK\x03\x04")
+	par2Magic = []byte("PAR2\x00PKT")
+	ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+	//Coder ID of 7-Zip's AES-256 + SHA-256 method.
+	sevenZipAES = []byte{0x06, 0xf1, 0x07, 0x01}
+)
+
+//Identifies a container from its leading bytes.
+//Matroska is reported as mkv whatever its doctype, since webm is a subset of it.
+func DetectType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, rar.Magic5):
+		return TypeRar5
+	case bytes.HasPrefix(data, rar.Magic4):
+		return TypeRar4
+	case bytes.HasPrefix(data, sevenZipMagic):
+		return Type7z
+	case bytes.HasPrefix(data, zipMagic):
+		return TypeZip
+	case bytes.HasPrefix(data, par2Magic):
+		return TypePar2
+	case bytes.HasPrefix(data, ebmlMagic):
+		return TypeMkv
+	//ISO base media files open with a box size followed by "ftyp".
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return TypeMp4
+	}
+	return TypeUnknown
+}
+
+//Returns the file extension, without a dot, conventionally used for a container type.
+func Extension(fileType string) string {
+	switch fileType {
+	case TypeRar4, TypeRar5:
+		return "rar"
+	}
+	return fileType
+}
+
+//Reads a rar volume's headers up to the first file header, which settles whether the data or the headers are encrypted.
+func inspectRar(data []byte) archiveInfo {
+	header, err := rar.FirstFile(data)
+	if err != nil {
+		return archiveInfo{}
+	}
+	return archiveInfo{encrypted: header.Encrypted, headersEncrypted: header.HeadersEncrypted, conclusive: true}
+}
+
+//Reads the general purpose flags of a zip's first local file header; bit 0 marks encryption.
+func inspectZip(data []byte) archiveInfo {
+	if len(data) < 8 {
+		return archiveInfo{}
+	}
+	return archiveInfo{encrypted: binary.LittleEndian.Uint16(data[6:])&0x0001 != 0, conclusive: true}
+}
+
+//Reads the location of a 7z archive's header from its start header, as an offset from the start of the archive.
+func sevenZipHeaderRange(data []byte) (int64, int64, bool) {
+	//Signature(6) Version(2) StartHeaderCRC(4) NextHeaderOffset(8) NextHeaderSize(8) NextHeaderCRC(4)
+	if len(data) < 32 {
+		return 0, 0, false
+	}
+	offset := int64(binary.LittleEndian.Uint64(data[12:]))
+	size := int64(binary.LittleEndian.Uint64(data[20:]))
+	//Values past the int64 range come out negative; ones that would overflow once added up are just as bogus.
+	if offset < 0 || size <= 0 || offset > math.MaxInt64-32-size {
+		return 0, 0, false
+	}
+	return 32 + offset, size, true
+}
+
+//Inspects a 7z header. An encoded (packed) header using AES means the file list itself is encrypted; a plain header listing
+//the AES coder means only the data is. A header packed without AES hides the data's coders, so it is inconclusive.
+func inspect7zHeader(header []byte) archiveInfo {
+	const (
+		plainHeader = 0x01
+		encodedHeader = 0x17
+	)
+	if len(header) == 0 {
+		return archiveInfo{}
+	}
+
+	aes := bytes.Contains(header, sevenZipAES)
+	switch header[0] {
+	case encodedHeader:
+		return archiveInfo{encrypted: aes, headersEncrypted: aes, conclusive: aes}
+	case plainHeader:
+		return archiveInfo{encrypted: aes, conclusive: true}
+	}
+	return archiveInfo{}
+}
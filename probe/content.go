@@ -0,0 +1,88 @@
+package probe
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Decides the name a probed file should go by. A yEnc name that does not look obfuscated wins; otherwise the detected type's
+//extension is added to the subject name if it is missing.
+func recoverName(name string, yencName string, fileType string) string {
+	if yencName != "" {
+		stem, _ := parser.SplitFilename(yencName)
+		if !parser.IsObfuscated(stem) {
+			return yencName
+		}
+	}
+	if name == "" {
+		name = yencName
+	}
+
+	ext := Extension(fileType)
+	if ext == "" || strings.HasSuffix(strings.ToLower(name), "."+ext) {
+		return name
+	}
+	return name + "." + ext
+}
+
+//Rewrites a subject to carry a new filename, replacing the quoted name if there is one and building a standard subject otherwise.
+func renameSubject(file parser.File, idx int, total int, name string, size int64) string {
+	if start := strings.Index(file.Subject, `"`); start >= 0 {
+		if end := strings.Index(file.Subject[start+1:], `"`); end >= 0 {
+			return file.Subject[:start+1] + name + file.Subject[start+1+end:]
+		}
+	}
+	return yenc.Subject(name, idx+1, total, 1, len(file.Segments), size)
+}
+
+//Fetches the first segment of each file whose subject filename is obfuscated (or of every file, with options.All) and reports
+//the name and size its =ybegin line declares alongside the container type its magic bytes reveal. The returned Nzb is a copy
+//whose subjects carry the recovered names, so parser.ExtractFilename and downstream classifiers see them. The error is only set
+//if ctx was cancelled; fetch failures are recorded per file.
+func ProbeContent(ctx context.Context, nzb *parser.Nzb, fetcher nntp.Fetcher, options ContentOptions) ([]FileResult, *parser.Nzb, error) {
+	updated := &parser.Nzb{
+		Head: parser.Head{Meta: append([]parser.Meta{}, nzb.Head.Meta...)},
+		Files: append([]parser.File{}, nzb.Files...),
+	}
+
+	var results []FileResult
+	for i, f := range nzb.Files {
+		name := parser.ExtractFilename(f)
+		stem, _ := parser.SplitFilename(name)
+		result := FileResult{File: i, Name: name, Obfuscated: parser.IsObfuscated(stem)}
+		if !result.Obfuscated && !options.All {
+			continue
+		}
+
+		segments := sortedSegments(f)
+		if len(segments) == 0 {
+			result.Err = ErrNoSegments
+			results = append(results, result)
+			continue
+		}
+
+		part, err := fetchPart(ctx, fetcher, segments[0])
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+
+		result.YencName = part.Name
+		result.YencSize = part.FileSize
+		result.Type = DetectType(part.Data)
+		result.RecoveredName = recoverName(name, part.Name, result.Type)
+		if result.RecoveredName != name && result.RecoveredName != "" {
+			updated.Files[i].Subject = renameSubject(f, i, len(nzb.Files), result.RecoveredName, part.FileSize)
+		}
+		results = append(results, result)
+	}
+	return results, updated, nil
+}
@@ -0,0 +1,134 @@
+// Allows for probing the contents of an NZB by fetching only a few of its segments, such as whether its archives are encrypted.
+package probe
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Errors recorded in SetResult.Err.
+var (
+	ErrNoSegments = errors.New("probe: file has no segments")
+	ErrNotArchive = errors.New("probe: first volume is not a recognised archive")
+)
+
+//Determines, for every archive set of an NZB, whether its archives are encrypted. Only the first segment of each set's first
+//volume is fetched, plus for 7z the segment holding the archive's header. Sets without an archive (a lone video and its par2
+//files, say) are left out. The returned error is only set if ctx was cancelled; fetch failures are recorded per set.
+func ProbeEncryption(ctx context.Context, nzb *parser.Nzb, fetcher nntp.Fetcher) ([]SetResult, error) {
+	passwordKnown := len(parser.Passwords(nzb.Head.Meta)) > 0
+	p := &prober{nzb: nzb, fetcher: fetcher}
+
+	//Grouping the files of each set, keeping the NZB's order of sets.
+	var setNames []string
+	sets := map[string][]int{}
+	for i, f := range nzb.Files {
+		name := parser.SetName(parser.ExtractFilename(f))
+		if _, ok := sets[name]; !ok {
+			setNames = append(setNames, name)
+		}
+		sets[name] = append(sets[name], i)
+	}
+
+	var results []SetResult
+	for _, set := range setNames {
+		first := -1
+		for _, idx := range sets[set] {
+			if IsFirstVolume(parser.ExtractFilename(nzb.Files[idx])) {
+				first = idx
+				break
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		result := SetResult{Set: set, File: first, Name: parser.ExtractFilename(nzb.Files[first])}
+		info, err := p.probeArchive(ctx, sets[set], first, &result)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.Err = err
+
+		switch {
+		case !info.conclusive:
+			result.Encryption = EncryptionUnknown
+		case !info.encrypted:
+			result.Encryption = NotEncrypted
+		case passwordKnown:
+			result.Encryption = EncryptedPasswordKnown
+		default:
+			result.Encryption = EncryptedPasswordUnknown
+		}
+		result.HeadersEncrypted = info.headersEncrypted
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+//Fetches the start of a set's first volume and inspects its headers according to the detected container type.
+func (p *prober) probeArchive(ctx context.Context, set []int, first int, result *SetResult) (archiveInfo, error) {
+	file := p.nzb.Files[first]
+	segments := sortedSegments(file)
+	if len(segments) == 0 {
+		return archiveInfo{}, ErrNoSegments
+	}
+
+	part, err := fetchPart(ctx, p.fetcher, segments[0])
+	if err != nil {
+		return archiveInfo{}, err
+	}
+	result.Type = DetectType(part.Data)
+
+	switch result.Type {
+	case TypeRar4, TypeRar5:
+		return inspectRar(part.Data), nil
+	case TypeZip:
+		return inspectZip(part.Data), nil
+	case Type7z:
+		return p.probe7z(ctx, set, first, part.Data, part.End-part.Begin+1, part.FileSize)
+	}
+	return archiveInfo{}, ErrNotArchive
+}
+
+//Locates and inspects a 7z archive's header, which sits at the end of the archive. For split archives (.7z.001, .7z.002, ...)
+//volumes are assumed to share the first volume's size.
+func (p *prober) probe7z(ctx context.Context, set []int, first int, start []byte, partSize int64, volumeSize int64) (archiveInfo, error) {
+	offset, size, ok := sevenZipHeaderRange(start)
+	if !ok {
+		return archiveInfo{}, nil
+	}
+
+	volumes := []int{first}
+	if sevenZipVolumePattern.MatchString(parser.ExtractFilename(p.nzb.Files[first])) {
+		volumes = nil
+		for _, idx := range set {
+			if sevenZipVolumePattern.MatchString(parser.ExtractFilename(p.nzb.Files[idx])) {
+				volumes = append(volumes, idx)
+			}
+		}
+		sort.Slice(volumes, func(a, b int) bool {
+			return parser.ExtractFilename(p.nzb.Files[volumes[a]]) < parser.ExtractFilename(p.nzb.Files[volumes[b]])
+		})
+	}
+
+	volume := 0
+	if volumeSize > 0 {
+		volume = int(offset / volumeSize)
+		offset -= int64(volume) * volumeSize
+	}
+	//Headers spanning two volumes are not worth the extra fetches.
+	if offset < 0 || volume < 0 || volume >= len(volumes) || (volumeSize > 0 && size > volumeSize-offset) {
+		return archiveInfo{}, nil
+	}
+
+	header, err := readRange(ctx, p.fetcher, p.nzb.Files[volumes[volume]], partSize, offset, size)
+	if err != nil {
+		return archiveInfo{}, err
+	}
+	return inspect7zHeader(header), nil
+}
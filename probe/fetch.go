@@ -0,0 +1,86 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Precompiled regexes to recognise the first volume of an archive set.
+var (
+	rarPartPattern = regexp.MustCompile(`(?i)\.part(\d+)\.rar$`)
+	firstVolumePattern = regexp.MustCompile(`(?i)(\.rar|\.7z|\.7z\.0*1|\.zip|\.0*1)$`)
+	sevenZipVolumePattern = regexp.MustCompile(`(?i)\.7z\.\d+$`)
+)
+
+//Checks whether a filename is the first volume of an archive: name.part01.rar, name.rar, name.7z, name.7z.001, name.zip or name.001.
+func IsFirstVolume(filename string) bool {
+	if match := rarPartPattern.FindStringSubmatch(filename); match != nil {
+		number, _ := strconv.Atoi(match[1])
+		return number == 1
+	}
+	return firstVolumePattern.MatchString(filename)
+}
+
+//Returns a file's segments ordered by number.
+func sortedSegments(file parser.File) []parser.Segment {
+	segments := append([]parser.Segment{}, file.Segments...)
+	sort.Slice(segments, func(a, b int) bool {
+		return segments[a].Number < segments[b].Number
+	})
+	return segments
+}
+
+//Fetches and decodes a single segment. Data failing its CRC check is still returned, as probing only needs a best effort.
+func fetchPart(ctx context.Context, fetcher nntp.Fetcher, segment parser.Segment) (*yenc.Part, error) {
+	body, err := fetcher.Fetch(ctx, segment.ID)
+	if err != nil {
+		return nil, err
+	}
+	part, err := yenc.Decode(body)
+	if part != nil && (err == nil || errors.Is(err, yenc.ErrCRCMismatch)) {
+		return part, nil
+	}
+	return nil, err
+}
+
+//Reads the bytes [from, from+size) of a file, fetching only the segments covering them. Parts are assumed to be partSize bytes
+//each, as posting tools make every part but the last the same size.
+func readRange(ctx context.Context, fetcher nntp.Fetcher, file parser.File, partSize int64, from int64, size int64) ([]byte, error) {
+	//Checked before dividing: a first part declaring no bytes gives a zero partSize.
+	if partSize <= 0 || from < 0 || size <= 0 || from > math.MaxInt64-size {
+		return nil, errors.New("probe: range lies outside the file's segments")
+	}
+	segments := sortedSegments(file)
+	first := int(from / partSize)
+	last := int((from + size - 1) / partSize)
+	if last >= len(segments) {
+		return nil, errors.New("probe: range lies outside the file's segments")
+	}
+
+	var data []byte
+	start := int64(0)
+	for i := first; i <= last; i++ {
+		part, err := fetchPart(ctx, fetcher, segments[i])
+		if err != nil {
+			return nil, err
+		}
+		if i == first {
+			start = part.Begin - 1
+		}
+		data = append(data, part.Data...)
+	}
+
+	from -= start
+	if from < 0 || from+size > int64(len(data)) {
+		return nil, errors.New("probe: fetched segments do not cover the requested range")
+	}
+	return data[from : from+size], nil
+}
@@ -0,0 +1,91 @@
+package probe
+
+import (
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Container types recognised from a file's leading bytes.
+const (
+	TypeUnknown = ""
+	TypeRar4 = "rar4"
+	TypeRar5 = "rar5"
+	Type7z = "7z"
+	TypeZip = "zip"
+	TypePar2 = "par2"
+	TypeMkv = "mkv"
+	TypeMp4 = "mp4"
+)
+
+//Encryption verdict for an archive set.
+type Encryption int
+
+const (
+	//The probe could not tell, for example because the set is not an archive or its headers could not be read.
+	EncryptionUnknown Encryption = iota
+	NotEncrypted
+	//Encrypted, and the NZB declares at least one password through its meta.
+	EncryptedPasswordKnown
+	//Encrypted, with no password in the NZB.
+	EncryptedPasswordUnknown
+)
+
+//Returns a short description of an encryption verdict.
+func (e Encryption) String() string {
+	switch e {
+	case NotEncrypted:
+		return "not encrypted"
+	case EncryptedPasswordKnown:
+		return "encrypted, password known"
+	case EncryptedPasswordUnknown:
+		return "encrypted, password unknown"
+	default:
+		return "unknown"
+	}
+}
+
+//Outcome of probing one archive set. File is the index of the set's first volume within Nzb.Files.
+//HeadersEncrypted means even the file list is encrypted, which implies encrypted data.
+type SetResult struct {
+	Set string
+	File int
+	Name string
+	Type string
+	Encryption Encryption
+	HeadersEncrypted bool
+	Err error
+}
+
+//Settings for a content probe.
+type ContentOptions struct {
+	//Probes every file, not only those whose subject filename looks obfuscated.
+	All bool
+}
+
+//Outcome of probing one file. Name is the filename from the subject; YencName and YencSize are what the =ybegin line declares.
+//RecoveredName is the name the file should go by: the yEnc name when it is meaningful, otherwise the subject name with the
+//extension of the detected type.
+type FileResult struct {
+	File int
+	Name string
+	Obfuscated bool
+	YencName string
+	YencSize int64
+	Type string
+	RecoveredName string
+	Err error
+}
+
+//What the archive headers revealed, before passwords are taken into account.
+type archiveInfo struct {
+	encrypted bool
+	headersEncrypted bool
+	//Whether the headers were read far enough to be sure.
+	conclusive bool
+}
+
+//State shared by the steps of a single probe.
+type prober struct {
+	nzb *parser.Nzb
+	fetcher nntp.Fetcher
+}
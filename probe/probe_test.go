@@ -0,0 +1,173 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/internal/rar"
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func rar4(encrypted bool) []byte {
+	data := append([]byte{}, rar.Magic4...)
+	//Main header: CRC, type, flags, size and six reserved bytes.
+	data = append(data, 0, 0, 0x73, 0, 0, 13, 0, 0, 0, 0, 0, 0, 0)
+	flags := uint16(0x8000)
+	if encrypted {
+		flags |= 0x0004
+	}
+	data = append(data, 0, 0, 0x74)
+	data = binary.LittleEndian.AppendUint16(data, flags)
+	data = append(data, 32, 0, 0, 0, 0, 0)
+	return append(data, make([]byte, 2000)...)
+}
+
+func rar5Encrypted() []byte {
+	data := append([]byte{}, rar.Magic5...)
+	data = append(data, 0, 0, 0, 0, 3, 1, 0, 0)
+	header := []byte{2, 3, 5, 0, 0, 0, 0, 0, 0, 1, 'a', 4, 1, 0, 0, 0}
+	data = append(data, 0, 0, 0, 0, byte(len(header)))
+	data = append(data, header...)
+	return append(data, make([]byte, 2000)...)
+}
+
+func zipEncrypted() []byte {
+	return append(append([]byte{}, zipMagic...), 20, 0, 1, 0, 0, 0, 0, 0)
+}
+
+func sevenZipEncrypted() []byte {
+	const headerOffset = 5000
+	header := []byte{0x01, 0x04, 0x06, 0x01, 0x24, 0x06, 0xf1, 0x07, 0x01, 0x00}
+	data := append([]byte{}, sevenZipMagic...)
+	data = append(data, 0, 4, 0, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint64(data, headerOffset)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(header)))
+	data = append(data, 0, 0, 0, 0)
+	data = append(data, bytes.Repeat([]byte{0x55}, headerOffset)...)
+	return append(data, header...)
+}
+
+func TestProbeEncryption(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	nzb := &parser.Nzb{}
+	server.AddNzbFile(nzb, "plain.part01.rar", rar4(false), 1000)
+	server.AddNzbFile(nzb, "plain.part02.rar", rar4(false), 1000)
+	server.AddNzbFile(nzb, "old.rar", rar4(true), 1000)
+	server.AddNzbFile(nzb, "new.part1.rar", rar5Encrypted(), 1000)
+	server.AddNzbFile(nzb, "docs.zip", zipEncrypted(), 1000)
+	server.AddNzbFile(nzb, "backup.7z", sevenZipEncrypted(), 1000)
+	server.AddNzbFile(nzb, "video.mkv", make([]byte, 3000), 1000)
+	server.AddNzbFile(nzb, "video.mkv.par2", make([]byte, 100), 1000)
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+	results, err := ProbeEncryption(context.Background(), nzb, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Encryption{
+		"plain": NotEncrypted,
+		"old": EncryptedPasswordUnknown,
+		"new": EncryptedPasswordUnknown,
+		"docs": EncryptedPasswordUnknown,
+		"backup": EncryptedPasswordUnknown,
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d archive sets, got %+v", len(expected), results)
+	}
+	for _, r := range results {
+		if r.Encryption != expected[r.Set] || r.Err != nil {
+			t.Fatalf("set %q: got %v (%v), expected %v", r.Set, r.Encryption, r.Err, expected[r.Set])
+		}
+	}
+	//One segment per set, plus the 7z header's segment.
+	if server.CommandCount("BODY") != len(expected)+1 {
+		t.Fatalf("fetched %d bodies", server.CommandCount("BODY"))
+	}
+
+	nzb.Head.Meta = append(nzb.Head.Meta, parser.Meta{Type: "password", Value: "secret"})
+	results, _ = ProbeEncryption(context.Background(), nzb, client)
+	if results[1].Encryption != EncryptedPasswordKnown {
+		t.Fatalf("expected the password from meta to be recognised, got %v", results[1].Encryption)
+	}
+}
+
+func TestProbeContent(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	nzb := &parser.Nzb{}
+	hexName := "0123456789abcdef0123456789abcdef"
+	server.AddNzbFile(nzb, hexName, append([]byte{0x1a, 0x45, 0xdf, 0xa3}, make([]byte, 3000)...), 1000)
+	server.AddNzbFile(nzb, "abc.xyz.a8f3b2", nil, 1000)
+	server.AddNzbFile(nzb, "Gattaca.mkv", append([]byte{0x1a, 0x45, 0xdf, 0xa3}, make([]byte, 10)...), 1000)
+
+	//The second file's article declares its real name in =ybegin.
+	data := append([]byte{0, 0, 0, 0x20}, []byte("ftypisom")...)
+	body := yenc.EncodePart(yenc.SplitParts("Spiderman 2021.mp4", data, 0)[0], 0)
+	server.AddArticle(nzb.Files[1].Segments[0].ID, nil, body)
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	results, updated, err := ProbeContent(context.Background(), nzb, client, ContentOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected only the obfuscated files to be probed, got %+v", results)
+	}
+	if results[0].Type != TypeMkv || results[0].RecoveredName != hexName+".mkv" {
+		t.Fatalf("unexpected result for the hex-named file: %+v", results[0])
+	}
+	if results[1].Type != TypeMp4 || results[1].YencName != "Spiderman 2021.mp4" || results[1].RecoveredName != "Spiderman 2021.mp4" {
+		t.Fatalf("unexpected result for the yEnc-named file: %+v", results[1])
+	}
+
+	if name := parser.ExtractFilename(updated.Files[1]); name != "Spiderman 2021.mp4" {
+		t.Fatalf("updated subject yields %q", name)
+	}
+	if parser.ExtractFilename(nzb.Files[1]) != "abc.xyz.a8f3b2" {
+		t.Fatal("the original Nzb was modified")
+	}
+
+	results, _, _ = ProbeContent(context.Background(), nzb, client, ContentOptions{All: true})
+	if len(results) != 3 || results[2].RecoveredName != "Gattaca.mkv" {
+		t.Fatalf("unexpected results when probing every file: %+v", results)
+	}
+}
+
+func TestMalformedHeaders(t *testing.T) {
+	//A 7z NextHeaderOffset that is negative as an int64.
+	data := append(append([]byte{}, sevenZipMagic...), 0, 4, 0, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint64(data, 1<<63+12345)
+	data = binary.LittleEndian.AppendUint64(data, 10)
+	if _, _, ok := sevenZipHeaderRange(append(data, 0, 0, 0, 0)); ok {
+		t.Fatal("negative 7z header offset was accepted")
+	}
+
+	//Parts declaring no bytes.
+	if _, err := readRange(context.Background(), nil, parser.File{Segments: []parser.Segment{{Number: 1}}}, 0, 10, 5); err == nil {
+		t.Fatal("zero part size was accepted")
+	}
+}
@@ -0,0 +1,18 @@
+package release
+
+//Fields recovered from a scene-style release name such as "Show.Name.S01E02.1080p.WEB-DL.x264-GROUP". Fields that could not be
+//found are empty, or zero for numbers.
+type Info struct {
+	//Name of the show or movie, with separators turned into spaces.
+	Title string
+	Year int
+	Season int
+	Episode int
+	//Such as "720p", "1080p" or "2160p".
+	Resolution string
+	//Such as "BluRay", "WEB-DL" or "HDTV".
+	Source string
+	//Such as "x264", "HEVC".
+	Codec string
+	Group string
+}
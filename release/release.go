@@ -0,0 +1,106 @@
+// Allows for recovering show/movie names, episode numbers, resolution, source, codec and group from scene-style release names.
+package release
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Compiled regexes for the parts of a release name.
+var (
+	EPISODE_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-])(?:s(\d{1,2})[ ._\-]?e(\d{1,3})|s(\d{1,2})(?:[ ._\-]|$)|(\d{1,2})x(\d{2,3}))`)
+	YEAR_PATTERN = *regexp.MustCompile(`(?:^|[ ._\-(\[])((?:19|20)\d{2})(?:[ ._\-)\]]|$)`)
+	RESOLUTION_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-\[])(480p|576p|720p|1080[pi]|2160p|4k)(?:[ ._\-\]]|$)`)
+	SOURCE_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-])(blu-?ray|bdrip|brrip|web-?dl|web-?rip|web|hdtv|pdtv|dvdrip|dvd|remux)(?:[ ._\-]|$)`)
+	CODEC_PATTERN = *regexp.MustCompile(`(?i)(?:^|[ ._\-])(x26[45]|h[ .]?26[45]|hevc|avc|xvid|divx|av1)(?:[ ._\-]|$)`)
+	GROUP_PATTERN = *regexp.MustCompile(`-([A-Za-z0-9]+)(?:\[[^\]]*\])?$`)
+)
+
+//Recovers what it can from a release name. Only the part before the first recognized field is taken as the title.
+func Parse(name string) Info {
+	name = strings.TrimSpace(name)
+	var info Info
+	titleEnd := len(name)
+	cut := func(index int) {
+		if index >= 0 && index < titleEnd {
+			titleEnd = index
+		}
+	}
+
+	if m := EPISODE_PATTERN.FindStringSubmatchIndex(name); m != nil {
+		switch {
+		case m[2] >= 0:
+			info.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+			info.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+		case m[6] >= 0:
+			info.Season, _ = strconv.Atoi(name[m[6]:m[7]])
+		default:
+			info.Season, _ = strconv.Atoi(name[m[8]:m[9]])
+			info.Episode, _ = strconv.Atoi(name[m[10]:m[11]])
+		}
+		cut(m[0])
+	}
+	//A leading year is part of titles like "2001 A Space Odyssey", so the last year found is used.
+	if years := YEAR_PATTERN.FindAllStringSubmatchIndex(name, -1); years != nil {
+		m := years[len(years)-1]
+		if m[2] > 0 {
+			info.Year, _ = strconv.Atoi(name[m[2]:m[3]])
+			cut(m[0])
+		}
+	}
+	if m := RESOLUTION_PATTERN.FindStringSubmatchIndex(name); m != nil {
+		info.Resolution = strings.ToLower(name[m[2]:m[3]])
+		cut(m[0])
+	}
+	if m := SOURCE_PATTERN.FindStringSubmatchIndex(name); m != nil {
+		info.Source = name[m[2]:m[3]]
+		cut(m[0])
+	}
+	if m := CODEC_PATTERN.FindStringSubmatchIndex(name); m != nil {
+		info.Codec = name[m[2]:m[3]]
+		cut(m[0])
+	}
+	if m := GROUP_PATTERN.FindStringSubmatchIndex(name); m != nil && m[0] > 0 {
+		//"WEB-DL" and the like end in a hyphenated source rather than a group.
+		if !SOURCE_PATTERN.MatchString(name[max(m[0]-4, 0):]) {
+			info.Group = name[m[2]:m[3]]
+			cut(m[0])
+		}
+	}
+
+	info.Title = strings.Join(strings.FieldsFunc(name[:titleEnd], func(r rune) bool {
+		return r == '.' || r == '_' || r == ' ' || r == '-' || r == '(' || r == '[' || r == ')' || r == ']'
+	}), " ")
+	return info
+}
+
+//Returns a field by name, as used in rules: "title", "year", "season", "episode", "resolution", "source", "codec" or "group".
+//Numbers are returned in decimal, and missing fields as "".
+func (i Info) Field(name string) string {
+	number := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	switch strings.ToLower(name) {
+	case "title":
+		return i.Title
+	case "year":
+		return number(i.Year)
+	case "season":
+		return number(i.Season)
+	case "episode":
+		return number(i.Episode)
+	case "resolution":
+		return i.Resolution
+	case "source":
+		return i.Source
+	case "codec":
+		return i.Codec
+	case "group":
+		return i.Group
+	}
+	return ""
+}
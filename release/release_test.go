@@ -0,0 +1,24 @@
+package release
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := map[string]Info{
+		"Show.Name.S01E02.1080p.WEB-DL.x264-GROUP": {Title: "Show Name", Season: 1, Episode: 2, Resolution: "1080p", Source: "WEB-DL", Codec: "x264", Group: "GROUP"},
+		"The.Matrix.1999.2160p.BluRay.HEVC-OTHER": {Title: "The Matrix", Year: 1999, Resolution: "2160p", Source: "BluRay", Codec: "HEVC", Group: "OTHER"},
+		"2001.A.Space.Odyssey.1968.720p.BluRay": {Title: "2001 A Space Odyssey", Year: 1968, Resolution: "720p", Source: "BluRay"},
+		"Show Name 3x07 HDTV": {Title: "Show Name", Season: 3, Episode: 7, Source: "HDTV"},
+		"Show.Name.S02.Complete.WEB-DL": {Title: "Show Name", Season: 2, Source: "WEB-DL"},
+		"plain name": {Title: "plain name"},
+	}
+	for name, want := range cases {
+		if got := Parse(name); got != want {
+			t.Errorf("%s: got %+v, expected %+v", name, got, want)
+		}
+	}
+
+	info := Parse("Show.Name.S01E02.1080p.WEB-DL.x264-GROUP")
+	if info.Field("episode") != "2" || info.Field("Resolution") != "1080p" || info.Field("year") != "" {
+		t.Fatal("unexpected field lookups")
+	}
+}
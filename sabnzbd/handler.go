@@ -0,0 +1,373 @@
+// Allows for exposing a download engine through the SABnzbd HTTP API, so tools such as Sonarr and Radarr can use it as a download
+// client.
+package sabnzbd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/metaeditor"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Largest NZB accepted by addfile, both as uploaded and once decompressed.
+const maxUpload = 64 << 20
+
+//Creates a handler serving the API on top of a backend.
+func New(backend Backend, options Options) *Handler {
+	if options.Version == "" {
+		options.Version = "4.3.0"
+	}
+	if options.HTTP == nil {
+		options.HTTP = http.DefaultClient
+	}
+	return &Handler{backend: backend, options: options}
+}
+
+//Writes a JSON response.
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+//Writes an error the way SABnzbd does: a 200 response with status false.
+func writeError(w http.ResponseWriter, message string) {
+	writeJSON(w, map[string]any{"status": false, "error": message})
+}
+
+//Compares an API key in constant time, so response timing does not reveal how much of it was guessed right.
+func keyMatches(key string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1
+}
+
+//Serves the API. The mode parameter selects the call, from either the query string or a form body.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.ParseMultipartForm(maxUpload)
+	}
+	mode := r.FormValue("mode")
+
+	if mode != "version" && h.options.APIKey != "" {
+		key := r.FormValue("apikey")
+		adding := mode == "addfile" || mode == "addurl"
+		switch {
+		case key == "":
+			writeError(w, "API Key Required")
+			return
+		case !keyMatches(key, h.options.APIKey) && !(adding && h.options.NZBKey != "" && keyMatches(key, h.options.NZBKey)):
+			writeError(w, "API Key Incorrect")
+			return
+		}
+	}
+
+	switch mode {
+	case "version":
+		writeJSON(w, map[string]string{"version": h.options.Version})
+	case "get_cats":
+		writeJSON(w, map[string][]string{"categories": append([]string{"*"}, h.backend.Categories()...)})
+	case "addfile":
+		h.addFile(w, r)
+	case "addurl":
+		h.addURL(w, r)
+	case "queue":
+		h.queue(w, r)
+	case "history":
+		h.history(w, r)
+	case "pause", "resume":
+		h.pauseResume(w, r, mode, "")
+	case "":
+		writeError(w, "Missing mode")
+	default:
+		writeError(w, "not implemented")
+	}
+}
+
+func (h *Handler) addFile(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("name")
+	if err != nil {
+		file, header, err = r.FormFile("nzbfile")
+	}
+	if err != nil {
+		writeError(w, "expects one parameter")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUpload))
+	if err == nil {
+		data, err = parser.Decompress(data, maxUpload)
+	}
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	nzb, err := parser.FromStr(string(data))
+	if err != nil {
+		writeError(w, "Invalid NZB: "+err.Error())
+		return
+	}
+	h.add(w, r, nzb, parser.StripNzbExtension(header.Filename))
+}
+
+func (h *Handler) addURL(w http.ResponseWriter, r *http.Request) {
+	url := r.FormValue("name")
+	if url == "" {
+		writeError(w, "expects one parameter")
+		return
+	}
+	nzb, name, err := parser.FromURLContext(r.Context(), h.options.HTTP, url)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	h.add(w, r, nzb, name)
+}
+
+//Applies the request's nzbname, cat and password to the NZB's meta and hands the job to the backend.
+func (h *Handler) add(w http.ResponseWriter, r *http.Request, nzb *parser.Nzb, suggested string) {
+	job := &Job{
+		Nzb: nzb,
+		Name: strings.TrimSpace(r.FormValue("nzbname")),
+		Category: strings.TrimSpace(r.FormValue("cat")),
+		Priority: formInt(r, "priority", PriorityDefault),
+		PostProcessing: formInt(r, "pp", -1),
+		Script: r.FormValue("script"),
+		Password: r.FormValue("password"),
+	}
+	if job.Name == "" {
+		job.Name = suggested
+	}
+	//"*" and "Default" both select the default category.
+	if job.Category == "*" || strings.EqualFold(job.Category, "default") {
+		job.Category = ""
+	}
+
+	//SABnzbd also accepts passwords appended to the name as "name{{password}}".
+	if start := strings.LastIndex(job.Name, "{{"); start >= 0 && strings.HasSuffix(job.Name, "}}") {
+		if job.Password == "" {
+			job.Password = job.Name[start+2 : len(job.Name)-2]
+		}
+		job.Name = strings.TrimSpace(job.Name[:start])
+	}
+
+	editor := &metaeditor.NzbMetaEditor{Metadata: nzb.Head.Meta, Nzb: nzb}
+	metaeditor.Put(editor, "title", job.Name)
+	metaeditor.Put(editor, "category", job.Category)
+	if job.Password != "" && !metaeditor.Contains(editor, "password", job.Password) {
+		metaeditor.Append(editor, "password", job.Password)
+	}
+	nzb.Head.Meta = editor.Metadata
+	if job.Name == "" {
+		job.Name = parser.Title(nzb.Head.Meta)
+	}
+	if job.Category == "" {
+		job.Category = parser.Category(nzb.Head.Meta)
+	}
+
+	id, err := h.backend.Add(r.Context(), job)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"status": true, "nzo_ids": []string{id}})
+}
+
+//Reads an integer form value, returning fallback if it is missing or malformed.
+func formInt(r *http.Request, name string, fallback int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(r.FormValue(name)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+//Splits the comma-separated IDs of a value parameter.
+func ids(value string) []string {
+	var found []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			found = append(found, id)
+		}
+	}
+	return found
+}
+
+//Names a priority the way SABnzbd lists it in the queue.
+func priorityName(priority int) string {
+	switch priority {
+	case PriorityForce:
+		return "Force"
+	case PriorityHigh:
+		return "High"
+	case PriorityLow:
+		return "Low"
+	case PriorityPaused:
+		return "Paused"
+	}
+	return "Normal"
+}
+
+//Formats a byte count in megabytes, as SABnzbd reports sizes.
+func megabytes(bytes int64) string {
+	return fmt.Sprintf("%.2f", float64(bytes)/(1<<20))
+}
+
+//Formats a duration as H:MM:SS.
+func clock(d time.Duration) string {
+	seconds := int64(d.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func (h *Handler) queue(w http.ResponseWriter, r *http.Request) {
+	switch r.FormValue("name") {
+	case "delete":
+		h.delete(w, r, false)
+		return
+	case "pause", "resume":
+		h.pauseResume(w, r, r.FormValue("name"), r.FormValue("value"))
+		return
+	}
+
+	status, err := h.backend.Queue(r.Context())
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+
+	var total, remaining int64
+	slots := make([]map[string]any, 0, len(status.Items))
+	for i, item := range status.Items {
+		total += item.Size
+		remaining += item.Remaining
+		percentage := 0
+		if item.Size > 0 {
+			percentage = int((item.Size - item.Remaining) * 100 / item.Size)
+		}
+		category := item.Category
+		if category == "" {
+			category = "*"
+		}
+		slots = append(slots, map[string]any{
+			"index": i,
+			"nzo_id": item.ID,
+			"filename": item.Name,
+			"cat": category,
+			"priority": priorityName(item.Priority),
+			"status": item.Status,
+			"mb": megabytes(item.Size),
+			"mbleft": megabytes(item.Remaining),
+			"percentage": strconv.Itoa(percentage),
+			"timeleft": clock(item.TimeLeft),
+		})
+	}
+
+	state := "Downloading"
+	if status.Paused {
+		state = "Paused"
+	} else if len(slots) == 0 {
+		state = "Idle"
+	}
+	writeJSON(w, map[string]any{"queue": map[string]any{
+		"status": state,
+		"paused": status.Paused,
+		"kbpersec": fmt.Sprintf("%.2f", float64(status.Speed)/1024),
+		"speed": fmt.Sprintf("%.1f M", float64(status.Speed)/(1<<20)),
+		"mb": megabytes(total),
+		"mbleft": megabytes(remaining),
+		"noofslots": len(slots),
+		"noofslots_total": len(slots),
+		"slots": slots,
+		"version": h.options.Version,
+	}})
+}
+
+func (h *Handler) history(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") == "delete" {
+		h.delete(w, r, true)
+		return
+	}
+
+	items, err := h.backend.History(r.Context())
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	slots := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		slots = append(slots, map[string]any{
+			"nzo_id": item.ID,
+			"name": item.Name,
+			"nzb_name": item.Name + ".nzb",
+			"category": item.Category,
+			"status": item.Status,
+			"bytes": item.Size,
+			"size": megabytes(item.Size) + " MB",
+			"completed": item.Completed.Unix(),
+			"fail_message": item.FailMessage,
+			"storage": item.Storage,
+		})
+	}
+	writeJSON(w, map[string]any{"history": map[string]any{
+		"noofslots": len(slots),
+		"slots": slots,
+		"version": h.options.Version,
+	}})
+}
+
+//Deletes the jobs listed in value, or all of them for "all", from the queue or the history.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, history bool) {
+	value := r.FormValue("value")
+	targets := ids(value)
+	if strings.EqualFold(value, "all") {
+		targets = nil
+		if history {
+			items, err := h.backend.History(r.Context())
+			if err != nil {
+				writeError(w, err.Error())
+				return
+			}
+			for _, item := range items {
+				targets = append(targets, item.ID)
+			}
+		} else {
+			status, err := h.backend.Queue(r.Context())
+			if err != nil {
+				writeError(w, err.Error())
+				return
+			}
+			for _, item := range status.Items {
+				targets = append(targets, item.ID)
+			}
+		}
+	} else if len(targets) == 0 {
+		writeError(w, "expects one parameter")
+		return
+	}
+
+	if err := h.backend.Delete(r.Context(), targets, history); err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"status": true, "nzo_ids": targets})
+}
+
+//Pauses or resumes a job, or the whole queue when id is empty.
+func (h *Handler) pauseResume(w http.ResponseWriter, r *http.Request, action string, id string) {
+	var err error
+	if action == "pause" {
+		err = h.backend.Pause(r.Context(), id)
+	} else {
+		err = h.backend.Resume(r.Context(), id)
+	}
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"status": true})
+}
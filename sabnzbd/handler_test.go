@@ -0,0 +1,186 @@
+package sabnzbd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//In-memory backend recording what the handler asks of it.
+type fakeBackend struct {
+	jobs []*Job
+	history []HistoryItem
+	deleted []string
+	paused map[string]bool
+}
+
+func (b *fakeBackend) Add(ctx context.Context, job *Job) (string, error) {
+	b.jobs = append(b.jobs, job)
+	return fmt.Sprintf("SABnzbd_nzo_%d", len(b.jobs)), nil
+}
+
+func (b *fakeBackend) Queue(ctx context.Context) (*QueueStatus, error) {
+	status := &QueueStatus{Paused: b.paused[""], Speed: 2 << 20}
+	for i, job := range b.jobs {
+		status.Items = append(status.Items, QueueItem{
+			ID: fmt.Sprintf("SABnzbd_nzo_%d", i+1),
+			Name: job.Name,
+			Category: job.Category,
+			Priority: job.Priority,
+			Status: "Queued",
+			Size: 4 << 20,
+			Remaining: 1 << 20,
+			TimeLeft: 90 * time.Second,
+		})
+	}
+	return status, nil
+}
+
+func (b *fakeBackend) History(ctx context.Context) ([]HistoryItem, error) {
+	return b.history, nil
+}
+
+func (b *fakeBackend) Delete(ctx context.Context, ids []string, history bool) error {
+	b.deleted = append(b.deleted, ids...)
+	return nil
+}
+
+func (b *fakeBackend) Pause(ctx context.Context, id string) error {
+	b.paused[id] = true
+	return nil
+}
+
+func (b *fakeBackend) Resume(ctx context.Context, id string) error {
+	delete(b.paused, id)
+	return nil
+}
+
+func (b *fakeBackend) Categories() []string {
+	return []string{"movies", "tv"}
+}
+
+//Issues a GET against the API and decodes its JSON response.
+func call(t *testing.T, server *httptest.Server, params url.Values) map[string]any {
+	t.Helper()
+	response, err := http.Get(server.URL + "/api?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var decoded map[string]any
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestHandler(t *testing.T) {
+	sample, err := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &fakeBackend{paused: map[string]bool{}}
+	server := httptest.NewServer(New(backend, Options{APIKey: "full", NZBKey: "nzbonly"}))
+	defer server.Close()
+
+	if got := call(t, server, url.Values{"mode": {"version"}}); got["version"] != "4.3.0" {
+		t.Fatalf("unexpected version response %v", got)
+	}
+	if got := call(t, server, url.Values{"mode": {"queue"}}); got["error"] != "API Key Required" {
+		t.Fatalf("missing key was not rejected: %v", got)
+	}
+	if got := call(t, server, url.Values{"mode": {"queue"}, "apikey": {"nzbonly"}}); got["error"] != "API Key Incorrect" {
+		t.Fatalf("NZB key was accepted for the queue: %v", got)
+	}
+
+	//Upload a gzipped NZB with the NZB key, overriding its title and category and adding a password through the name.
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(sample)
+	gz.Close()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("name", "Upload.Name.nzb.gz")
+	part.Write(compressed.Bytes())
+	form.WriteField("nzbname", "Renamed.Release{{secret}}")
+	form.WriteField("cat", "tv")
+	form.WriteField("priority", "1")
+	form.Close()
+	response, err := http.Post(server.URL+"/api?mode=addfile&apikey=nzbonly", form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var added map[string]any
+	json.NewDecoder(response.Body).Decode(&added)
+	response.Body.Close()
+	if added["status"] != true || fmt.Sprint(added["nzo_ids"]) != "[SABnzbd_nzo_1]" {
+		t.Fatalf("unexpected addfile response %v", added)
+	}
+
+	job := backend.jobs[0]
+	if job.Name != "Renamed.Release" || job.Category != "tv" || job.Password != "secret" || job.Priority != PriorityHigh {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	meta := job.Nzb.Head.Meta
+	if parser.Title(meta) != "Renamed.Release" || parser.Category(meta) != "tv" || !slices.Contains(parser.Passwords(meta), "secret") {
+		t.Fatalf("meta was not updated: %+v", meta)
+	}
+
+	//Fetch an NZB by URL, keeping the category from its meta when cat is "*".
+	nzbServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-DNZB-Name", "Indexer.Name")
+		w.Write(sample)
+	}))
+	defer nzbServer.Close()
+	got := call(t, server, url.Values{"mode": {"addurl"}, "apikey": {"full"}, "name": {nzbServer.URL + "/get/1"}, "cat": {"*"}})
+	if got["status"] != true || backend.jobs[1].Name != "Indexer.Name" || backend.jobs[1].Category != "misc." {
+		t.Fatalf("unexpected addurl result %v %+v", got, backend.jobs[1])
+	}
+
+	queue := call(t, server, url.Values{"mode": {"queue"}, "apikey": {"full"}})["queue"].(map[string]any)
+	slots := queue["slots"].([]any)
+	first := slots[0].(map[string]any)
+	if len(slots) != 2 || first["filename"] != "Renamed.Release" || first["priority"] != "High" || first["mbleft"] != "1.00" ||
+		first["percentage"] != "75" || first["timeleft"] != "0:01:30" {
+		t.Fatalf("unexpected queue %v", queue)
+	}
+
+	call(t, server, url.Values{"mode": {"pause"}, "apikey": {"full"}})
+	if !backend.paused[""] || call(t, server, url.Values{"mode": {"queue"}, "apikey": {"full"}})["queue"].(map[string]any)["status"] != "Paused" {
+		t.Fatal("queue was not paused")
+	}
+	call(t, server, url.Values{"mode": {"queue"}, "name": {"pause"}, "value": {"SABnzbd_nzo_2"}, "apikey": {"full"}})
+	call(t, server, url.Values{"mode": {"resume"}, "apikey": {"full"}})
+	if backend.paused[""] || !backend.paused["SABnzbd_nzo_2"] {
+		t.Fatalf("unexpected pause state %v", backend.paused)
+	}
+
+	call(t, server, url.Values{"mode": {"queue"}, "name": {"delete"}, "value": {"all"}, "apikey": {"full"}})
+	if fmt.Sprint(backend.deleted) != "[SABnzbd_nzo_1 SABnzbd_nzo_2]" {
+		t.Fatalf("unexpected deletions %v", backend.deleted)
+	}
+
+	backend.history = []HistoryItem{{ID: "SABnzbd_nzo_0", Name: "Old.Release", Status: "Failed", Size: 1 << 20, FailMessage: "CRC failed"}}
+	history := call(t, server, url.Values{"mode": {"history"}, "apikey": {"full"}})["history"].(map[string]any)
+	entry := history["slots"].([]any)[0].(map[string]any)
+	if entry["name"] != "Old.Release" || entry["fail_message"] != "CRC failed" || entry["bytes"] != float64(1<<20) {
+		t.Fatalf("unexpected history %v", history)
+	}
+
+	cats := call(t, server, url.Values{"mode": {"get_cats"}, "apikey": {"full"}})
+	if fmt.Sprint(cats["categories"]) != "[* movies tv]" {
+		t.Fatalf("unexpected categories %v", cats)
+	}
+}
@@ -0,0 +1,95 @@
+package sabnzbd
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Job priorities, as used by SABnzbd.
+const (
+	PriorityDefault = -100
+	PriorityPaused = -2
+	PriorityLow = -1
+	PriorityNormal = 0
+	PriorityHigh = 1
+	PriorityForce = 2
+)
+
+//A job submitted through addfile or addurl. Its meta has already been updated with Name, Category and Password.
+type Job struct {
+	Nzb *parser.Nzb
+	Name string
+	Category string
+	Priority int
+	//SABnzbd's post-processing level: -1 default, 0 none, 1 repair, 2 repair and unpack, 3 repair, unpack and delete.
+	PostProcessing int
+	Script string
+	Password string
+}
+
+//A queued job as reported by the backend. Sizes are in bytes.
+type QueueItem struct {
+	ID string
+	Name string
+	Category string
+	Priority int
+	//Such as "Queued", "Downloading" or "Paused".
+	Status string
+	Size int64
+	Remaining int64
+	TimeLeft time.Duration
+}
+
+//State of the whole queue.
+type QueueStatus struct {
+	Paused bool
+	//Download speed in bytes per second.
+	Speed int64
+	Items []QueueItem
+}
+
+//A finished job.
+type HistoryItem struct {
+	ID string
+	Name string
+	Category string
+	//"Completed" or "Failed".
+	Status string
+	Size int64
+	Completed time.Time
+	FailMessage string
+	//Directory the job's files ended up in.
+	Storage string
+}
+
+//Download engine behind the API. Pause and Resume act on the whole queue when id is empty.
+type Backend interface {
+	Add(ctx context.Context, job *Job) (string, error)
+	Queue(ctx context.Context) (*QueueStatus, error)
+	History(ctx context.Context) ([]HistoryItem, error)
+	Delete(ctx context.Context, ids []string, history bool) error
+	Pause(ctx context.Context, id string) error
+	Resume(ctx context.Context, id string) error
+	Categories() []string
+}
+
+//Settings for the API handler.
+type Options struct {
+	//Key required for every mode but version. Requests need no key if empty.
+	APIKey string
+	//Key that only allows adding NZBs, as handed to indexers and browser extensions.
+	NZBKey string
+	//Version reported by mode=version, which tools check for compatibility.
+	Version string
+	//Used by addurl; http.DefaultClient if nil.
+	HTTP *http.Client
+}
+
+//An http.Handler implementing the core of the SABnzbd API.
+type Handler struct {
+	backend Backend
+	options Options
+}
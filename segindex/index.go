@@ -0,0 +1,169 @@
+// Allows for random access into the files of an NZB by mapping decoded byte offsets to the segments that hold them.
+package segindex
+
+import (
+	"sort"
+
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Builds an index for a file, estimating each segment's decoded range from its encoded size.
+func New(file parser.File) *Index {
+	segments := append([]parser.Segment{}, file.Segments...)
+	sort.Slice(segments, func(a, b int) bool {
+		return segments[a].Number < segments[b].Number
+	})
+
+	idx := &Index{entries: make([]Entry, len(segments))}
+	for i, s := range segments {
+		idx.entries[i] = Entry{Segment: s}
+	}
+	idx.estimate()
+	return idx
+}
+
+//Recomputes the ranges of segments not yet seen. Once a part size is known every inexact segment is placed on that grid, since
+//posting tools split files evenly; before that, encoded sizes are used, scaled to the file size if it is known.
+func (idx *Index) estimate() {
+	n := int64(len(idx.entries))
+	if n == 0 {
+		return
+	}
+
+	if idx.partSize > 0 {
+		for i := range idx.entries {
+			e := &idx.entries[i]
+			if e.Exact {
+				continue
+			}
+			e.Begin = int64(i) * idx.partSize
+			e.End = e.Begin + idx.partSize
+			if idx.sizeExact {
+				e.Begin = min(e.Begin, idx.size)
+				e.End = min(e.End, idx.size)
+			}
+		}
+	} else {
+		estimates := make([]int64, n)
+		var total int64
+		for i, e := range idx.entries {
+			estimates[i] = max(yenc.EstimateDecodedSize(e.Segment.Bytes), 1)
+			total += estimates[i]
+		}
+
+		var offset int64
+		for i := range idx.entries {
+			size := estimates[i]
+			if idx.sizeExact && total > 0 {
+				size = estimates[i] * idx.size / total
+			}
+			e := &idx.entries[i]
+			if !e.Exact {
+				e.Begin, e.End = offset, offset+size
+			}
+			offset = e.End
+		}
+		//Rounding must not leave the tail of the file uncovered.
+		if last := &idx.entries[n-1]; idx.sizeExact && !last.Exact {
+			last.End = idx.size
+		}
+	}
+
+	idx.clamp()
+	if !idx.sizeExact {
+		idx.size = idx.entries[n-1].End
+	}
+}
+
+//Keeps estimated ranges between their neighbours, so that both bounds rise with the segment number and Segments can binary
+//search them even where an exact range disagrees with the estimates around it.
+func (idx *Index) clamp() {
+	for i := 1; i < len(idx.entries); i++ {
+		if e := &idx.entries[i]; !e.Exact {
+			e.Begin = max(e.Begin, idx.entries[i-1].End)
+			e.End = max(e.End, e.Begin)
+		}
+	}
+	for i := len(idx.entries) - 2; i >= 0; i-- {
+		if e := &idx.entries[i]; !e.Exact {
+			e.End = min(e.End, idx.entries[i+1].Begin)
+			e.Begin = min(e.Begin, e.End)
+		}
+	}
+}
+
+//Records the real range of a segment from its decoded part, and re-estimates the segments not yet seen. Parts whose message-ID
+//is not in the index, or whose range is impossible, are ignored.
+func (idx *Index) Refine(messageID string, part *yenc.Part) {
+	if part.Begin < 1 || part.End < part.Begin-1 {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i := range idx.entries {
+		e := &idx.entries[i]
+		if e.Segment.ID != messageID {
+			continue
+		}
+		e.Begin = part.Begin - 1
+		e.End = part.End
+		e.Exact = true
+
+		if part.FileSize > 0 {
+			idx.size = part.FileSize
+			idx.sizeExact = true
+		}
+		if i < len(idx.entries)-1 && e.End > e.Begin {
+			idx.partSize = e.End - e.Begin
+		}
+		idx.estimate()
+		return
+	}
+}
+
+//Returns the file's decoded size, and whether it is exact rather than estimated.
+func (idx *Index) Size() (int64, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.size, idx.sizeExact
+}
+
+//Returns a copy of every entry, in segment order.
+func (idx *Index) Entries() []Entry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return append([]Entry{}, idx.entries...)
+}
+
+//Returns the segments needed to read the inclusive byte range [from, to], as in an HTTP Range header. Where a boundary falls
+//on an estimated entry, its neighbour is included too, so that estimation errors are covered; callers should Refine with what
+//they fetch and trim to the exact ranges.
+func (idx *Index) Segments(from int64, to int64) []Entry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := len(idx.entries)
+	if n == 0 || to < from {
+		return nil
+	}
+
+	//First entry ending after from, and last entry beginning at or before to.
+	first := sort.Search(n, func(i int) bool {
+		return idx.entries[i].End > from
+	})
+	last := sort.Search(n, func(i int) bool {
+		return idx.entries[i].Begin > to
+	}) - 1
+	first = min(first, n-1)
+	last = max(last, first)
+
+	if !idx.entries[first].Exact && first > 0 {
+		first--
+	}
+	if !idx.entries[last].Exact && last < n-1 {
+		last++
+	}
+	return append([]Entry{}, idx.entries[first:last+1]...)
+}
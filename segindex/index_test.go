@@ -0,0 +1,80 @@
+package segindex
+
+import (
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+func TestIndexRefinement(t *testing.T) {
+	data := make([]byte, 2500)
+	parts := yenc.SplitParts("file.bin", data, 1000)
+
+	file := parser.File{}
+	for _, p := range parts {
+		file.Segments = append(file.Segments, parser.Segment{
+			ID: p.Name + string(rune('a'+p.Number)),
+			Number: p.Number,
+			Bytes: len(yenc.EncodePart(p, 0)) + 300,
+		})
+	}
+	//Segments listed out of order are still indexed by number.
+	file.Segments[0], file.Segments[2] = file.Segments[2], file.Segments[0]
+
+	idx := New(file)
+	if size, exact := idx.Size(); exact || size < 2000 || size > 3000 {
+		t.Fatalf("unexpected estimated size %d", size)
+	}
+
+	first := idx.Entries()[0]
+	idx.Refine(first.Segment.ID, &parts[0])
+	if size, exact := idx.Size(); !exact || size != 2500 {
+		t.Fatalf("expected the exact size after refining, got %d", size)
+	}
+
+	//With the part size known, the other entries fall on the 1000-byte grid.
+	entries := idx.Entries()
+	if entries[1].Begin != 1000 || entries[1].End != 2000 || entries[2].End != 2500 {
+		t.Fatalf("unexpected re-estimated entries: %+v", entries)
+	}
+
+	//Bytes 1500-1600 lie in the second segment, whose estimate pulls in its neighbours.
+	if got := idx.Segments(1500, 1600); len(got) != 3 {
+		t.Fatalf("expected the estimated segment and its neighbours, got %d", len(got))
+	}
+	idx.Refine(entries[1].Segment.ID, &parts[1])
+	idx.Refine(entries[2].Segment.ID, &parts[2])
+	if got := idx.Segments(1500, 1600); len(got) != 1 || got[0].Segment.Number != 2 {
+		t.Fatalf("expected only the second segment, got %+v", got)
+	}
+	if got := idx.Segments(999, 1000); len(got) != 2 {
+		t.Fatalf("a range across a boundary should need two segments, got %d", len(got))
+	}
+}
+
+func TestIndexRefineKeepsRangesOrdered(t *testing.T) {
+	file := parser.File{}
+	for i, bytes := range []int{1300, 1300, 700} {
+		file.Segments = append(file.Segments, parser.Segment{ID: string(rune('a' + i)), Number: i + 1, Bytes: bytes})
+	}
+	idx := New(file)
+
+	//A part claiming to start before the file is ignored.
+	idx.Refine("c", &yenc.Part{Begin: 0, End: 500, FileSize: 2500})
+	if _, exact := idx.Size(); exact || idx.Entries()[2].Exact {
+		t.Fatal("a part beginning at offset 0 was recorded")
+	}
+
+	//The last part starts well before the estimates place it, which must not leave the middle entry reaching past it.
+	idx.Refine("c", &yenc.Part{Begin: 1001, End: 2500, FileSize: 2500})
+	entries := idx.Entries()
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Begin < entries[i-1].Begin || entries[i].End < entries[i-1].End || entries[i-1].End > entries[i].Begin {
+			t.Fatalf("entries out of order: %+v", entries)
+		}
+	}
+	if got := idx.Segments(1500, 1600); len(got) == 0 || got[len(got)-1].Segment.ID != "c" {
+		t.Fatalf("the exact last segment was not found: %+v", got)
+	}
+}
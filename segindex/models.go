@@ -0,0 +1,26 @@
+package segindex
+
+import (
+	"sync"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Decoded byte range a segment covers, as the half-open interval [Begin, End) of 0-based offsets. Exact is set once the range
+//comes from the segment's own =ypart line rather than an estimate.
+type Entry struct {
+	Segment parser.Segment
+	Begin int64
+	End int64
+	Exact bool
+}
+
+//Maps decoded byte offsets of a file to the segments holding them. Safe for concurrent use.
+type Index struct {
+	mu sync.RWMutex
+	entries []Entry
+	size int64
+	sizeExact bool
+	//Size of every part but the last, once one non-final part has been seen.
+	partSize int64
+}
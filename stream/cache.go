@@ -0,0 +1,51 @@
+package stream
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jgr0sz/nzbgo/cache"
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Creates a fetch coordinator over a segment cache.
+func newPartCache(store cache.Cache) *partCache {
+	return &partCache{
+		store: store,
+		inflight: map[string]*fetchCall{},
+	}
+}
+
+//Returns a decoded segment, from the cache if possible. Concurrent callers asking for the same segment share one fetch.
+//Data failing its CRC check is still served, uncached; a player copes better with a glitch than a gap.
+func (c *partCache) get(ctx context.Context, fetcher nntp.Fetcher, id string) (*yenc.Part, error) {
+	if part, ok := c.store.Get(id); ok {
+		return part, nil
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[id]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.ready:
+			return call.part, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &fetchCall{ready: make(chan struct{})}
+	c.inflight[id] = call
+	c.mu.Unlock()
+
+	call.part, call.err = cache.FetchPart(ctx, c.store, fetcher, id)
+	if errors.Is(call.err, yenc.ErrCRCMismatch) {
+		call.err = nil
+	}
+	close(call.ready)
+
+	c.mu.Lock()
+	delete(c.inflight, id)
+	c.mu.Unlock()
+	return call.part, call.err
+}
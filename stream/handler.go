@@ -0,0 +1,262 @@
+// Allows for streaming the files of an NZB over HTTP, serving Range requests by fetching and decoding only the segments needed.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/cache"
+	"github.com/jgr0sz/nzbgo/internal/rar"
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/segindex"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Errors returned when opening files.
+var (
+	ErrNoSuchFile = errors.New("stream: no such file")
+	ErrNotStored = errors.New("stream: rar set is compressed or encrypted and cannot be streamed")
+)
+
+//Creates a handler serving the files of an NZB. Routes:
+//
+//	GET /                      JSON list of streamable files
+//	GET /files/{index}/{name}  file index of the NZB, par2 files excluded
+//	GET /sets/{index}/{name}   inner file of the stored rar set whose first volume is file index
+func New(nzb *parser.Nzb, fetcher nntp.Fetcher, options Options) *Handler {
+	if options.ReadAhead == 0 {
+		options.ReadAhead = 2
+	}
+	if options.CacheSegments <= 0 {
+		options.CacheSegments = 16
+	}
+	if options.Cache == nil {
+		options.Cache = cache.NewMemory(int64(options.CacheSegments) * yenc.DefaultPartSize)
+	}
+
+	h := &Handler{
+		nzb: nzb,
+		fetcher: fetcher,
+		options: options,
+		cache: newPartCache(options.Cache),
+		volumes: rarVolumes(nzb),
+		files: map[int]*fileSource{},
+		sets: map[int]*rarSource{},
+		unstreamable: map[int]bool{},
+	}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /{$}", h.serveListing)
+	h.mux.HandleFunc("GET /files/{index}/{name}", h.serveFile)
+	h.mux.HandleFunc("GET /sets/{index}/{name}", h.serveSet)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+//Opens a file of the NZB, fetching its first segment to learn its exact size. The fetch runs without holding the handler's
+//lock; concurrent openers share it through the segment cache, and the first to finish is kept.
+func (h *Handler) file(ctx context.Context, idx int) (*fileSource, error) {
+	if idx < 0 || idx >= len(h.nzb.Files) || parser.IsPar2(&h.nzb.Files[idx]) {
+		return nil, ErrNoSuchFile
+	}
+
+	h.mu.Lock()
+	src, ok := h.files[idx]
+	h.mu.Unlock()
+	if ok {
+		return src, nil
+	}
+
+	file := h.nzb.Files[idx]
+	src = &fileSource{handler: h, file: file, index: segindex.New(file)}
+	entries := src.index.Entries()
+	if len(entries) == 0 {
+		return nil, ErrNoSuchFile
+	}
+	if _, _, err := src.part(ctx, entries[0]); err != nil {
+		return nil, err
+	}
+	src.size, _ = src.index.Size()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if existing, ok := h.files[idx]; ok {
+		return existing, nil
+	}
+	h.files[idx] = src
+	return src, nil
+}
+
+//Groups the rar volumes of an NZB into sets, ordered by volume number and keyed by the index of each set's first volume.
+func rarVolumes(nzb *parser.Nzb) map[int][]int {
+	bySet := map[string][]int{}
+	numbers := map[int]int{}
+	for i, f := range nzb.Files {
+		name := parser.ExtractFilename(f)
+		if number := volumeNumber(name); number >= 0 {
+			numbers[i] = number
+			bySet[parser.SetName(name)] = append(bySet[parser.SetName(name)], i)
+		}
+	}
+
+	volumes := map[int][]int{}
+	for _, set := range bySet {
+		sort.SliceStable(set, func(a, b int) bool {
+			return numbers[set[a]] < numbers[set[b]]
+		})
+		volumes[set[0]] = set
+	}
+	return volumes
+}
+
+//Opens the inner file of a stored rar set, reading the first file header of every volume. Sets found not to be streamable are
+//remembered; fetch failures are not, as they may be temporary.
+func (h *Handler) rarSet(ctx context.Context, idx int) (*rarSource, error) {
+	volumes, ok := h.volumes[idx]
+	if !ok {
+		return nil, ErrNoSuchFile
+	}
+
+	h.mu.Lock()
+	src, ok := h.sets[idx]
+	unstreamable := h.unstreamable[idx]
+	h.mu.Unlock()
+	if ok {
+		return src, nil
+	}
+	if unstreamable {
+		return nil, ErrNotStored
+	}
+
+	src = &rarSource{set: parser.SetName(parser.ExtractFilename(h.nzb.Files[idx]))}
+	for _, v := range volumes {
+		volume, err := h.file(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		data, _, err := volume.part(ctx, volume.index.Entries()[0])
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := rar.FirstFile(data)
+		if err != nil || !header.Stored || header.Encrypted {
+			h.mu.Lock()
+			h.unstreamable[idx] = true
+			h.mu.Unlock()
+			return nil, ErrNotStored
+		}
+		if src.name == "" {
+			src.name = header.Name
+		}
+		src.slices = append(src.slices, rarSlice{volume: volume, offset: header.DataOffset, length: header.DataLength})
+		src.size += header.DataLength
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if existing, ok := h.sets[idx]; ok {
+		return existing, nil
+	}
+	h.sets[idx] = src
+	return src, nil
+}
+
+//Lists the streamable files and stored rar sets. Files that cannot be opened are left out.
+func (h *Handler) serveListing(w http.ResponseWriter, r *http.Request) {
+	listing := []Listing{}
+	for i, f := range h.nzb.Files {
+		if parser.IsPar2(&f) {
+			continue
+		}
+		src, err := h.file(r.Context(), i)
+		if err != nil {
+			continue
+		}
+		name := parser.ExtractFilename(f)
+		listing = append(listing, Listing{
+			Name: name,
+			Path: fmt.Sprintf("/files/%d/%s", i, url.PathEscape(name)),
+			Size: src.size,
+		})
+
+		if _, ok := h.volumes[i]; !ok {
+			continue
+		}
+		if set, err := h.rarSet(r.Context(), i); err == nil {
+			listing = append(listing, Listing{
+				Name: set.name,
+				Path: fmt.Sprintf("/sets/%d/%s", i, url.PathEscape(set.name)),
+				Size: set.size,
+				Set: set.set,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listing)
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request) {
+	idx, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	src, err := h.file(r.Context(), idx)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	serve(w, r, parser.ExtractFilename(src.file), modified(src.file), src)
+}
+
+func (h *Handler) serveSet(w http.ResponseWriter, r *http.Request) {
+	idx, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	src, err := h.rarSet(r.Context(), idx)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	serve(w, r, src.name, modified(h.nzb.Files[idx]), src)
+}
+
+//Returns when a file was posted, or the zero time for files without a date, which leaves out the Last-Modified header.
+func modified(file parser.File) time.Time {
+	if file.Date == 0 {
+		return time.Time{}
+	}
+	return parser.DatePosted(file)
+}
+
+//Serves a source with Range support, letting net/http handle the headers.
+func serve(w http.ResponseWriter, r *http.Request, name string, modified time.Time, src source) {
+	http.ServeContent(w, r, name, modified, &readSeeker{ctx: r.Context(), src: src})
+}
+
+//Maps errors to responses: unknown files are 404, sets that cannot be streamed 415 and fetch failures 502.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNoSuchFile):
+		http.NotFound(w, r)
+	case errors.Is(err, ErrNotStored):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
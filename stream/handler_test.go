@@ -0,0 +1,278 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/cache"
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/nntptest"
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Builds a RAR 4.x volume storing a chunk of an inner file.
+func rarVolume(name string, chunk []byte) []byte {
+	data := []byte("Rar!\x1a\x07\x00")
+	data = append(data, 0, 0, 0x73, 0, 0, 13, 0, 0, 0, 0, 0, 0, 0)
+
+	header := binary.LittleEndian.AppendUint32(nil, uint32(len(chunk)))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(chunk)))
+	header = append(header, 2, 0, 0, 0, 0, 0, 0, 0, 0, 29, 0x30)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(name)))
+	header = append(header, 0, 0, 0, 0)
+	header = append(header, name...)
+
+	data = append(data, 0, 0, 0x74, 0x00, 0x80)
+	data = binary.LittleEndian.AppendUint16(data, uint16(7+len(header)))
+	data = append(data, header...)
+	return append(data, chunk...)
+}
+
+func TestStreamRanges(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	random := rand.New(rand.NewSource(1))
+	video := make([]byte, 10000)
+	random.Read(video)
+	inner := make([]byte, 6000)
+	random.Read(inner)
+
+	nzb := &parser.Nzb{}
+	server.AddNzbFile(nzb, "video.mkv", video, 1000)
+	server.AddNzbFile(nzb, "video.mkv.par2", make([]byte, 100), 1000)
+	server.AddNzbFile(nzb, "movie.part1.rar", rarVolume("movie.mkv", inner[:3500]), 1000)
+	server.AddNzbFile(nzb, "movie.part2.rar", rarVolume("movie.mkv", inner[3500:]), 1000)
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	ts := httptest.NewServer(New(nzb, client, Options{ReadAhead: -1}))
+	defer ts.Close()
+
+	get := func(path string, rangeHeader string) (*http.Response, []byte) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	resp, body := get("/files/0/video.mkv", "bytes=2500-4499")
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, video[2500:4500]) {
+		t.Fatalf("unexpected range response: %d, %d bytes", resp.StatusCode, len(body))
+	}
+	//The first segment for the size, then the three covering the range and at most their estimated neighbours.
+	if fetched := server.CommandCount("BODY"); fetched > 6 {
+		t.Fatalf("fetched %d of 10 segments for a 2000 byte range", fetched)
+	}
+
+	_, body = get("/files/0/video.mkv", "")
+	if !bytes.Equal(body, video) {
+		t.Fatal("full response does not match the file")
+	}
+
+	var listing []Listing
+	_, body = get("/", "")
+	if err := json.Unmarshal(body, &listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing) != 4 || listing[2].Name != "movie.mkv" || listing[2].Size != int64(len(inner)) {
+		t.Fatalf("unexpected listing: %+v", listing)
+	}
+
+	resp, body = get(listing[2].Path, "bytes=3000-3999")
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, inner[3000:4000]) {
+		t.Fatalf("unexpected inner range response: %d, %d bytes", resp.StatusCode, len(body))
+	}
+
+	if resp, _ := get("/files/1/video.mkv.par2", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("par2 files should not be served, got %d", resp.StatusCode)
+	}
+}
+
+//Fetcher that can hold back chosen segments and tracks how many fetches run at once.
+type gatedFetcher struct {
+	fetcher nntp.Fetcher
+	gates map[string]chan struct{}
+	mu sync.Mutex
+	held int
+	active int
+	peak int
+}
+
+func (f *gatedFetcher) Fetch(ctx context.Context, messageID string) ([]byte, error) {
+	f.mu.Lock()
+	gate := f.gates[messageID]
+	if gate != nil {
+		f.held++
+	}
+	f.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+
+	f.mu.Lock()
+	f.active++
+	f.peak = max(f.peak, f.active)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+	time.Sleep(2 * time.Millisecond)
+	return f.fetcher.Fetch(ctx, messageID)
+}
+
+func TestStreamConcurrency(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	first := bytes.Repeat([]byte{1}, 5000)
+	second := bytes.Repeat([]byte{2}, 20000)
+	nzb := &parser.Nzb{}
+	server.AddNzbFile(nzb, "slow.mkv", first, 1000)
+	server.AddNzbFile(nzb, "fast.mkv", second, 1000)
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+
+	gate := make(chan struct{})
+	fetcher := &gatedFetcher{fetcher: client, gates: map[string]chan struct{}{nzb.Files[0].Segments[0].ID: gate}}
+	ts := httptest.NewServer(New(nzb, fetcher, Options{ReadAhead: 2}))
+	defer ts.Close()
+
+	//A request stuck on a slow segment does not hold up others.
+	slow := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(ts.URL + "/files/0/slow.mkv")
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		slow <- err
+	}()
+	for {
+		fetcher.mu.Lock()
+		held := fetcher.held
+		fetcher.mu.Unlock()
+		if held > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan []byte, 1)
+	go func() {
+		resp, err := http.Get(ts.URL + "/files/1/fast.mkv")
+		if err != nil {
+			done <- nil
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		done <- body
+	}()
+
+	select {
+	case body := <-done:
+		if !bytes.Equal(body, second) {
+			t.Fatal("fast file was not served correctly")
+		}
+	case <-time.After(5 * time.Second):
+		close(gate)
+		t.Fatal("request was blocked by a fetch for another file")
+	}
+	close(gate)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+
+	//The reader and a single read-ahead worker at most.
+	if fetcher.peak > 2 {
+		t.Fatalf("%d fetches ran at once", fetcher.peak)
+	}
+}
+
+func TestStreamListingCachesSets(t *testing.T) {
+	server, err := nntptest.Start(nntptest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	nzb := &parser.Nzb{}
+	for _, name := range []string{"packed.part1.rar", "packed.part2.rar", "packed.part3.rar"} {
+		volume := rarVolume("packed.mkv", make([]byte, 500))
+		//Compression method "best" instead of "store".
+		volume[45] = 0x35
+		server.AddNzbFile(nzb, name, volume, 1000)
+	}
+	server.AddNzbFile(nzb, "undated.mkv", make([]byte, 500), 1000)
+	nzb.Files[3].Date = 0
+
+	client, err := nntp.Dial(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Quit()
+	ts := httptest.NewServer(New(nzb, client, Options{ReadAhead: -1, Cache: cache.NewMemory(1)}))
+	defer ts.Close()
+
+	list := func() []Listing {
+		resp, err := http.Get(ts.URL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var listing []Listing
+		if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+			t.Fatal(err)
+		}
+		return listing
+	}
+	if listing := list(); len(listing) != 4 {
+		t.Fatalf("expected the four files without the compressed set, got %+v", listing)
+	}
+	fetched := server.CommandCount("BODY")
+	list()
+	if server.CommandCount("BODY") != fetched {
+		t.Fatalf("listing again fetched %d more segments", server.CommandCount("BODY")-fetched)
+	}
+
+	resp, err := http.Get(ts.URL + "/files/3/undated.mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		t.Fatalf("undated file sent Last-Modified %q", modified)
+	}
+}
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/jgr0sz/nzbgo/cache"
+	"github.com/jgr0sz/nzbgo/nntp"
+	"github.com/jgr0sz/nzbgo/parser"
+	"github.com/jgr0sz/nzbgo/segindex"
+	"github.com/jgr0sz/nzbgo/yenc"
+)
+
+//Settings for a streaming handler.
+type Options struct {
+	//Segments fetched ahead of the one being read, 2 if zero. Negative disables read-ahead.
+	ReadAhead int
+	//Decoded segments kept in memory, 16 if zero. Ignored when Cache is set.
+	CacheSegments int
+	//Cache of decoded segments to use instead of a private memory cache, for sharing one between handlers or adding a disk tier.
+	Cache cache.Cache
+}
+
+//A file exposed by the handler, as listed at the root URL. Inner files of stored rar sets carry the name of their set.
+type Listing struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Size int64 `json:"size"`
+	Set string `json:"set,omitempty"`
+}
+
+//Serves the files of an NZB over HTTP, fetching only the segments each Range request needs.
+type Handler struct {
+	nzb *parser.Nzb
+	fetcher nntp.Fetcher
+	options Options
+	mux *http.ServeMux
+	cache *partCache
+	//Rar volumes of each set in order, keyed by the index of the set's first volume.
+	volumes map[int][]int
+
+	mu sync.Mutex
+	files map[int]*fileSource
+	sets map[int]*rarSource
+	//Sets known not to be streamable, so listings do not fetch their volumes again.
+	unstreamable map[int]bool
+}
+
+//A single file of the NZB, read through its segment index.
+type fileSource struct {
+	handler *Handler
+	file parser.File
+	index *segindex.Index
+	size int64
+
+	//Read-ahead state: a single worker fetches the segments following ahead, the latest segment read, until it catches up.
+	aheadMu sync.Mutex
+	ahead string
+	aheadRunning bool
+}
+
+//A contiguous slice of a rar volume holding part of a stored inner file.
+type rarSlice struct {
+	volume *fileSource
+	offset int64
+	length int64
+}
+
+//The inner file of a stored (uncompressed) rar set, read straight from its volumes' data areas.
+type rarSource struct {
+	name string
+	set string
+	slices []rarSlice
+	size int64
+}
+
+//A fetch in progress. ready is closed once part or err is set.
+type fetchCall struct {
+	ready chan struct{}
+	part *yenc.Part
+	err error
+}
+
+//Segment cache that also collapses concurrent fetches of the same segment.
+type partCache struct {
+	store cache.Cache
+	mu sync.Mutex
+	inflight map[string]*fetchCall
+}
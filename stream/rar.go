@@ -0,0 +1,31 @@
+package stream
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Precompiled regexes for rar volume names, new style (name.part01.rar) and old style (name.rar, name.r00, name.s00...).
+var (
+	rarPartPattern = regexp.MustCompile(`(?i)\.part(\d+)\.rar$`)
+	rarOldPattern = regexp.MustCompile(`(?i)\.([r-z])(\d{2})$`)
+	rarPattern = regexp.MustCompile(`(?i)\.rar$`)
+)
+
+//Orders rar volumes: name.part1.rar before name.part2.rar, and name.rar before name.r00, name.r99 and name.s00.
+//Returns -1 for names that are not rar volumes.
+func volumeNumber(filename string) int {
+	if match := rarPartPattern.FindStringSubmatch(filename); match != nil {
+		number, _ := strconv.Atoi(match[1])
+		return number
+	}
+	if match := rarOldPattern.FindStringSubmatch(filename); match != nil {
+		number, _ := strconv.Atoi(match[2])
+		return 1 + int(strings.ToLower(match[1])[0]-'r')*100 + number
+	}
+	if rarPattern.MatchString(filename) {
+		return 0
+	}
+	return -1
+}
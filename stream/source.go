@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/segindex"
+)
+
+//Returned when the segments of a file do not cover a requested range, even after refining the index.
+var ErrRangeNotCovered = errors.New("stream: segments do not cover the requested range")
+
+//Something that can be read at arbitrary offsets and has a known size.
+type source interface {
+	readAt(ctx context.Context, p []byte, off int64) (int, error)
+	length() int64
+}
+
+//Fetches a segment through the handler's cache and records its real range in the index.
+func (s *fileSource) part(ctx context.Context, entry segindex.Entry) ([]byte, int64, error) {
+	part, err := s.handler.cache.get(ctx, s.handler.fetcher, entry.Segment.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.index.Refine(entry.Segment.ID, part)
+	return part.Data, part.Begin - 1, nil
+}
+
+func (s *fileSource) length() int64 {
+	return s.size
+}
+
+//Reads decoded bytes of the file. Segments are located through the index; if an estimate was off, the refined index is
+//consulted again, a few times at most.
+func (s *fileSource) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if off >= s.size {
+		return 0, io.EOF
+	}
+	want := int(min(int64(len(p)), s.size-off))
+
+	n := 0
+	for attempt := 0; n < want && attempt < 4; attempt++ {
+		entries := s.index.Segments(off+int64(n), off+int64(want)-1)
+		for _, e := range entries {
+			pos := off + int64(n)
+			//Entries known to end before the position are skipped; estimated ones are fetched, as their real range may still cover it.
+			if e.Exact && e.End <= pos {
+				continue
+			}
+			data, begin, err := s.part(ctx, e)
+			if err != nil {
+				return n, err
+			}
+			if begin <= pos && pos < begin+int64(len(data)) {
+				n += copy(p[n:want], data[pos-begin:])
+				s.readAhead(e)
+			}
+			if n >= want {
+				break
+			}
+		}
+	}
+
+	if n < want {
+		return n, ErrRangeNotCovered
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+//Has the segments after entry fetched in the background, so sequential playback does not wait on every segment. A single
+//worker per file does the fetching; reads made while it is busy only move its target forward.
+func (s *fileSource) readAhead(entry segindex.Entry) {
+	if s.handler.options.ReadAhead <= 0 {
+		return
+	}
+
+	s.aheadMu.Lock()
+	defer s.aheadMu.Unlock()
+	s.ahead = entry.Segment.ID
+	if s.aheadRunning {
+		return
+	}
+	s.aheadRunning = true
+	go s.readAheadWorker()
+}
+
+//Fetches the segments following the latest read until no newer read is waiting, then exits.
+func (s *fileSource) readAheadWorker() {
+	for {
+		s.aheadMu.Lock()
+		id := s.ahead
+		s.ahead = ""
+		if id == "" {
+			s.aheadRunning = false
+			s.aheadMu.Unlock()
+			return
+		}
+		s.aheadMu.Unlock()
+
+		entries := s.index.Entries()
+		for i, e := range entries {
+			if e.Segment.ID != id {
+				continue
+			}
+			for _, next := range entries[i+1 : min(i+1+s.handler.options.ReadAhead, len(entries))] {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				s.part(ctx, next)
+				cancel()
+			}
+			break
+		}
+	}
+}
+
+func (s *rarSource) length() int64 {
+	return s.size
+}
+
+//Reads bytes of the inner file by mapping them onto the volumes' data areas.
+func (s *rarSource) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	start := int64(0)
+	for _, slice := range s.slices {
+		end := start + slice.length
+		pos := off + int64(n)
+		if n < len(p) && pos >= start && pos < end {
+			chunk := p[n:min(len(p), n+int(end-pos))]
+			read, err := slice.volume.readAt(ctx, chunk, slice.offset+pos-start)
+			n += read
+			if err != nil && !(errors.Is(err, io.EOF) && read == len(chunk)) {
+				return n, err
+			}
+		}
+		start = end
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+//Adapts a source to io.ReadSeeker for http.ServeContent, binding it to a request's context.
+type readSeeker struct {
+	ctx context.Context
+	src source
+	pos int64
+}
+
+func (r *readSeeker) Read(p []byte) (int, error) {
+	if r.pos >= r.src.length() {
+		return 0, io.EOF
+	}
+	n, err := r.src.readAt(r.ctx, p, r.pos)
+	r.pos += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.src.length()
+	default:
+		return 0, errors.New("stream: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("stream: negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
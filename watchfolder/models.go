@@ -0,0 +1,74 @@
+package watchfolder
+
+import (
+	"regexp"
+	"time"
+)
+
+//Files a category on NZBs whose filename matches Pattern. A nil Pattern matches every filename.
+type Rule struct {
+	Pattern *regexp.Regexp
+	Category string
+}
+
+//Settings for a watcher.
+type Options struct {
+	//Directory polled for .nzb and .nzb.gz files.
+	Directory string
+	//Valid NZBs are moved to a subdirectory of Outbox named after their category.
+	Outbox string
+	//Invalid NZBs are moved here along with a reason file; Directory/failed if empty.
+	Failed string
+	//Consulted in order when an NZB carries no category meta.
+	Rules []Rule
+	//Category of NZBs no rule matched, "default" if empty.
+	DefaultCategory string
+	//Time between polls, 5 seconds if zero.
+	Interval time.Duration
+	//Time a file's size and modification time must stay unchanged before it is picked up, so files still being written are left
+	//alone. One poll interval if zero.
+	Settle time.Duration
+	//Receives the outcome of every processed file when set. Results are sent blocking, so it must be drained.
+	Results chan<- Result
+}
+
+//Overview of an NZB, written as JSON next to it in the outbox.
+type Summary struct {
+	Name string `json:"name"`
+	Title string `json:"title,omitempty"`
+	Category string `json:"category"`
+	//Whether Category came from the NZB's meta rather than a rule or the default.
+	CategoryFromMeta bool `json:"category_from_meta"`
+	Size int `json:"size"`
+	Files int `json:"files"`
+	Par2Files int `json:"par2_files"`
+	Par2Percentage float64 `json:"par2_percentage"`
+	Groups []string `json:"groups"`
+	Tags []string `json:"tags,omitempty"`
+	HasPassword bool `json:"has_password"`
+	//When the files were posted; nil, and left out of the JSON, if they carry no dates.
+	Posted *time.Time `json:"posted,omitempty"`
+	Processed time.Time `json:"processed"`
+}
+
+//Outcome for a single file. Destination is where the file was moved: the outbox on success, the failed folder otherwise.
+type Result struct {
+	Source string
+	Destination string
+	Summary *Summary
+	Err error
+}
+
+//Size and modification time of a file as last seen, and since when they have been unchanged.
+type observation struct {
+	size int64
+	modified time.Time
+	since time.Time
+}
+
+//Polls a directory for NZBs, validating and routing each one.
+type Watcher struct {
+	options Options
+	pending map[string]observation
+	now func() time.Time
+}
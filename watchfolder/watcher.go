@@ -0,0 +1,269 @@
+// Allows for ingesting NZBs dropped into a directory: they are validated, filed by category into an outbox with a JSON summary,
+// or moved aside with the reason they were rejected.
+package watchfolder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Reported for NZBs that parse but list no files.
+var ErrEmpty = errors.New("watchfolder: NZB contains no files")
+
+//Creates a watcher, applying defaults. The failed folder and outbox are created when first needed.
+func New(options Options) *Watcher {
+	if options.Failed == "" {
+		options.Failed = filepath.Join(options.Directory, "failed")
+	}
+	if options.DefaultCategory == "" {
+		options.DefaultCategory = "default"
+	}
+	if options.Interval <= 0 {
+		options.Interval = 5 * time.Second
+	}
+	if options.Settle <= 0 {
+		options.Settle = options.Interval
+	}
+	return &Watcher{options: options, pending: map[string]observation{}, now: time.Now}
+}
+
+//Polls until ctx is cancelled. Returns early only if the watched directory cannot be read.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//Scans the directory once, processing every NZB that has settled. Files seen for the first time, or that changed since the last
+//poll, are only picked up by a later poll.
+func (w *Watcher) Poll(ctx context.Context) ([]Result, error) {
+	entries, err := os.ReadDir(w.options.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	now := w.now()
+	seen := map[string]bool{}
+	var results []Result
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !IsNzbName(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			//Removed since the directory was read.
+			continue
+		}
+		seen[name] = true
+
+		last, ok := w.pending[name]
+		if !ok || last.size != info.Size() || !last.modified.Equal(info.ModTime()) {
+			w.pending[name] = observation{size: info.Size(), modified: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(last.since) < w.options.Settle {
+			continue
+		}
+
+		delete(w.pending, name)
+		result := w.process(filepath.Join(w.options.Directory, name))
+		results = append(results, result)
+		if w.options.Results != nil {
+			select {
+			case w.options.Results <- result:
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	for name := range w.pending {
+		if !seen[name] {
+			delete(w.pending, name)
+		}
+	}
+	return results, nil
+}
+
+//Checks whether a filename is an NZB the watcher picks up. Hidden files, used by many tools while writing, are ignored.
+func IsNzbName(name string) bool {
+	lower := strings.ToLower(name)
+	return !strings.HasPrefix(name, ".") && (strings.HasSuffix(lower, ".nzb") || strings.HasSuffix(lower, ".nzb.gz"))
+}
+
+//Validates and routes a single file.
+func (w *Watcher) process(path string) Result {
+	result := Result{Source: path}
+
+	nzb, err := parser.FromFile(path)
+	if err == nil && len(nzb.Files) == 0 {
+		err = ErrEmpty
+	}
+	if err != nil {
+		result.Err = err
+		result.Destination, err = w.reject(path, err)
+		if err != nil {
+			result.Err = errors.Join(result.Err, err)
+		}
+		return result
+	}
+
+	name := filepath.Base(path)
+	summary := Summarize(nzb, parser.StripNzbExtension(name))
+	summary.Processed = w.now().UTC()
+	if summary.Category == "" {
+		summary.Category = w.categorize(name)
+	}
+	result.Summary = &summary
+
+	dir := filepath.Join(w.options.Outbox, safeName(summary.Category))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		result.Err = err
+		return result
+	}
+	destination := uniquePath(dir, name)
+
+	//The summary is written first, so anything picking up the NZB from the outbox finds its summary already there.
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err == nil {
+		err = writeAtomic(destination+".json", data)
+	}
+	if err == nil {
+		err = move(path, destination)
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Destination = destination
+	return result
+}
+
+//Builds the summary of an NZB. Category is left empty if the NZB carries none.
+func Summarize(nzb *parser.Nzb, name string) Summary {
+	summary := Summary{
+		Name: name,
+		Title: parser.Title(nzb.Head.Meta),
+		Category: parser.Category(nzb.Head.Meta),
+		Size: parser.Size(nzb),
+		Files: len(nzb.Files),
+		Par2Files: len(parser.Par2_files(nzb)),
+		Groups: parser.Groups(nzb),
+		Tags: parser.Tags(nzb.Head.Meta),
+		HasPassword: len(parser.Passwords(nzb.Head.Meta)) > 0,
+	}
+	if posted := parser.Posted(nzb); !posted.IsZero() {
+		summary.Posted = &posted
+	}
+	summary.CategoryFromMeta = summary.Category != ""
+	if summary.Size > 0 {
+		summary.Par2Percentage = parser.Par2_percentage(nzb)
+	}
+	return summary
+}
+
+//Picks the category of an NZB without category meta from the filename rules.
+func (w *Watcher) categorize(name string) string {
+	for _, rule := range w.options.Rules {
+		if rule.Pattern == nil || rule.Pattern.MatchString(name) {
+			return rule.Category
+		}
+	}
+	return w.options.DefaultCategory
+}
+
+//Moves an invalid NZB to the failed folder and writes the reason next to it. Returns where the NZB went.
+func (w *Watcher) reject(path string, reason error) (string, error) {
+	if err := os.MkdirAll(w.options.Failed, 0755); err != nil {
+		return "", err
+	}
+	destination := uniquePath(w.options.Failed, filepath.Base(path))
+	text := fmt.Sprintf("%s\n%s\n", w.now().UTC().Format(time.RFC3339), reason)
+	if err := writeAtomic(destination+".reason.txt", []byte(text)); err != nil {
+		return "", err
+	}
+	return destination, move(path, destination)
+}
+
+//Returns a path for name in dir that is not taken yet, numbering it before the extension if needed.
+func uniquePath(dir string, name string) string {
+	base := parser.StripNzbExtension(name)
+	extension := name[len(base):]
+	candidate := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, extension))
+	}
+}
+
+//Makes a category usable as a single directory name.
+func safeName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", "\x00", "").Replace(strings.TrimSpace(name))
+	if strings.Trim(name, ".") == "" {
+		return "_"
+	}
+	return name
+}
+
+//Writes a file through a temporary file and a rename, so readers never see it half-written.
+func writeAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".watchfolder-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+//Moves a file, copying it when source and destination are on different filesystems.
+func move(source string, destination string) error {
+	err := os.Rename(source, destination)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(destination, data); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
@@ -0,0 +1,162 @@
+package watchfolder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+//Creates a watcher over a fresh directory whose clock only moves when advanced.
+func newTestWatcher(t *testing.T) (*Watcher, string, func(time.Duration)) {
+	root := t.TempDir()
+	incoming := filepath.Join(root, "incoming")
+	os.Mkdir(incoming, 0755)
+
+	watcher := New(Options{
+		Directory: incoming,
+		Outbox: filepath.Join(root, "outbox"),
+		Rules: []Rule{{Pattern: regexp.MustCompile(`(?i)\.S\d{2}E\d{2}\.`), Category: "tv"}},
+		Settle: time.Second,
+	})
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	watcher.now = func() time.Time { return clock }
+	return watcher, root, func(d time.Duration) { clock = clock.Add(d) }
+}
+
+//Polls twice with the settle time in between, as the watcher needs to see a file unchanged.
+func pollSettled(t *testing.T, watcher *Watcher, advance func(time.Duration)) []Result {
+	if results, err := watcher.Poll(context.Background()); err != nil || len(results) != 0 {
+		t.Fatalf("unsettled files were processed: %v, %v", results, err)
+	}
+	advance(time.Second)
+	results, err := watcher.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestWatcherRoutesByCategory(t *testing.T) {
+	watcher, root, advance := newTestWatcher(t)
+	incoming := watcher.options.Directory
+
+	data, err := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(incoming, "sample.nzb"), data, 0644)
+
+	//No category meta, so the filename rule applies; compressed NZBs are accepted too.
+	withoutMeta := regexp.MustCompile(`<meta type="category">[^<]*</meta>`).ReplaceAll(data, nil)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(withoutMeta)
+	gz.Close()
+	os.WriteFile(filepath.Join(incoming, "Show.S01E02.720p.nzb.gz"), compressed.Bytes(), 0644)
+
+	results := pollSettled(t, watcher, advance)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+
+	moved := filepath.Join(root, "outbox", "misc.", "sample.nzb")
+	if _, err := os.Stat(moved); err != nil {
+		t.Fatal("NZB was not moved to its category outbox")
+	}
+	var summary Summary
+	raw, _ := os.ReadFile(moved + ".json")
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Name != "sample" || summary.Category != "misc." || !summary.CategoryFromMeta || !summary.HasPassword || summary.Files == 0 || summary.Posted == nil {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	//Files without dates leave the posting time out rather than writing the zero time.
+	if raw, _ := json.Marshal(Summarize(&parser.Nzb{}, "undated")); bytes.Contains(raw, []byte("posted")) {
+		t.Fatalf("undated summary has a posting time: %s", raw)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "outbox", "tv", "Show.S01E02.720p.nzb.gz.json")); err != nil {
+		t.Fatal("filename rule was not applied")
+	}
+	if entries, _ := os.ReadDir(incoming); len(entries) != 0 {
+		t.Fatalf("files left behind: %v", entries)
+	}
+}
+
+func TestWatcherRejectsInvalidNzb(t *testing.T) {
+	watcher, _, advance := newTestWatcher(t)
+	incoming := watcher.options.Directory
+
+	os.WriteFile(filepath.Join(incoming, "broken.nzb"), []byte("<nzb><file"), 0644)
+	os.WriteFile(filepath.Join(incoming, "empty.nzb"), []byte(`<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb"></nzb>`), 0644)
+
+	results := pollSettled(t, watcher, advance)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Err == nil || r.Summary != nil {
+			t.Fatalf("invalid NZB was accepted: %+v", r)
+		}
+		reason, err := os.ReadFile(r.Destination + ".reason.txt")
+		if err != nil || !strings.Contains(string(reason), r.Err.Error()) {
+			t.Fatalf("missing reason for %s: %q, %v", r.Source, reason, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(incoming, "failed", "empty.nzb")); err != nil {
+		t.Fatal("empty NZB was not moved to the failed folder")
+	}
+}
+
+func TestWatcherWaitsForWritesToFinish(t *testing.T) {
+	watcher, root, advance := newTestWatcher(t)
+	path := filepath.Join(watcher.options.Directory, "growing.nzb")
+	data, _ := os.ReadFile("../_tests/nzbs/samplenzb.nzb")
+
+	os.WriteFile(path, data[:len(data)/2], 0644)
+	watcher.Poll(context.Background())
+	advance(time.Second)
+
+	//Still being written: the size changed, so the settle time starts over.
+	os.WriteFile(path, data, 0644)
+	if results, _ := watcher.Poll(context.Background()); len(results) != 0 {
+		t.Fatal("a file still being written was processed")
+	}
+	advance(500 * time.Millisecond)
+	if results, _ := watcher.Poll(context.Background()); len(results) != 0 {
+		t.Fatal("a file was processed before settling")
+	}
+	advance(500 * time.Millisecond)
+	results, _ := watcher.Poll(context.Background())
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("settled file was not processed: %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(root, "outbox", "misc.", "growing.nzb")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuleWithoutPatternMatchesEverything(t *testing.T) {
+	watcher := New(Options{Rules: []Rule{{Pattern: regexp.MustCompile(`\.S\d{2}E\d{2}\.`), Category: "tv"}, {Category: "catch-all"}}})
+	if category := watcher.categorize("Show.S01E01.nzb"); category != "tv" {
+		t.Fatalf("expected the first matching rule, got %q", category)
+	}
+	if category := watcher.categorize("Anything.nzb"); category != "catch-all" {
+		t.Fatalf("expected the rule without a pattern to match, got %q", category)
+	}
+}
@@ -0,0 +1,68 @@
+package yenc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//Formats an article subject the way most posting tools do: [file/files] - "name" yEnc (part/parts) size.
+//parser.ExtractFilename recovers name from subjects formatted this way.
+func Subject(name string, fileNumber int, fileTotal int, part int, totalParts int, size int64) string {
+	return fmt.Sprintf("[%d/%d] - \"%s\" yEnc (%d/%d) %d", fileNumber, fileTotal, name, part, totalParts, size)
+}
+
+//Generates a random, globally unique message-ID for the given domain, without angle brackets.
+func NewMessageID(domain string) string {
+	if domain == "" {
+		domain = "nzbgo"
+	}
+	random := make([]byte, 16)
+	//crypto/rand never returns an error on supported platforms.
+	rand.Read(random)
+	return fmt.Sprintf("%s-%d@%s", hex.EncodeToString(random), time.Now().UnixNano(), domain)
+}
+
+//Splits a file into parts and builds one yEnc-encoded article per part, complete with headers and a fresh message-ID.
+func BuildArticles(name string, data []byte, opts ArticleOptions) []Article {
+	fileNumber := max(opts.FileNumber, 1)
+	fileTotal := max(opts.FileTotal, 1)
+	date := opts.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	parts := SplitParts(name, data, opts.PartSize)
+	articles := make([]Article, 0, len(parts))
+	for _, p := range parts {
+		articles = append(articles, Article{
+			From: opts.From,
+			Newsgroups: opts.Newsgroups,
+			Subject: Subject(name, fileNumber, fileTotal, p.Number, p.Total, p.FileSize),
+			MessageID: NewMessageID(opts.Domain),
+			Date: date,
+			Body: EncodePart(p, opts.LineLength),
+			Part: p,
+		})
+	}
+	return articles
+}
+
+//Serializes an article into its wire form: headers, a blank line and the body, all CRLF-terminated.
+//NNTP dot-stuffing is left to the transport.
+func ArticleBytes(article *Article) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", article.From)
+	fmt.Fprintf(&out, "Newsgroups: %s\r\n", strings.Join(article.Newsgroups, ","))
+	fmt.Fprintf(&out, "Subject: %s\r\n", article.Subject)
+	fmt.Fprintf(&out, "Message-ID: <%s>\r\n", article.MessageID)
+	if !article.Date.IsZero() {
+		fmt.Fprintf(&out, "Date: %s\r\n", article.Date.UTC().Format(time.RFC1123Z))
+	}
+	out.WriteString("\r\n")
+	out.Write(article.Body)
+	return out.Bytes()
+}
@@ -0,0 +1,153 @@
+package yenc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+//Errors returned while decoding a yEnc body.
+var (
+	ErrNoHeader = errors.New("yenc: no =ybegin line found")
+	ErrNoTrailer = errors.New("yenc: no =yend line found")
+	ErrSizeMismatch = errors.New("yenc: decoded size does not match =yend size")
+	ErrCRCMismatch = errors.New("yenc: crc32 does not match =yend checksum")
+)
+
+//Parses the "key=value" pairs of a =ybegin, =ypart or =yend line. The name key always consumes the rest of the line, as filenames may contain spaces.
+func parseKeywords(line string) map[string]string {
+	keywords := map[string]string{}
+	//Dropping the leading "=ybegin", "=ypart" or "=yend".
+	_, line, _ = strings.Cut(line, " ")
+
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, "name=") {
+			keywords["name"] = strings.TrimRight(line[len("name="):], " \r")
+			break
+		}
+
+		var field string
+		field, line, _ = strings.Cut(line, " ")
+		if key, value, ok := strings.Cut(field, "="); ok {
+			keywords[key] = value
+		}
+	}
+	return keywords
+}
+
+//Retrieves a decimal keyword value, or 0 if it is absent or malformed.
+func intKeyword(keywords map[string]string, key string) int64 {
+	value, _ := strconv.ParseInt(keywords[key], 10, 64)
+	return value
+}
+
+//Retrieves a hexadecimal checksum keyword value, and whether it was present.
+func crcKeyword(keywords map[string]string, key string) (uint32, bool) {
+	value, err := strconv.ParseUint(keywords[key], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(value), true
+}
+
+//Decodes the yEnc body of an article, returning the part it carries. Anything before the =ybegin line is ignored.
+//The body is expected to already be free of NNTP dot-stuffing. When the checksum or size in =yend does not match the decoded data,
+//the part is still returned alongside ErrCRCMismatch or ErrSizeMismatch so callers may decide whether to keep it.
+func Decode(body []byte) (*Part, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+
+	var (
+		part Part
+		data = make([]byte, 0, len(body))
+		begun, ended, escaped bool
+		trailer map[string]string
+	)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if !begun {
+			if bytes.HasPrefix(line, []byte("=ybegin ")) {
+				keywords := parseKeywords(string(line))
+				part.Name = keywords["name"]
+				part.Number = int(intKeyword(keywords, "part"))
+				part.Total = int(intKeyword(keywords, "total"))
+				part.FileSize = intKeyword(keywords, "size")
+				begun = true
+			}
+			continue
+		}
+
+		if bytes.HasPrefix(line, []byte("=ypart ")) {
+			keywords := parseKeywords(string(line))
+			part.Begin = intKeyword(keywords, "begin")
+			part.End = intKeyword(keywords, "end")
+			continue
+		}
+
+		if bytes.HasPrefix(line, []byte("=yend")) {
+			trailer = parseKeywords(string(line))
+			ended = true
+			break
+		}
+
+		//Escapes are carried over line boundaries in case an encoder split them.
+		for _, b := range bytes.TrimRight(line, "\r") {
+			if escaped {
+				data = append(data, b-64-42)
+				escaped = false
+				continue
+			}
+			if b == '=' {
+				escaped = true
+				continue
+			}
+			data = append(data, b-42)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !begun {
+		return nil, ErrNoHeader
+	}
+
+	part.Data = data
+	part.CRC32 = crc32.ChecksumIEEE(data)
+
+	//Single-part posts have no =ypart line; the part then spans the whole file.
+	if part.Begin == 0 && part.End == 0 {
+		part.Begin = 1
+		part.End = int64(len(data))
+		part.Number = max(part.Number, 1)
+		part.Total = max(part.Total, 1)
+	}
+
+	if !ended {
+		return &part, ErrNoTrailer
+	}
+
+	if size, ok := trailer["size"]; ok && size != strconv.Itoa(len(data)) {
+		return &part, ErrSizeMismatch
+	}
+
+	if crc, ok := crcKeyword(trailer, "crc32"); ok {
+		part.FileCRC32 = crc
+	}
+
+	//pcrc32 covers the part; crc32 covers the part only when the post is single-part.
+	expected, ok := crcKeyword(trailer, "pcrc32")
+	if !ok && trailer["part"] == "" {
+		expected, ok = crcKeyword(trailer, "crc32")
+	}
+	if ok && expected != part.CRC32 {
+		return &part, ErrCRCMismatch
+	}
+	return &part, nil
+}
@@ -0,0 +1,119 @@
+// Allows for the encoding and decoding of yEnc data, the binary encoding used by nearly every Usenet post referenced in an NZB.
+package yenc
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+)
+
+//Checks whether an encoded byte always has to be escaped: NUL, LF, CR and the escape character itself.
+func isCritical(b byte) bool {
+	return b == 0x00 || b == '\n' || b == '\r' || b == '='
+}
+
+//yEnc-encodes raw data, wrapping it every lineLength characters. Lines are terminated by CRLF.
+//Tabs and spaces are escaped at the start and end of a line, and dots at the start, so that neither transports nor NNTP dot-stuffing can mangle them.
+func Encode(data []byte, lineLength int) []byte {
+	if lineLength <= 0 {
+		lineLength = DefaultLineLength
+	}
+
+	//Encoding grows data by roughly 2%, plus line endings.
+	var out bytes.Buffer
+	out.Grow(len(data) + len(data)/50 + (len(data)/lineLength+1)*2)
+
+	column := 0
+	for i, b := range data {
+		encoded := b + 42
+		lineStart := column == 0
+		lineEnd := column >= lineLength-1 || i == len(data)-1
+
+		escape := isCritical(encoded) ||
+		((encoded == '\t' || encoded == ' ') && (lineStart || lineEnd)) ||
+		(encoded == '.' && lineStart)
+
+		if escape {
+			out.WriteByte('=')
+			encoded += 64
+			column++
+		}
+		out.WriteByte(encoded)
+		column++
+
+		if column >= lineLength {
+			out.WriteString("\r\n")
+			column = 0
+		}
+	}
+
+	if column > 0 {
+		out.WriteString("\r\n")
+	}
+	return out.Bytes()
+}
+
+//Splits a file's contents into parts of partSize raw bytes each, calculating their offsets and CRCs.
+//The last part additionally carries the CRC32 of the whole file.
+func SplitParts(name string, data []byte, partSize int) []Part {
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+
+	total := (len(data) + partSize - 1) / partSize
+	//An empty file is still posted as a single, empty part.
+	if total == 0 {
+		total = 1
+	}
+
+	parts := make([]Part, 0, total)
+	for i := 0; i < total; i++ {
+		start := i * partSize
+		end := min(start+partSize, len(data))
+		chunk := data[start:end]
+
+		parts = append(parts, Part{
+			Name: name,
+			Number: i + 1,
+			Total: total,
+			Begin: int64(start) + 1,
+			End: int64(end),
+			FileSize: int64(len(data)),
+			Data: chunk,
+			CRC32: crc32.ChecksumIEEE(chunk),
+		})
+	}
+	parts[len(parts)-1].FileCRC32 = crc32.ChecksumIEEE(data)
+	return parts
+}
+
+//Produces a complete yEnc body for a part: the =ybegin and =ypart headers, encoded data and the =yend trailer.
+//Single-part files are written without part information, as the yEnc 1.3 specification describes.
+func EncodePart(part Part, lineLength int) []byte {
+	if lineLength <= 0 {
+		lineLength = DefaultLineLength
+	}
+
+	var out bytes.Buffer
+	multipart := part.Total > 1
+
+	if multipart {
+		fmt.Fprintf(&out, "=ybegin part=%d total=%d line=%d size=%d name=%s\r\n", part.Number, part.Total, lineLength, part.FileSize, part.Name)
+		fmt.Fprintf(&out, "=ypart begin=%d end=%d\r\n", part.Begin, part.End)
+	} else {
+		fmt.Fprintf(&out, "=ybegin line=%d size=%d name=%s\r\n", lineLength, part.FileSize, part.Name)
+	}
+
+	out.Write(Encode(part.Data, lineLength))
+
+	if multipart {
+		fmt.Fprintf(&out, "=yend size=%d part=%d pcrc32=%08x", len(part.Data), part.Number, part.CRC32)
+		if part.FileCRC32 != 0 {
+			fmt.Fprintf(&out, " crc32=%08x", part.FileCRC32)
+		}
+	} else {
+		fmt.Fprintf(&out, "=yend size=%d crc32=%08x", len(part.Data), part.CRC32)
+	}
+	out.WriteString("\r\n")
+	return out.Bytes()
+}
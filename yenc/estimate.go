@@ -0,0 +1,13 @@
+package yenc
+
+//Approximate bytes of an article that are not encoded data: NNTP headers plus the =ybegin, =ypart and =yend lines.
+const ArticleOverhead = 400
+
+//Approximate growth of data through yEnc encoding at the default line length: about 1/64 for escaped critical bytes plus a CRLF
+//every 128 characters.
+const EncodingRatio = 1 + 1.0/64 + 2.0/DefaultLineLength
+
+//Estimates the decoded size of an article from its encoded size, such as parser.Segment.Bytes.
+func EstimateDecodedSize(encoded int) int64 {
+	return int64(float64(max(encoded-ArticleOverhead, 0)) / EncodingRatio)
+}
@@ -0,0 +1,55 @@
+package yenc
+
+import "time"
+
+/*
+	Structs used to describe yEnc parts and the NNTP articles carrying them.
+*/
+
+//Default number of encoded characters per line, as used by most posting clients.
+const DefaultLineLength = 128
+
+//Default amount of raw file data per part (700 KiB), a common article size on Usenet.
+const DefaultPartSize = 716800
+
+//A single part of a yEnc-encoded file. Begin and End are 1-based and inclusive, matching the =ypart line.
+type Part struct {
+	Name string
+	Number int
+	Total int
+	Begin int64
+	End int64
+	FileSize int64
+	Data []byte
+	CRC32 uint32
+	//CRC32 of the whole file; only known on the last part when encoding, or when the poster declared it when decoding.
+	FileCRC32 uint32
+}
+
+//An NNTP article carrying a yEnc part. MessageID is stored without angle brackets, the same way parser.Segment.ID is.
+type Article struct {
+	From string
+	Newsgroups []string
+	Subject string
+	MessageID string
+	Date time.Time
+	Body []byte
+	Part Part
+}
+
+//Settings used when building articles from a file.
+type ArticleOptions struct {
+	From string
+	Newsgroups []string
+	//Raw bytes per part; DefaultPartSize if zero.
+	PartSize int
+	//Encoded characters per line; DefaultLineLength if zero.
+	LineLength int
+	//Position of the file within its post, used for the "[n/m]" subject prefix. Both default to 1.
+	FileNumber int
+	FileTotal int
+	//Domain used for generated message-IDs; "nzbgo" if empty.
+	Domain string
+	//Posting date of the articles; time.Now() if zero.
+	Date time.Time
+}
@@ -0,0 +1,84 @@
+package yenc
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jgr0sz/nzbgo/parser"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+	//Making sure every critical value shows up, including at line starts.
+	for i := 0; i < 256; i++ {
+		data[i*3] = byte(i)
+	}
+
+	parts := SplitParts("test file.bin", data, 3000)
+	if len(parts) != 4 {
+		t.Fatalf("expected 4 parts, got %d", len(parts))
+	}
+
+	var joined []byte
+	for _, p := range parts {
+		body := EncodePart(p, 0)
+		for _, line := range strings.Split(string(body), "\r\n") {
+			if len(line) > DefaultLineLength+1 && !strings.HasPrefix(line, "=y") {
+				t.Fatalf("line too long: %d", len(line))
+			}
+			if strings.HasPrefix(line, ".") {
+				t.Fatalf("line starts with an unescaped dot")
+			}
+		}
+
+		decoded, err := Decode(body)
+		if err != nil {
+			t.Fatalf("decoding part %d: %v", p.Number, err)
+		}
+		if decoded.Name != "test file.bin" || decoded.Begin != p.Begin || decoded.End != p.End {
+			t.Fatalf("unexpected part header: %+v", decoded)
+		}
+		joined = append(joined, decoded.Data...)
+	}
+
+	if !bytes.Equal(joined, data) {
+		t.Fatal("decoded data does not match the original")
+	}
+}
+
+func TestDecodeCRCMismatch(t *testing.T) {
+	body := EncodePart(SplitParts("a.bin", []byte("hello world"), 0)[0], 0)
+	body = regexp.MustCompile(`crc32=[0-9a-f]{8}`).ReplaceAll(body, []byte("crc32=00000000"))
+	if _, err := Decode(body); err != ErrCRCMismatch {
+		t.Fatalf("expected ErrCRCMismatch, got %v", err)
+	}
+}
+
+func TestArticleSubjectExtractsFilename(t *testing.T) {
+	articles := BuildArticles("Big Buck Bunny - S01E01.mkv", make([]byte, 5000), ArticleOptions{
+		From: "poster <poster@example.com>",
+		Newsgroups: []string{"alt.binaries.test"},
+		PartSize: 2000,
+		FileNumber: 2,
+		FileTotal: 5,
+	})
+	if len(articles) != 3 {
+		t.Fatalf("expected 3 articles, got %d", len(articles))
+	}
+
+	file := parser.File{Subject: articles[0].Subject}
+	if name := parser.ExtractFilename(file); name != "Big Buck Bunny - S01E01.mkv" {
+		t.Fatalf("ExtractFilename returned %q", name)
+	}
+
+	raw := string(ArticleBytes(&articles[0]))
+	for _, header := range []string{"From: ", "Newsgroups: alt.binaries.test", "Subject: [2/5]", "Message-ID: <"} {
+		if !strings.Contains(raw, header) {
+			t.Fatalf("article is missing %q", header)
+		}
+	}
+}